@@ -0,0 +1,71 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"runtime"
+	"time"
+
+	"github.com/kelseyhightower/envconfig"
+	"github.com/sirupsen/logrus"
+
+	"github.com/julienbt/siri-sm/internal/config"
+	"github.com/julienbt/siri-sm/internal/fakeproducer"
+)
+
+func main() {
+	logger := getLogger()
+
+	var cfg config.ConfigFakeProducer
+	err := envconfig.Process("SIRISM_FAKEPRODUCER", &cfg)
+	if err != nil {
+		logger.Fatal(err)
+	}
+
+	producer := fakeproducer.New(cfg.ProducerRef)
+	producer.Synthetic = cfg.Synthetic
+	fixtureFiles := map[fakeproducer.Operation]string{
+		fakeproducer.OPERATION_CHECK_STATUS:        cfg.CheckStatusFile,
+		fakeproducer.OPERATION_GET_STOP_MONITORING: cfg.GetStopMonitoringFile,
+		fakeproducer.OPERATION_SUBSCRIBE:           cfg.SubscribeFile,
+	}
+	for _, op := range fakeproducer.Operations {
+		response := fakeproducer.Response{
+			Latency:     cfg.Latency,
+			Fault:       cfg.Fault,
+			StatusFalse: cfg.StatusFalse,
+		}
+		if fixtureFiles[op] != "" {
+			response.Body, err = ioutil.ReadFile(fixtureFiles[op])
+			if err != nil {
+				logger.Fatal(err)
+			}
+		}
+		producer.SetResponse(op, response)
+	}
+
+	if cfg.NotifyInterval > 0 {
+		go notify(producer, cfg.NotifyInterval, logger)
+	}
+
+	logger.Infof("fake SIRI producer served on %s", cfg.ListenAddress)
+	logger.Fatal(http.ListenAndServe(cfg.ListenAddress, producer))
+}
+
+func notify(producer *fakeproducer.Producer, interval time.Duration, logger *logrus.Entry) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		err := producer.NotifyStopMonitoring()
+		if err != nil {
+			logger.Error(err)
+		}
+	}
+}
+
+func getLogger() *logrus.Entry {
+	return logrus.WithFields(logrus.Fields{
+		"app":     "fakeproducer",
+		"runtime": runtime.Version(),
+	})
+}
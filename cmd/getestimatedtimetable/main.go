@@ -0,0 +1,66 @@
+package main
+
+import (
+	"runtime"
+	"time"
+
+	"github.com/kelseyhightower/envconfig"
+	"github.com/sirupsen/logrus"
+
+	"github.com/julienbt/siri-sm/internal/bootstrap"
+	"github.com/julienbt/siri-sm/internal/config"
+	"github.com/julienbt/siri-sm/internal/estimatedtimetable"
+	"github.com/julienbt/siri-sm/internal/siri"
+)
+
+func main() {
+	logger := getLogger()
+
+	var cfg config.ConfigGetEstimatedTimetable
+	err := envconfig.Process("SIRISM_GETESTIMATEDTIMETABLE", &cfg)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	err = bootstrap.InstallTransports(logger, nil)
+	if err != nil {
+		logger.Fatal(err)
+	}
+
+	location, err := cfg.Location()
+	if err != nil {
+		logger.Fatal(err)
+	}
+	requestTimestamp := time.Now().In(location)
+	estimatedVehicleJourneys, _, _, err := estimatedtimetable.GetEstimatedTimetable(
+		cfg.ConfigCheckStatus,
+		logger,
+		&requestTimestamp,
+		cfg.LineRefs,
+	)
+	if err != nil {
+		switch e := err.(type) {
+		case *siri.RemoteError:
+			logger.Error(e)
+		default:
+			logger.Fatal(e)
+		}
+		return
+	}
+	for _, journey := range estimatedVehicleJourneys {
+		logger.Infof(
+			"%s line %s to %s: %d estimated calls",
+			journey.VehicleJourneyRef(),
+			journey.LineRef,
+			journey.DestinationName,
+			len(journey.EstimatedCalls),
+		)
+	}
+	logger.Infof("GetEstimatedTimetable: %d journeys", len(estimatedVehicleJourneys))
+}
+
+func getLogger() *logrus.Entry {
+	return logrus.WithFields(logrus.Fields{
+		"app":     "getestimatedtimetable",
+		"runtime": runtime.Version(),
+	})
+}
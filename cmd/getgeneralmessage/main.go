@@ -0,0 +1,61 @@
+package main
+
+import (
+	"runtime"
+	"time"
+
+	"github.com/kelseyhightower/envconfig"
+	"github.com/sirupsen/logrus"
+
+	"github.com/julienbt/siri-sm/internal/bootstrap"
+	"github.com/julienbt/siri-sm/internal/config"
+	"github.com/julienbt/siri-sm/internal/generalmessage"
+	"github.com/julienbt/siri-sm/internal/siri"
+)
+
+func main() {
+	logger := getLogger()
+
+	var cfg config.ConfigGetGeneralMessage
+	err := envconfig.Process("SIRISM_GETGENERALMESSAGE", &cfg)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	err = bootstrap.InstallTransports(logger, nil)
+	if err != nil {
+		logger.Fatal(err)
+	}
+
+	location, err := cfg.Location()
+	if err != nil {
+		logger.Fatal(err)
+	}
+	requestTimestamp := time.Now().In(location)
+	generalMessages, _, _, err := generalmessage.GetGeneralMessage(
+		cfg.ConfigCheckStatus,
+		logger,
+		&requestTimestamp,
+		cfg.InfoChannelRefs,
+	)
+	if err != nil {
+		switch e := err.(type) {
+		case *siri.RemoteError:
+			logger.Error(e)
+		default:
+			logger.Fatal(e)
+		}
+		return
+	}
+	for i := range generalMessages {
+		d := generalMessages[i].Disruption()
+		logger.Infof("%s lines=%v stops=%v: %s", d.Id, d.LineRefs, d.StopRefs, d.Summary)
+	}
+	logger.Infof("GetGeneralMessage: %d messages", len(generalMessages))
+}
+
+func getLogger() *logrus.Entry {
+	return logrus.WithFields(logrus.Fields{
+		"app":     "getgeneralmessage",
+		"runtime": runtime.Version(),
+	})
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"runtime"
+	"time"
+
+	"github.com/kelseyhightower/envconfig"
+	"github.com/sirupsen/logrus"
+
+	"github.com/julienbt/siri-sm/internal/bootstrap"
+	"github.com/julienbt/siri-sm/internal/config"
+	"github.com/julienbt/siri-sm/internal/siri"
+	"github.com/julienbt/siri-sm/internal/situationexchange"
+)
+
+func main() {
+	logger := getLogger()
+
+	var cfg config.ConfigGetSituationExchange
+	err := envconfig.Process("SIRISM_GETSITUATIONEXCHANGE", &cfg)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	err = bootstrap.InstallTransports(logger, nil)
+	if err != nil {
+		logger.Fatal(err)
+	}
+
+	location, err := cfg.Location()
+	if err != nil {
+		logger.Fatal(err)
+	}
+	requestTimestamp := time.Now().In(location)
+	situations, _, _, err := situationexchange.GetSituationExchange(
+		cfg.ConfigCheckStatus,
+		logger,
+		&requestTimestamp,
+		cfg.LineRefs,
+	)
+	if err != nil {
+		switch e := err.(type) {
+		case *siri.RemoteError:
+			logger.Error(e)
+		default:
+			logger.Fatal(e)
+		}
+		return
+	}
+	for i := range situations {
+		d := situations[i].Disruption()
+		logger.Infof("%s lines=%v stops=%v: %s", d.Id, d.LineRefs, d.StopRefs, d.Summary)
+	}
+	logger.Infof("GetSituationExchange: %d situations", len(situations))
+}
+
+func getLogger() *logrus.Entry {
+	return logrus.WithFields(logrus.Fields{
+		"app":     "getsituationexchange",
+		"runtime": runtime.Version(),
+	})
+}
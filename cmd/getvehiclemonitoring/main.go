@@ -0,0 +1,71 @@
+package main
+
+import (
+	"runtime"
+	"time"
+
+	"github.com/kelseyhightower/envconfig"
+	"github.com/sirupsen/logrus"
+
+	"github.com/julienbt/siri-sm/internal/bootstrap"
+	"github.com/julienbt/siri-sm/internal/config"
+	"github.com/julienbt/siri-sm/internal/siri"
+	"github.com/julienbt/siri-sm/internal/vehiclemonitoring"
+)
+
+func main() {
+	logger := getLogger()
+
+	var cfg config.ConfigGetVehicleMonitoring
+	err := envconfig.Process("SIRISM_GETVEHICLEMONITORING", &cfg)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	err = bootstrap.InstallTransports(logger, nil)
+	if err != nil {
+		logger.Fatal(err)
+	}
+
+	location, err := cfg.Location()
+	if err != nil {
+		logger.Fatal(err)
+	}
+	requestTimestamp := time.Now().In(location)
+	vehicleActivities, _, _, err := vehiclemonitoring.GetVehicleMonitoring(
+		cfg.ConfigCheckStatus,
+		logger,
+		&requestTimestamp,
+		cfg.LineRefs,
+	)
+	if err != nil {
+		switch e := err.(type) {
+		case *siri.RemoteError:
+			logger.Error(e)
+		default:
+			logger.Fatal(e)
+		}
+		return
+	}
+	for _, vehicleActivity := range vehicleActivities {
+		journey := vehicleActivity.MonitoredVehicleJourney
+		if journey.VehicleLocation == nil {
+			logger.Infof("%s line %s: no location", journey.VehicleRef, journey.LineRef)
+			continue
+		}
+		logger.Infof(
+			"%s line %s at (%f, %f)",
+			journey.VehicleRef,
+			journey.LineRef,
+			journey.VehicleLocation.Longitude,
+			journey.VehicleLocation.Latitude,
+		)
+	}
+	logger.Infof("GetVehicleMonitoring: %d vehicles", len(vehicleActivities))
+}
+
+func getLogger() *logrus.Entry {
+	return logrus.WithFields(logrus.Fields{
+		"app":     "getvehiclemonitoring",
+		"runtime": runtime.Version(),
+	})
+}
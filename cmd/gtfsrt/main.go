@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"runtime"
+
+	"github.com/kelseyhightower/envconfig"
+	"github.com/sirupsen/logrus"
+
+	"github.com/julienbt/siri-sm/internal/bootstrap"
+	"github.com/julienbt/siri-sm/internal/config"
+	"github.com/julienbt/siri-sm/internal/gtfsrt"
+	"github.com/julienbt/siri-sm/internal/metrics"
+	"github.com/julienbt/siri-sm/internal/poller"
+	"github.com/julienbt/siri-sm/internal/recording"
+	"github.com/julienbt/siri-sm/internal/visitstore"
+)
+
+func main() {
+	logger := getLogger()
+
+	var cfg config.ConfigGtfsRt
+	err := envconfig.Process("SIRISM_GTFSRT", &cfg)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	suppliers, err := config.LoadSuppliers("SIRISM")
+	if err != nil {
+		logger.Fatal(err)
+	}
+	err = bootstrap.InstallTransports(logger, recording.SupplierAddresses(suppliers))
+	if err != nil {
+		logger.Fatal(err)
+	}
+	metrics.Install(recording.SupplierAddresses(suppliers))
+
+	mapping := gtfsrt.NewMapping()
+	if cfg.MappingFile != "" {
+		mapping, err = gtfsrt.LoadMappingFile(cfg.MappingFile)
+		if err != nil {
+			logger.Fatal(err)
+		}
+	}
+
+	store := visitstore.NewStore()
+	metrics.Registry.MustRegister(&metrics.VisitsCollector{Store: store})
+	exporter := &gtfsrt.Exporter{Store: store, Mapping: mapping}
+	p := &poller.Poller{
+		Suppliers: suppliers,
+		Store:     store,
+		Interval:  cfg.PollInterval,
+		Logger:    logger,
+	}
+	go p.Run(context.Background(), func() {
+		if cfg.OutputFile == "" {
+			return
+		}
+		if err := exporter.WriteFile(cfg.OutputFile); err != nil {
+			logger.Error(err)
+		}
+	})
+
+	http.Handle("/gtfs-rt/trip-updates", exporter)
+	http.Handle("/metrics", metrics.Handler())
+	logger.Infof("GTFS-RT feed served on %s", cfg.ListenAddress)
+	logger.Fatal(http.ListenAndServe(cfg.ListenAddress, nil))
+}
+
+func getLogger() *logrus.Entry {
+	return logrus.WithFields(logrus.Fields{
+		"app":     "gtfsrt",
+		"runtime": runtime.Version(),
+	})
+}
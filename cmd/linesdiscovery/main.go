@@ -0,0 +1,68 @@
+package main
+
+import (
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/kelseyhightower/envconfig"
+	"github.com/sirupsen/logrus"
+
+	"github.com/julienbt/siri-sm/internal/bootstrap"
+	"github.com/julienbt/siri-sm/internal/config"
+	"github.com/julienbt/siri-sm/internal/linesdiscovery"
+	"github.com/julienbt/siri-sm/internal/siri"
+)
+
+func main() {
+	logger := getLogger()
+
+	var cfg config.ConfigLinesDiscovery
+	err := envconfig.Process("SIRISM_LINESDISCOVERY", &cfg)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	err = bootstrap.InstallTransports(logger, nil)
+	if err != nil {
+		logger.Fatal(err)
+	}
+
+	location, err := cfg.Location()
+	if err != nil {
+		logger.Fatal(err)
+	}
+	requestTimestamp := time.Now().In(location)
+
+	lines, _, _, err := linesdiscovery.LinesDiscovery(cfg.ConfigCheckStatus, logger, &requestTimestamp)
+	if err != nil {
+		switch e := err.(type) {
+		case *siri.RemoteError:
+			logger.Error(e)
+		default:
+			logger.Fatal(e)
+		}
+		return
+	}
+
+	for _, line := range linesdiscovery.NewCatalog(lines).Lines() {
+		directionNames := make([]string, 0, len(line.Directions))
+		for _, direction := range line.Directions {
+			directionNames = append(directionNames, direction.DirectionName)
+		}
+		logger.Infof(
+			"%s %q monitored=%t directions=[%s]",
+			line.LineRef,
+			line.LineName,
+			line.Monitored,
+			strings.Join(directionNames, ", "),
+		)
+	}
+	logger.Infof("LinesDiscovery: %d lines", len(lines))
+}
+
+func getLogger() *logrus.Entry {
+	return logrus.WithFields(logrus.Fields{
+		"app":     "linesdiscovery",
+		"runtime": runtime.Version(),
+	})
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/julienbt/siri-sm/internal/board"
+	"github.com/julienbt/siri-sm/internal/bootstrap"
+	"github.com/julienbt/siri-sm/internal/config"
+	"github.com/julienbt/siri-sm/internal/getstopmonitoring"
+	"github.com/julienbt/siri-sm/internal/output"
+	"github.com/julienbt/siri-sm/internal/subscribe"
+)
+
+// CLEAR_SCREEN moves the cursor home and clears the terminal
+const CLEAR_SCREEN string = "\033[H\033[2J"
+
+// runBoard shows the departure board of a stop, refreshed with a
+// GetStopMonitoring until interrupted, in the timezone of the supplier
+func runBoard(args []string) int {
+	logger := getLogger("board")
+	flags := flag.NewFlagSet("board", flag.ContinueOnError)
+	options := supplierOptions{}
+	options.register(flags)
+	stop := flags.String("stop", "", "stop id, e.g. CAS001, or monitoring ref")
+	producerRef := flags.String("producer-ref", "", "producer ref of the monitoring ref of the stop id")
+	flags.String("line-refs", "", "comma separated line refs, only the departures of these lines are shown")
+	flags.Bool("validate-line-refs", true, "check the line refs with a LinesDiscovery")
+	refresh := flags.Duration("refresh", 30*time.Second, "interval between two refreshes")
+	limit := flags.Int("limit", 3, "next departures shown by line and destination, all when 0")
+	once := flags.Bool("once", false, "print the board once, without clearing the terminal")
+	if code := parseFlags(flags, args, commands["board"].Usage); code >= 0 {
+		return code
+	}
+	if *stop == "" || *refresh <= 0 || *limit < 0 {
+		flags.Usage()
+		return EXIT_USAGE
+	}
+
+	err := options.apply(flags, "SIRISM_GETSTOPMONITORING")
+	if err != nil {
+		return fail(logger, err)
+	}
+	if *producerRef == "" && options.supplier != nil {
+		*producerRef = options.supplier.ProducerRef
+	}
+	monitoringRef, err := stopMonitoringRef(*stop, *producerRef)
+	if err != nil {
+		logger.Error(err)
+		return EXIT_USAGE
+	}
+	var filterCfg config.ConfigGetStopMonitoring
+	err = loadConfig("SIRISM_GETSTOPMONITORING", &filterCfg)
+	if err != nil {
+		return fail(logger, err)
+	}
+	err = bootstrap.InstallTransports(logger, nil)
+	if err != nil {
+		return fail(logger, err)
+	}
+	getStopMonitoring, location, err := newStopMonitoringFunc(flags, &options, filterCfg, logger, &output.Options{})
+	if err != nil {
+		return fail(logger, err)
+	}
+
+	stopRef := monitoringRef
+	if ref, err := getstopmonitoring.ParseStopPointRef(monitoringRef); err == nil {
+		stopRef = string(ref)
+	}
+	stopLogger := logger.WithField("monitoringRef", monitoringRef)
+	var current *board.Board
+	for {
+		visits, err := getStopMonitoring(stopLogger, monitoringRef)
+		if err == nil {
+			b := board.Build(stopRef, visits, time.Now(), *limit)
+			current = &b
+		}
+		if *once {
+			if err != nil {
+				return fail(stopLogger, err)
+			}
+			err = current.Render(os.Stdout, location)
+			if err != nil {
+				return fail(stopLogger, err)
+			}
+			return EXIT_OK
+		}
+
+		fmt.Print(CLEAR_SCREEN)
+		if current != nil {
+			current.Render(os.Stdout, location)
+		}
+		if err != nil {
+			fmt.Printf("\nrefresh failed at %s: %s\n", time.Now().In(location).Format("15:04:05"), err)
+		}
+		time.Sleep(*refresh)
+	}
+}
+
+// stopMonitoringRef returns the monitoring ref of a stop id, e.g.
+// `ILEVIA:StopPoint:BP:CAS001:LOC` for `CAS001`, a ref is kept as is
+func stopMonitoringRef(stop string, producerRef string) (string, error) {
+	if strings.Contains(stop, ":") {
+		return stop, nil
+	}
+	if producerRef == "" {
+		return "", fmt.Errorf("the producer ref of the stop %s is unknown (set --producer-ref or --supplier)", stop)
+	}
+	return subscribe.MonitoringRef(producerRef, stop), nil
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"flag"
+	"time"
+
+	"github.com/julienbt/siri-sm/internal/bootstrap"
+	"github.com/julienbt/siri-sm/internal/checkstatus"
+	"github.com/julienbt/siri-sm/internal/config"
+	"github.com/julienbt/siri-sm/internal/output"
+)
+
+func runCheckStatus(args []string) int {
+	logger := getLogger("checkstatus")
+	flags := flag.NewFlagSet("checkstatus", flag.ContinueOnError)
+	options := supplierOptions{}
+	options.register(flags)
+	outputs := output.Options{}
+	outputs.Register(flags)
+	if code := parseFlags(flags, args, commands["checkstatus"].Usage); code >= 0 {
+		return code
+	}
+
+	err := outputs.Validate()
+	if err != nil {
+		logger.Error(err)
+		return EXIT_USAGE
+	}
+	err = options.apply(flags, "SIRISM_CHECKSTATUS")
+	if err != nil {
+		return fail(logger, err)
+	}
+	err = options.requireSoap("checkstatus")
+	if err != nil {
+		return fail(logger, err)
+	}
+	var cfg config.ConfigCheckStatus
+	err = loadConfig("SIRISM_CHECKSTATUS", &cfg)
+	if err != nil {
+		return fail(logger, err)
+	}
+	err = bootstrap.InstallTransports(logger, nil)
+	if err != nil {
+		return fail(logger, err)
+	}
+	ts := time.Now()
+
+	result, htmlReqBody, htmlRespBody, err := checkstatus.CheckStatus(cfg, logger, &ts)
+	outputs.DumpExchange(htmlReqBody, htmlRespBody)
+	if err != nil {
+		return fail(logger, err)
+	}
+	err = outputs.Write("checkStatus", output.CHECK_STATUS_HEADER, []output.Record{output.NewCheckStatusRecord(result)})
+	if err != nil {
+		return fail(logger, err)
+	}
+	return EXIT_OK
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	siri_time "github.com/julienbt/siri-sm/internal/common/time"
+	"github.com/julienbt/siri-sm/internal/inspect"
+)
+
+const INSPECT_USAGE string = "inspect [--json] [--timezone <tz>] <file>"
+
+func runInspect(args []string) int {
+	flags := flag.NewFlagSet("inspect", flag.ContinueOnError)
+	asJson := flags.Bool("json", false, "print the report and the decoded answer as JSON")
+	timezone := flags.String("timezone", siri_time.DEFAULT_TIMEZONE, "of the supplier, the times without UTC offset are read in it")
+	if err := flags.Parse(args); err != nil || flags.NArg() != 1 {
+		fmt.Fprintf(os.Stderr, "usage: sirism %s\n", INSPECT_USAGE)
+		return EXIT_USAGE
+	}
+
+	location, err := siri_time.LoadLocation(*timezone)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return EXIT_USAGE
+	}
+	body, err := ioutil.ReadFile(flags.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return EXIT_FAILURE
+	}
+	report := inspect.Inspect(body, location)
+
+	if *asJson {
+		output, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return EXIT_FAILURE
+		}
+		fmt.Println(string(output))
+	} else {
+		if report.Binding != "" {
+			fmt.Printf("binding: %s\n", report.Binding)
+		}
+		if report.Message != "" {
+			fmt.Printf("message: %s\n", report.Message)
+		}
+		for _, line := range report.Summary {
+			fmt.Println(line)
+		}
+	}
+
+	if report.Error != "" {
+		if report.ErrorPath != "" {
+			fmt.Fprintf(os.Stderr, "decode error at %s: %s\n", report.ErrorPath, report.Error)
+		} else {
+			fmt.Fprintf(os.Stderr, "error: %s\n", report.Error)
+		}
+		return EXIT_FAILURE
+	}
+	return EXIT_OK
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"sort"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Exit codes of the subcommands
+const (
+	EXIT_OK      int = 0
+	EXIT_FAILURE int = 1 // local failure, e.g. bad configuration or undecodable message
+	EXIT_USAGE   int = 2 // bad arguments
+	EXIT_REMOTE  int = 3 // the supplier failed or refused the request
+)
+
+type command struct {
+	Usage string
+	Run   func(args []string) int
+}
+
+var commands map[string]command
+
+func init() {
+	commands = map[string]command{
+		"board":           {Usage: "board [supplier flags] --stop id [--producer-ref ref] [--line-refs refs] [--refresh d] [--limit n] [--once]", Run: runBoard},
+		"checkstatus":     {Usage: "checkstatus [supplier flags]", Run: runCheckStatus},
+		"inspect":         {Usage: INSPECT_USAGE, Run: runInspect},
+		"reconcile":       {Usage: "reconcile [supplier flags] [--subscriptions-file file] [--stop-list-file file] [--line-refs refs] [--dry-run]", Run: runReconcile},
+		"serve":           {Usage: "serve [--supplier name] [--listen addr] [--poll-interval d]", Run: runServe},
+		"stop-monitoring": {Usage: "stop-monitoring [supplier flags] [--monitoring-ref refs] [--line-refs refs]", Run: runStopMonitoring},
+		"subscribe":       {Usage: "subscribe [supplier flags] [--producer-ref ref] [--consumer-address url] [--service sm|et|vm|gm|sx] [--line-refs refs] [--stop-list-file file]", Run: runSubscribe},
+		"unsubscribe":     {Usage: "unsubscribe [supplier flags] [--subscription-refs refs]", Run: runUnsubscribe},
+	}
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(EXIT_USAGE)
+	}
+	cmd, ok := commands[os.Args[1]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command: %s\n", os.Args[1])
+		usage()
+		os.Exit(EXIT_USAGE)
+	}
+	os.Exit(cmd.Run(os.Args[2:]))
+}
+
+func usage() {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	fmt.Fprintln(os.Stderr, "usage:")
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  sirism %s\n", commands[name].Usage)
+	}
+	fmt.Fprintln(os.Stderr, "supplier flags:")
+	fmt.Fprintln(os.Stderr, "  --supplier name  the SIRISM_SUPPLIER_<NAME>_* configuration")
+	fmt.Fprintln(os.Stderr, "  --address url  --subscriber-ref ref  --binding soap11|soap12|raw  --timezone tz")
+	fmt.Fprintln(os.Stderr, "the flags override the configuration of the environment variables")
+}
+
+func getLogger(command string) *logrus.Entry {
+	return logrus.WithFields(logrus.Fields{
+		"app":     "sirism",
+		"command": command,
+		"runtime": runtime.Version(),
+	})
+}
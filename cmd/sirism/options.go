@@ -0,0 +1,184 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/kelseyhightower/envconfig"
+	"github.com/sirupsen/logrus"
+
+	"github.com/julienbt/siri-sm/internal/config"
+	"github.com/julienbt/siri-sm/internal/siri"
+	"github.com/julienbt/siri-sm/internal/subscriptionstate"
+)
+
+// FLAG_ENV_SUFFIXES maps the flags to the suffix of the environment variable
+// they override, e.g. `--address` overrides `SIRISM_CHECKSTATUS_SUPPLIER_ADDRESS`
+var FLAG_ENV_SUFFIXES = map[string]string{
+	"address":            "SUPPLIER_ADDRESS",
+	"subscriber-ref":     "SUBSCRIBER_REF",
+	"binding":            "BINDING",
+	"producer-ref":       "PRODUCER_REF",
+	"consumer-address":   "CONSUMER_ADDRESS",
+	"service":            "SERVICE",
+	"line-refs":          "LINE_REFS",
+	"validate-line-refs": "VALIDATE_LINE_REFS",
+	"stop-list-file":     "STOP_LIST_FILE",
+	"listen":             "LISTEN_ADDRESS",
+	"poll-interval":      "POLL_INTERVAL",
+	"timezone":           "TIMEZONE",
+	"chunk-size":         "CHUNK_SIZE",
+	"chunk-concurrency":  "CHUNK_CONCURRENCY",
+	"subscriptions-file": "SUBSCRIPTIONS_FILE",
+	"renewal-margin":     "RENEWAL_MARGIN",
+}
+
+// supplierOptions are the flags selecting and overriding the supplier
+type supplierOptions struct {
+	Supplier string
+	supplier *config.ConfigSupplier
+}
+
+func (o *supplierOptions) register(flags *flag.FlagSet) {
+	flags.StringVar(&o.Supplier, "supplier", "", "name of a supplier configured with SIRISM_SUPPLIER_<NAME>_*")
+	flags.String("address", "", "address of the supplier")
+	flags.String("subscriber-ref", "", "requestor/subscriber ref")
+	flags.String("binding", "", `"soap11", "soap12" or "raw"`)
+	flags.String("timezone", "", "timezone of the supplier, e.g. Europe/Paris")
+}
+
+// apply sets the environment variables of the prefixes from the selected
+// supplier and then from the flags set on the command line, before the
+// configuration is loaded
+func (o *supplierOptions) apply(flags *flag.FlagSet, prefixes ...string) error {
+	if o.Supplier != "" {
+		supplier, err := findSupplier(o.Supplier)
+		if err != nil {
+			return err
+		}
+		o.supplier = &supplier
+		values := map[string]string{
+			"SUPPLIER_ADDRESS":   supplier.SupplierAddress,
+			"SUBSCRIBER_REF":     supplier.SubscriberRef,
+			"BINDING":            supplier.Binding,
+			"PRODUCER_REF":       supplier.ProducerRef,
+			"CONSUMER_ADDRESS":   supplier.ConsumerAddress,
+			"LINE_REFS":          strings.Join(supplier.LineRefs, ","),
+			"STOP_LIST_FILE":     supplier.StopListFile,
+			"TIMEZONE":           supplier.Timezone,
+			"MESSAGE_ID_PATTERN": supplier.MessageIdPattern,
+		}
+		for _, prefix := range prefixes {
+			for suffix, value := range values {
+				if value != "" {
+					os.Setenv(prefix+"_"+suffix, value)
+				}
+			}
+		}
+	}
+	flags.Visit(func(f *flag.Flag) {
+		suffix, ok := FLAG_ENV_SUFFIXES[f.Name]
+		if !ok {
+			return
+		}
+		for _, prefix := range prefixes {
+			os.Setenv(prefix+"_"+suffix, f.Value.String())
+		}
+	})
+	return nil
+}
+
+// requireSoap fails for the SIRI Lite suppliers, which only have a
+// StopMonitoring service
+func (o *supplierOptions) requireSoap(command string) error {
+	if o.supplier != nil && o.supplier.Protocol == config.PROTOCOL_SIRI_LITE {
+		return fmt.Errorf("%s is not available for the SIRI Lite supplier %s", command, o.supplier.Name)
+	}
+	return nil
+}
+
+func findSupplier(name string) (config.ConfigSupplier, error) {
+	suppliers, err := config.LoadSuppliers("SIRISM")
+	if err != nil {
+		return config.ConfigSupplier{}, err
+	}
+	for _, supplier := range suppliers {
+		if strings.EqualFold(supplier.Name, name) {
+			return supplier, nil
+		}
+	}
+	return config.ConfigSupplier{}, fmt.Errorf("unknown supplier: %s", name)
+}
+
+// openSubscriptionState opens the store of the subscriptions, nil when it
+// isn't configured
+func openSubscriptionState() (*subscriptionstate.Store, error) {
+	stateCfg, err := config.LoadSubscriptionState("SIRISM")
+	if err != nil {
+		return nil, err
+	}
+	if stateCfg.SubscriptionStateFile == "" {
+		return nil, nil
+	}
+	return subscriptionstate.Open(stateCfg.SubscriptionStateFile)
+}
+
+// splitRefs splits a comma separated list of refs
+func splitRefs(s string) []string {
+	refs := make([]string, 0)
+	for _, ref := range strings.Split(s, ",") {
+		ref = strings.TrimSpace(ref)
+		if ref != "" {
+			refs = append(refs, ref)
+		}
+	}
+	return refs
+}
+
+// parseFlags returns the exit code of a usage error, or -1
+func parseFlags(flags *flag.FlagSet, args []string, usage string) int {
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: sirism %s\n", usage)
+		flags.PrintDefaults()
+	}
+	err := flags.Parse(args)
+	if err == flag.ErrHelp {
+		return EXIT_OK
+	}
+	if err != nil || flags.NArg() != 0 {
+		if err == nil {
+			flags.Usage()
+		}
+		return EXIT_USAGE
+	}
+	return -1
+}
+
+// exitCodeOf the error of a command, the errors of the supplier are
+// `*siri.RemoteError`
+func exitCodeOf(err error) int {
+	switch err.(type) {
+	case nil:
+		return EXIT_OK
+	case *siri.RemoteError:
+		return EXIT_REMOTE
+	}
+	return EXIT_FAILURE
+}
+
+// fail logs the error and returns its exit code
+func fail(logger *logrus.Entry, err error) int {
+	logger.Error(err)
+	return exitCodeOf(err)
+}
+
+// loadConfig loads the configuration once the flags are applied
+func loadConfig(prefix string, cfg interface{}) error {
+	err := envconfig.Process(prefix, cfg)
+	if err != nil {
+		return fmt.Errorf("%s (set the variable, the flag or --supplier)", err)
+	}
+	return nil
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/julienbt/siri-sm/internal/bootstrap"
+	"github.com/julienbt/siri-sm/internal/config"
+	"github.com/julienbt/siri-sm/internal/linesdiscovery"
+	"github.com/julienbt/siri-sm/internal/output"
+	"github.com/julienbt/siri-sm/internal/reconcile"
+	"github.com/julienbt/siri-sm/internal/siri"
+	"github.com/julienbt/siri-sm/internal/subscribe"
+	"github.com/julienbt/siri-sm/internal/subscriptionstate"
+)
+
+func runReconcile(args []string) int {
+	logger := getLogger("reconcile")
+	flags := flag.NewFlagSet("reconcile", flag.ContinueOnError)
+	options := supplierOptions{}
+	options.register(flags)
+	outputs := output.Options{}
+	outputs.Register(flags)
+	flags.String("producer-ref", "", "producer ref of the monitoring refs")
+	flags.String("consumer-address", "", "address of the notifications")
+	flags.String("line-refs", "", "comma separated line refs, one subscription per stop and line")
+	flags.Bool("validate-line-refs", true, "check the line refs with a LinesDiscovery")
+	flags.String("stop-list-file", "", "stop ids to subscribe to")
+	flags.String("subscriptions-file", "", "active subscriptions, e.g. the JSON output of subscribe, rewritten once reconciled, instead of the subscription state")
+	flags.Int("chunk-size", 0, "subscriptions per Subscribe call, all of them in one call when 0")
+	flags.Int("chunk-concurrency", 2, "Subscribe calls sent at once")
+	flags.Duration("renewal-margin", time.Hour, "subscribe again the subscriptions expiring within this margin")
+	dryRun := flags.Bool("dry-run", false, "print the plan without sending any request")
+	if code := parseFlags(flags, args, commands["reconcile"].Usage); code >= 0 {
+		return code
+	}
+
+	err := outputs.Validate()
+	if err != nil {
+		logger.Error(err)
+		return EXIT_USAGE
+	}
+	err = options.apply(flags, "SIRISM_SUBSCRIBE")
+	if err != nil {
+		return fail(logger, err)
+	}
+	err = options.requireSoap("reconcile")
+	if err != nil {
+		return fail(logger, err)
+	}
+	var cfg config.ConfigSubscribe
+	err = loadConfig("SIRISM_SUBSCRIBE", &cfg)
+	if err != nil {
+		return fail(logger, err)
+	}
+	err = bootstrap.InstallTransports(logger, nil)
+	if err != nil {
+		return fail(logger, err)
+	}
+	state, err := openSubscriptionState()
+	if err != nil {
+		return fail(logger, err)
+	}
+	if cfg.SubscriptionsFile == "" && state == nil {
+		return fail(logger, fmt.Errorf("the active subscriptions are required to reconcile (set --subscriptions-file or SIRISM_SUBSCRIPTION_STATE_FILE)"))
+	}
+	ts := time.Now()
+
+	stopPointIds, err := subscribe.StopPointIds(&cfg)
+	if err != nil {
+		return fail(logger, err)
+	}
+	// The subscriptions file has precedence over the subscription state
+	var active []reconcile.Subscription
+	if cfg.SubscriptionsFile != "" {
+		active, err = reconcile.ReadFile(cfg.SubscriptionsFile)
+	} else {
+		var records []subscriptionstate.Record
+		records, err = state.Active(cfg.SupplierAddress, ts)
+		active = subscriptionstate.Subscriptions(records)
+	}
+	if err != nil {
+		return fail(logger, err)
+	}
+	plan, err := reconcile.NewPlan(&cfg, stopPointIds, active, ts)
+	if err != nil {
+		return fail(logger, err)
+	}
+	logger.Infof("%d subscriptions to keep, %d to subscribe and %d to delete",
+		plan.Count(reconcile.ACTION_KEEP), plan.Count(reconcile.ACTION_SUBSCRIBE), plan.Count(reconcile.ACTION_DELETE))
+
+	if !*dryRun {
+		if len(cfg.LineRefs) > 0 && cfg.ValidateLineRefs && len(plan.StopPointIds()) > 0 {
+			lines, _, _, err := linesdiscovery.LinesDiscovery(cfg.CheckStatusConfig(), logger, &ts)
+			if err != nil {
+				return fail(logger, err)
+			}
+			err = linesdiscovery.NewCatalog(lines).Validate(cfg.LineRefs)
+			if err != nil {
+				return fail(logger, err)
+			}
+		}
+		var htmlReqBody string
+		var htmlRespBody []byte
+		plan, htmlReqBody, htmlRespBody, err = reconcile.Apply(cfg, logger, &ts, plan)
+		outputs.DumpExchange(htmlReqBody, htmlRespBody)
+		if cfg.SubscriptionsFile != "" {
+			if writeErr := reconcile.WriteFile(cfg.SubscriptionsFile, plan.Active(cfg.SubscriberRef)); writeErr != nil {
+				logger.Error(writeErr)
+			}
+		}
+		if state != nil {
+			if recordErr := state.RecordPlan(cfg.SupplierAddress, cfg.SubscriberRef, cfg.ProducerRef, plan, ts); recordErr != nil {
+				logger.Error(recordErr)
+			}
+		}
+		if err != nil {
+			return fail(logger, err)
+		}
+	}
+
+	records := make([]output.Record, 0, len(plan.Steps))
+	for _, step := range plan.Steps {
+		records = append(records, output.NewReconcileRecord(step))
+	}
+	err = outputs.Write("plan", output.RECONCILE_HEADER, records)
+	if err != nil {
+		return fail(logger, err)
+	}
+	if failed := plan.Failed(); failed > 0 {
+		return fail(logger, &siri.RemoteError{
+			Loc: "Reconcile remote error",
+			Err: fmt.Errorf("%d subscriptions not reconciled out of %d", failed, len(plan.Steps)),
+		})
+	}
+	return EXIT_OK
+}
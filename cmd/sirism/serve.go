@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/julienbt/siri-sm/internal/api"
+	"github.com/julienbt/siri-sm/internal/bootstrap"
+	"github.com/julienbt/siri-sm/internal/clockskew"
+	"github.com/julienbt/siri-sm/internal/config"
+	"github.com/julienbt/siri-sm/internal/consumer"
+	"github.com/julienbt/siri-sm/internal/heartbeat"
+	"github.com/julienbt/siri-sm/internal/metrics"
+	"github.com/julienbt/siri-sm/internal/poller"
+	"github.com/julienbt/siri-sm/internal/recording"
+	"github.com/julienbt/siri-sm/internal/subscriptionstate"
+	"github.com/julienbt/siri-sm/internal/supplierstatus"
+	"github.com/julienbt/siri-sm/internal/visitstore"
+)
+
+// runServe polls the suppliers and serves the JSON API
+func runServe(args []string) int {
+	logger := getLogger("serve")
+	flags := flag.NewFlagSet("serve", flag.ContinueOnError)
+	supplierName := flags.String("supplier", "", "only poll this supplier")
+	flags.String("listen", "", "listen address of the API, e.g. :8081")
+	flags.Duration("poll-interval", 0, "interval between two polls of the suppliers")
+	if code := parseFlags(flags, args, commands["serve"].Usage); code >= 0 {
+		return code
+	}
+
+	options := supplierOptions{}
+	err := options.apply(flags, "SIRISM_API")
+	if err != nil {
+		return fail(logger, err)
+	}
+	var cfg config.ConfigApi
+	err = loadConfig("SIRISM_API", &cfg)
+	if err != nil {
+		return fail(logger, err)
+	}
+	suppliers, err := config.LoadSuppliers("SIRISM")
+	if err != nil {
+		return fail(logger, err)
+	}
+	if *supplierName != "" {
+		selected := make([]config.ConfigSupplier, 0, 1)
+		for _, supplier := range suppliers {
+			if strings.EqualFold(supplier.Name, *supplierName) {
+				selected = append(selected, supplier)
+			}
+		}
+		if len(selected) == 0 {
+			return fail(logger, fmt.Errorf("unknown supplier: %s", *supplierName))
+		}
+		suppliers = selected
+	}
+	err = bootstrap.InstallTransports(logger, recording.SupplierAddresses(suppliers))
+	if err != nil {
+		return fail(logger, err)
+	}
+	metrics.Install(recording.SupplierAddresses(suppliers))
+	state, err := openSubscriptionState()
+	if err != nil {
+		return fail(logger, err)
+	}
+	if state != nil {
+		err = logReloadedSubscriptions(logger, state, suppliers)
+		if err != nil {
+			return fail(logger, err)
+		}
+	}
+	expected, err := api.ExpectedSubscriptionRefs(suppliers)
+	if err != nil {
+		return fail(logger, err)
+	}
+	store := visitstore.NewStore()
+	metrics.Registry.MustRegister(&metrics.VisitsCollector{Store: store})
+	statuses := supplierstatus.NewStore()
+	p := &poller.Poller{
+		Suppliers: suppliers,
+		Store:     store,
+		Statuses:  statuses,
+		Interval:  cfg.PollInterval,
+		Logger:    logger,
+	}
+	go p.Run(context.Background(), nil)
+
+	tracker := heartbeat.NewTracker(cfg.LivenessWindow)
+	server := &api.Server{
+		Suppliers: suppliers,
+		Store:     store,
+		Statuses:  statuses,
+		Tracker:   tracker,
+		Skews:     clockskew.Default,
+		Metrics:   metrics.Handler(),
+		Notifications: &consumer.Handler{
+			Tracker:       tracker,
+			Subscriptions: state,
+			Suppliers:     suppliers,
+			Logger:        logger,
+		},
+		Health: api.HealthLimits{
+			CheckStatusMaxAge:  cfg.HealthCheckStatusMaxAge,
+			StoreMaxAge:        cfg.HealthStoreMaxAge,
+			NotificationMaxAge: cfg.HealthNotificationMaxAge,
+		},
+		ExpectedSubscriptions: expected,
+	}
+	if state != nil {
+		server.Subscriptions = state
+		metrics.Registry.MustRegister(&metrics.SubscriptionsCollector{
+			State:     state,
+			Suppliers: recording.SupplierAddresses(suppliers),
+		})
+	}
+	logger.Infof("API served on %s", cfg.ListenAddress)
+	return fail(logger, http.ListenAndServe(cfg.ListenAddress, server.Handler()))
+}
+
+// logReloadedSubscriptions logs the subscriptions of the previous runs still
+// active for every supplier
+func logReloadedSubscriptions(logger *logrus.Entry, state *subscriptionstate.Store, suppliers []config.ConfigSupplier) error {
+	now := time.Now()
+	for _, supplier := range suppliers {
+		active, err := state.Active(supplier.SupplierAddress, now)
+		if err != nil {
+			return err
+		}
+		logger.WithField("supplier", supplier.Name).Infof("%d active subscriptions reloaded", len(active))
+	}
+	return nil
+}
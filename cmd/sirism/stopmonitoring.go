@@ -0,0 +1,148 @@
+package main
+
+import (
+	"flag"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/julienbt/siri-sm/internal/bootstrap"
+	"github.com/julienbt/siri-sm/internal/config"
+	"github.com/julienbt/siri-sm/internal/getstopmonitoring"
+	"github.com/julienbt/siri-sm/internal/linesdiscovery"
+	"github.com/julienbt/siri-sm/internal/output"
+	"github.com/julienbt/siri-sm/internal/sirilite"
+)
+
+func runStopMonitoring(args []string) int {
+	logger := getLogger("stop-monitoring")
+	flags := flag.NewFlagSet("stop-monitoring", flag.ContinueOnError)
+	options := supplierOptions{}
+	options.register(flags)
+	outputs := output.Options{}
+	outputs.Register(flags)
+	monitoringRefsFlag := flags.String("monitoring-ref", "", "comma separated monitoring refs, e.g. ILEVIA:StopPoint:BP:CAS001:LOC")
+	flags.String("line-refs", "", "comma separated line refs, only the visits of these lines are kept")
+	flags.Bool("validate-line-refs", true, "check the line refs with a LinesDiscovery")
+	if code := parseFlags(flags, args, commands["stop-monitoring"].Usage); code >= 0 {
+		return code
+	}
+
+	err := outputs.Validate()
+	if err != nil {
+		logger.Error(err)
+		return EXIT_USAGE
+	}
+	err = options.apply(flags, "SIRISM_GETSTOPMONITORING")
+	if err != nil {
+		return fail(logger, err)
+	}
+	var filterCfg config.ConfigGetStopMonitoring
+	err = loadConfig("SIRISM_GETSTOPMONITORING", &filterCfg)
+	if err != nil {
+		return fail(logger, err)
+	}
+	var monitoringRefs []string
+	switch {
+	case *monitoringRefsFlag != "":
+		monitoringRefs = splitRefs(*monitoringRefsFlag)
+	case options.supplier != nil && len(options.supplier.MonitoringRefs) > 0:
+		monitoringRefs = options.supplier.MonitoringRefs
+	case filterCfg.MonitoringRef != "":
+		monitoringRefs = []string{filterCfg.MonitoringRef}
+	default:
+		logger.Error("no stop to request, set --monitoring-ref or a --supplier with monitoring refs")
+		return EXIT_USAGE
+	}
+	err = bootstrap.InstallTransports(logger, nil)
+	if err != nil {
+		return fail(logger, err)
+	}
+
+	getStopMonitoring, _, err := newStopMonitoringFunc(flags, &options, filterCfg, logger, &outputs)
+	if err != nil {
+		return fail(logger, err)
+	}
+
+	records := make([]output.Record, 0)
+	exitCode := EXIT_OK
+	for _, monitoringRef := range monitoringRefs {
+		stopLogger := logger.WithField("monitoringRef", monitoringRef)
+		visits, err := getStopMonitoring(stopLogger, monitoringRef)
+		if err != nil {
+			exitCode = fail(stopLogger, err)
+			continue
+		}
+		for i := range visits {
+			records = append(records, output.NewVisitRecord(&visits[i]))
+		}
+	}
+	err = outputs.Write("visits", output.VISIT_HEADER, records)
+	if err != nil {
+		return fail(logger, err)
+	}
+	return exitCode
+}
+
+type stopMonitoringFunc func(logger *logrus.Entry, monitoringRef string) ([]getstopmonitoring.MonitoredStopVisit, error)
+
+// newStopMonitoringFunc returns the GetStopMonitoring of the selected
+// supplier, in SIRI Lite or in SIRI, and its timezone once the options are
+// applied
+func newStopMonitoringFunc(
+	flags *flag.FlagSet,
+	options *supplierOptions,
+	filterCfg config.ConfigGetStopMonitoring,
+	logger *logrus.Entry,
+	outputs *output.Options,
+) (stopMonitoringFunc, *time.Location, error) {
+	if options.supplier != nil && options.supplier.Protocol == config.PROTOCOL_SIRI_LITE {
+		liteCfg := options.supplier.SiriLiteConfig()
+		if address := flags.Lookup("address").Value.String(); address != "" {
+			liteCfg.SupplierAddress = address
+		}
+		if timezone := flags.Lookup("timezone").Value.String(); timezone != "" {
+			liteCfg.Timezone = timezone
+		}
+		location, err := liteCfg.Location()
+		if err != nil {
+			return nil, nil, err
+		}
+		return func(logger *logrus.Entry, monitoringRef string) ([]getstopmonitoring.MonitoredStopVisit, error) {
+			visits, reqUrl, htmlRespBody, err := sirilite.GetStopMonitoring(liteCfg, logger, monitoringRef, filterCfg.LineRefs)
+			outputs.DumpExchange(reqUrl, htmlRespBody)
+			return visits, err
+		}, location, nil
+	}
+	var cfg config.ConfigCheckStatus
+	err := loadConfig("SIRISM_GETSTOPMONITORING", &cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	location, err := cfg.Location()
+	if err != nil {
+		return nil, nil, err
+	}
+	err = validateLineRefs(cfg, logger, filterCfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	return func(logger *logrus.Entry, monitoringRef string) ([]getstopmonitoring.MonitoredStopVisit, error) {
+		ts := time.Now()
+		visits, htmlReqBody, htmlRespBody, err := getstopmonitoring.GetStopMonitoring(cfg, logger, &ts, monitoringRef, filterCfg.LineRefs)
+		outputs.DumpExchange(htmlReqBody, htmlRespBody)
+		return visits, err
+	}, location, nil
+}
+
+func validateLineRefs(cfg config.ConfigCheckStatus, logger *logrus.Entry, filterCfg config.ConfigGetStopMonitoring) error {
+	if len(filterCfg.LineRefs) == 0 || !filterCfg.ValidateLineRefs {
+		return nil
+	}
+	ts := time.Now()
+	lines, _, _, err := linesdiscovery.LinesDiscovery(cfg, logger, &ts)
+	if err != nil {
+		return err
+	}
+	return linesdiscovery.NewCatalog(lines).Validate(filterCfg.LineRefs)
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/julienbt/siri-sm/internal/bootstrap"
+	"github.com/julienbt/siri-sm/internal/config"
+	"github.com/julienbt/siri-sm/internal/linesdiscovery"
+	"github.com/julienbt/siri-sm/internal/output"
+	"github.com/julienbt/siri-sm/internal/siri"
+	"github.com/julienbt/siri-sm/internal/subscribe"
+)
+
+func runSubscribe(args []string) int {
+	logger := getLogger("subscribe")
+	flags := flag.NewFlagSet("subscribe", flag.ContinueOnError)
+	options := supplierOptions{}
+	options.register(flags)
+	outputs := output.Options{}
+	outputs.Register(flags)
+	flags.String("producer-ref", "", "producer ref of the monitoring refs")
+	flags.String("consumer-address", "", "address of the notifications")
+	flags.String("service", "", `"sm", "et", "vm", "gm" or "sx"`)
+	flags.String("line-refs", "", "comma separated line refs, one subscription per stop and line")
+	flags.Bool("validate-line-refs", true, "check the line refs with a LinesDiscovery")
+	flags.String("stop-list-file", "", "stop ids to subscribe to")
+	flags.Int("chunk-size", 0, "subscriptions per Subscribe call, all of them in one call when 0")
+	flags.Int("chunk-concurrency", 2, "Subscribe calls sent at once")
+	if code := parseFlags(flags, args, commands["subscribe"].Usage); code >= 0 {
+		return code
+	}
+
+	err := outputs.Validate()
+	if err != nil {
+		logger.Error(err)
+		return EXIT_USAGE
+	}
+	err = options.apply(flags, "SIRISM_SUBSCRIBE")
+	if err != nil {
+		return fail(logger, err)
+	}
+	err = options.requireSoap("subscribe")
+	if err != nil {
+		return fail(logger, err)
+	}
+	var cfg config.ConfigSubscribe
+	err = loadConfig("SIRISM_SUBSCRIBE", &cfg)
+	if err != nil {
+		return fail(logger, err)
+	}
+	err = bootstrap.InstallTransports(logger, nil)
+	if err != nil {
+		return fail(logger, err)
+	}
+	state, err := openSubscriptionState()
+	if err != nil {
+		return fail(logger, err)
+	}
+	ts := time.Now()
+
+	if len(cfg.LineRefs) > 0 && cfg.ValidateLineRefs {
+		lines, _, _, err := linesdiscovery.LinesDiscovery(cfg.CheckStatusConfig(), logger, &ts)
+		if err != nil {
+			return fail(logger, err)
+		}
+		err = linesdiscovery.NewCatalog(lines).Validate(cfg.LineRefs)
+		if err != nil {
+			return fail(logger, err)
+		}
+	}
+
+	result, htmlReqBody, htmlRespBody, err := subscribe.Subscribe(cfg, logger, &ts)
+	outputs.DumpExchange(htmlReqBody, htmlRespBody)
+	if err != nil {
+		return fail(logger, err)
+	}
+	if state != nil {
+		err = state.RecordSubscribe(cfg.SupplierAddress, result, ts)
+		if err != nil {
+			return fail(logger, err)
+		}
+	}
+	records := make([]output.Record, 0, len(result.ResponseStatus))
+	rejected := 0
+	for _, status := range result.ResponseStatus {
+		records = append(records, output.NewSubscriptionRecord(status))
+		if !status.Status {
+			rejected++
+		}
+	}
+	err = outputs.Write("subscriptions", output.SUBSCRIPTION_HEADER, records)
+	if err != nil {
+		return fail(logger, err)
+	}
+	if rejected > 0 {
+		return fail(logger, &siri.RemoteError{
+			Loc: "Subscribe remote error",
+			Err: fmt.Errorf("%d subscriptions rejected out of %d", rejected, len(result.ResponseStatus)),
+		})
+	}
+	return EXIT_OK
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/julienbt/siri-sm/internal/bootstrap"
+	"github.com/julienbt/siri-sm/internal/config"
+	"github.com/julienbt/siri-sm/internal/output"
+	"github.com/julienbt/siri-sm/internal/siri"
+	"github.com/julienbt/siri-sm/internal/unsubscribe"
+)
+
+func runUnsubscribe(args []string) int {
+	logger := getLogger("unsubscribe")
+	flags := flag.NewFlagSet("unsubscribe", flag.ContinueOnError)
+	options := supplierOptions{}
+	options.register(flags)
+	outputs := output.Options{}
+	outputs.Register(flags)
+	subscriptionRefsFlag := flags.String("subscription-refs", "", "comma separated subscription refs, all the subscriptions when empty")
+	if code := parseFlags(flags, args, commands["unsubscribe"].Usage); code >= 0 {
+		return code
+	}
+
+	err := outputs.Validate()
+	if err != nil {
+		logger.Error(err)
+		return EXIT_USAGE
+	}
+	err = options.apply(flags, "SIRISM_UNSUBSCRIBE")
+	if err != nil {
+		return fail(logger, err)
+	}
+	err = options.requireSoap("unsubscribe")
+	if err != nil {
+		return fail(logger, err)
+	}
+	var cfg config.ConfigCheckStatus
+	err = loadConfig("SIRISM_UNSUBSCRIBE", &cfg)
+	if err != nil {
+		return fail(logger, err)
+	}
+	err = bootstrap.InstallTransports(logger, nil)
+	if err != nil {
+		return fail(logger, err)
+	}
+	state, err := openSubscriptionState()
+	if err != nil {
+		return fail(logger, err)
+	}
+	ts := time.Now()
+
+	statuses, htmlReqBody, htmlRespBody, err := unsubscribe.Unsubscribe(cfg, logger, &ts, splitRefs(*subscriptionRefsFlag))
+	outputs.DumpExchange(htmlReqBody, htmlRespBody)
+	if err != nil {
+		return fail(logger, err)
+	}
+	if state != nil {
+		err = state.RecordTermination(cfg.SupplierAddress, statuses, ts)
+		if err != nil {
+			return fail(logger, err)
+		}
+	}
+	records := make([]output.Record, 0, len(statuses))
+	failed := 0
+	for _, status := range statuses {
+		records = append(records, output.NewTerminationRecord(status))
+		if !status.Status {
+			failed++
+		}
+	}
+	err = outputs.Write("subscriptions", output.SUBSCRIPTION_HEADER, records)
+	if err != nil {
+		return fail(logger, err)
+	}
+	if failed > 0 {
+		return fail(logger, &siri.RemoteError{
+			Loc: "DeleteSubscription remote error",
+			Err: fmt.Errorf("%d subscriptions not terminated out of %d", failed, len(statuses)),
+		})
+	}
+	return EXIT_OK
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/kelseyhightower/envconfig"
+	"github.com/sirupsen/logrus"
+
+	"github.com/julienbt/siri-sm/internal/bootstrap"
+	"github.com/julienbt/siri-sm/internal/config"
+	"github.com/julienbt/siri-sm/internal/getstopmonitoring"
+	"github.com/julienbt/siri-sm/internal/siri"
+	"github.com/julienbt/siri-sm/internal/stoplist"
+	"github.com/julienbt/siri-sm/internal/stoppointsdiscovery"
+)
+
+func main() {
+	logger := getLogger()
+
+	var cfg config.ConfigStopPointsDiscovery
+	err := envconfig.Process("SIRISM_STOPPOINTSDISCOVERY", &cfg)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	err = bootstrap.InstallTransports(logger, nil)
+	if err != nil {
+		logger.Fatal(err)
+	}
+
+	location, err := cfg.Location()
+	if err != nil {
+		logger.Fatal(err)
+	}
+	requestTimestamp := time.Now().In(location)
+
+	stopPoints, _, _, err := stoppointsdiscovery.StopPointsDiscovery(
+		cfg.ConfigCheckStatus,
+		logger,
+		&requestTimestamp,
+	)
+	if err != nil {
+		switch e := err.(type) {
+		case *siri.RemoteError:
+			logger.Error(e)
+		default:
+			logger.Fatal(e)
+		}
+		return
+	}
+
+	entries := buildStopListEntries(stopPoints, cfg.OnlyMonitored)
+	header := fmt.Sprintf(
+		"Stop points of %s discovered at %s",
+		cfg.SupplierAddress,
+		requestTimestamp.Format(time.RFC3339),
+	)
+	err = stoplist.WriteFile(cfg.StopListFile, header, entries)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	logger.Infof("StopPointsDiscovery: %d stop points written to %s", len(entries), cfg.StopListFile)
+}
+
+func buildStopListEntries(stopPoints []stoppointsdiscovery.StopPoint, onlyMonitored bool) []stoplist.Entry {
+	entries := make([]stoplist.Entry, 0, len(stopPoints))
+	seen := make(map[getstopmonitoring.StopPointRef]bool)
+	for _, stopPoint := range stopPoints {
+		if (onlyMonitored && !stopPoint.IsMonitored()) || seen[stopPoint.StopId] {
+			continue
+		}
+		seen[stopPoint.StopId] = true
+		lineIds := make([]string, 0, len(stopPoint.LineRefs))
+		for _, lineRef := range stopPoint.LineRefs {
+			if lineId, err := getstopmonitoring.ParseLineRef(lineRef); err == nil {
+				lineIds = append(lineIds, string(lineId))
+			} else {
+				lineIds = append(lineIds, lineRef)
+			}
+		}
+		comment := stopPoint.StopName
+		if len(lineIds) > 0 {
+			comment = fmt.Sprintf("%s (%s)", comment, strings.Join(lineIds, ", "))
+		}
+		entries = append(entries, stoplist.Entry{StopId: string(stopPoint.StopId), Comment: comment})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].StopId < entries[j].StopId })
+	return entries
+}
+
+func getLogger() *logrus.Entry {
+	return logrus.WithFields(logrus.Fields{
+		"app":     "stoppointsdiscovery",
+		"runtime": runtime.Version(),
+	})
+}
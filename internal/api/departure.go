@@ -0,0 +1,104 @@
+package api
+
+import (
+	"sort"
+	"time"
+
+	"github.com/julienbt/siri-sm/internal/getstopmonitoring"
+	"github.com/julienbt/siri-sm/internal/visitstore"
+)
+
+type Departure struct {
+	Supplier               string     `json:"supplier"`
+	StopRef                string     `json:"stop_ref"`
+	ItemIdentifier         string     `json:"item_identifier"`
+	LineRef                string     `json:"line_ref"`
+	DirectionName          string     `json:"direction_name"`
+	DestinationRef         string     `json:"destination_ref"`
+	DestinationName        string     `json:"destination_name"`
+	DatedVehicleJourneyRef string     `json:"dated_vehicle_journey_ref,omitempty"`
+	AimedDepartureTime     *time.Time `json:"aimed_departure_time,omitempty"`
+	ExpectedDepartureTime  *time.Time `json:"expected_departure_time,omitempty"`
+	DelaySeconds           int64      `json:"delay_seconds"`
+	Cancelled              bool       `json:"cancelled"`
+	UpdatedAt              time.Time  `json:"updated_at"`
+}
+
+// DepartureTime is the expected departure time, or the aimed one when unknown
+func (d *Departure) DepartureTime() time.Time {
+	if d.ExpectedDepartureTime != nil {
+		return *d.ExpectedDepartureTime
+	}
+	if d.AimedDepartureTime != nil {
+		return *d.AimedDepartureTime
+	}
+	return time.Time{}
+}
+
+type DepartureFilter struct {
+	LineRef string
+	From    time.Time
+	Window  time.Duration // no time filtering when zero
+	Limit   int           // no limit when zero
+}
+
+func (f *DepartureFilter) match(d *Departure) bool {
+	if f.LineRef != "" && d.LineRef != f.LineRef {
+		return false
+	}
+	if f.Window > 0 {
+		departureTime := d.DepartureTime()
+		if departureTime.Before(f.From) || departureTime.After(f.From.Add(f.Window)) {
+			return false
+		}
+	}
+	return true
+}
+
+func newDeparture(stopVisits *visitstore.StopVisits, visit *getstopmonitoring.MonitoredStopVisit) Departure {
+	journey := &visit.MonitoredVehicleJourney
+	departure := Departure{
+		Supplier:               stopVisits.Supplier,
+		StopRef:                string(stopVisits.StopRef),
+		ItemIdentifier:         visit.ItemIdentifier,
+		LineRef:                string(journey.LineRef),
+		DirectionName:          journey.DirectionName.String(),
+		DestinationRef:         string(journey.DestinationRef),
+		DestinationName:        journey.DestinationName,
+		DatedVehicleJourneyRef: journey.FramedVehicleJourneyRef.DatedVehicleJourneyRef,
+		Cancelled:              journey.IsCancelled(),
+		UpdatedAt:              stopVisits.UpdatedAt,
+	}
+	aimed := time.Time(journey.MonitoredCall.AimedDepartureTime)
+	if !aimed.IsZero() {
+		departure.AimedDepartureTime = &aimed
+	}
+	expected := time.Time(journey.MonitoredCall.ExpectedDepartureTime)
+	if !expected.IsZero() {
+		departure.ExpectedDepartureTime = &expected
+	}
+	if !aimed.IsZero() && !expected.IsZero() {
+		departure.DelaySeconds = int64(expected.Sub(aimed) / time.Second)
+	}
+	return departure
+}
+
+// BuildDepartures flattens the stop visits into departures sorted by departure time
+func BuildDepartures(stops []visitstore.StopVisits, filter DepartureFilter) []Departure {
+	departures := make([]Departure, 0)
+	for i := range stops {
+		for j := range stops[i].Visits {
+			departure := newDeparture(&stops[i], &stops[i].Visits[j])
+			if filter.match(&departure) {
+				departures = append(departures, departure)
+			}
+		}
+	}
+	sort.SliceStable(departures, func(i, j int) bool {
+		return departures[i].DepartureTime().Before(departures[j].DepartureTime())
+	})
+	if filter.Limit > 0 && len(departures) > filter.Limit {
+		departures = departures[:filter.Limit]
+	}
+	return departures
+}
@@ -0,0 +1,231 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/julienbt/siri-sm/internal/config"
+	"github.com/julienbt/siri-sm/internal/stoplist"
+	"github.com/julienbt/siri-sm/internal/subscribe"
+	"github.com/julienbt/siri-sm/internal/subscriptionstate"
+)
+
+// HealthLimits are the max ages of the readiness checks, a check is disabled
+// with 0
+type HealthLimits struct {
+	CheckStatusMaxAge  time.Duration // since the last successful CheckStatus, not checked for SIRI Lite
+	StoreMaxAge        time.Duration // since the last update of the visits of the supplier
+	NotificationMaxAge time.Duration // since the last notification, with active subscriptions
+}
+
+type HealthResponse struct {
+	Ready     bool                     `json:"ready"`
+	Error     string                   `json:"error,omitempty"` // the state couldn't be read
+	Suppliers []SupplierHealthResponse `json:"suppliers"`
+}
+
+type SupplierHealthResponse struct {
+	Supplier                 string                       `json:"supplier"`
+	Ready                    bool                         `json:"ready"`
+	LastCheckStatusSuccessAt *time.Time                   `json:"last_check_status_success_at,omitempty"`
+	LastStoreUpdate          *time.Time                   `json:"last_store_update,omitempty"`
+	StoreAgeMs               *int64                       `json:"store_age_ms,omitempty"`
+	Subscriptions            *SubscriptionsHealthResponse `json:"subscriptions,omitempty"`
+	Failures                 []string                     `json:"failures,omitempty"` // the failed checks
+}
+
+// SubscriptionsHealthResponse compares the active subscriptions with the
+// expected ones, the subscriptions of the stop list of the supplier
+type SubscriptionsHealthResponse struct {
+	Active                  int        `json:"active"`
+	Expected                int        `json:"expected"`
+	LastNotification        *time.Time `json:"last_notification,omitempty"`
+	SinceLastNotificationMs *int64     `json:"since_last_notification_ms,omitempty"`
+}
+
+// handleHealthz fails only when the state of the process can't be read, the
+// suppliers are reported without affecting the status code
+func (s *Server) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	response := s.CheckHealth(time.Now())
+	if response.Error != "" {
+		writeJSON(w, http.StatusServiceUnavailable, response)
+		return
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+// handleReadyz fails when a check of a supplier failed
+func (s *Server) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	response := s.CheckHealth(time.Now())
+	if !response.Ready {
+		writeJSON(w, http.StatusServiceUnavailable, response)
+		return
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+// CheckHealth checks every supplier against the `Health` limits
+func (s *Server) CheckHealth(now time.Time) HealthResponse {
+	response := HealthResponse{Ready: true, Suppliers: make([]SupplierHealthResponse, 0, len(s.Suppliers))}
+	lastUpdates := make(map[string]time.Time)
+	for _, stop := range s.Store.All() {
+		if stop.UpdatedAt.After(lastUpdates[stop.Supplier]) {
+			lastUpdates[stop.Supplier] = stop.UpdatedAt
+		}
+	}
+	// The state is read once for all the suppliers
+	var subscriptions map[string][]subscriptionstate.Record
+	if s.Subscriptions != nil {
+		records, err := s.Subscriptions.All()
+		if err != nil {
+			response.Error = err.Error()
+			response.Ready = false
+		} else {
+			subscriptions = make(map[string][]subscriptionstate.Record)
+			for _, record := range records {
+				subscriptions[record.Supplier] = append(subscriptions[record.Supplier], record)
+			}
+		}
+	}
+	for _, supplier := range s.Suppliers {
+		health := SupplierHealthResponse{Supplier: supplier.Name, Failures: make([]string, 0)}
+		s.checkStatus(&health, supplier, now)
+		s.checkStore(&health, supplier, lastUpdates[supplier.Name], now)
+		if subscriptions != nil {
+			s.checkSubscriptions(&health, supplier, subscriptions[supplier.SupplierAddress], now)
+		}
+		health.Ready = len(health.Failures) == 0
+		if !health.Ready {
+			response.Ready = false
+		}
+		response.Suppliers = append(response.Suppliers, health)
+	}
+	return response
+}
+
+func (s *Server) checkStatus(health *SupplierHealthResponse, supplier config.ConfigSupplier, now time.Time) {
+	// SIRI Lite has no CheckStatus service
+	if supplier.Protocol == config.PROTOCOL_SIRI_LITE {
+		return
+	}
+	var lastSuccessAt time.Time
+	if status, ok := s.Statuses.Get(supplier.Name); ok {
+		lastSuccessAt = status.LastSuccessAt
+	}
+	health.LastCheckStatusSuccessAt = optionalTime(lastSuccessAt)
+	if s.Health.CheckStatusMaxAge == 0 {
+		return
+	}
+	if lastSuccessAt.IsZero() {
+		health.Failures = append(health.Failures, "no successful CheckStatus")
+	} else if age := now.Sub(lastSuccessAt); age > s.Health.CheckStatusMaxAge {
+		health.Failures = append(health.Failures, fmt.Sprintf("no successful CheckStatus for %s", age.Round(time.Second)))
+	}
+}
+
+// checkStore is skipped for the suppliers without polled stop
+func (s *Server) checkStore(health *SupplierHealthResponse, supplier config.ConfigSupplier, lastUpdate time.Time, now time.Time) {
+	if len(supplier.MonitoringRefs) == 0 {
+		return
+	}
+	health.LastStoreUpdate = optionalTime(lastUpdate)
+	if !lastUpdate.IsZero() {
+		age := now.Sub(lastUpdate).Milliseconds()
+		health.StoreAgeMs = &age
+	}
+	if s.Health.StoreMaxAge == 0 {
+		return
+	}
+	if lastUpdate.IsZero() {
+		health.Failures = append(health.Failures, "no visits in the store")
+	} else if age := now.Sub(lastUpdate); age > s.Health.StoreMaxAge {
+		health.Failures = append(health.Failures, fmt.Sprintf("visits not updated for %s", age.Round(time.Second)))
+	}
+}
+
+// checkSubscriptions checks the records of the supplier, it is skipped when
+// the subscriptions aren't persisted. Without stop list, the active
+// subscriptions are the expected ones
+func (s *Server) checkSubscriptions(health *SupplierHealthResponse, supplier config.ConfigSupplier, records []subscriptionstate.Record, now time.Time) {
+	subscriptions := &SubscriptionsHealthResponse{}
+	var lastNotification time.Time
+	active := make(map[string]bool, len(records))
+	for _, record := range records {
+		if !record.Active(now) {
+			continue
+		}
+		active[record.SubscriptionRef] = true
+		if record.LastNotification.After(lastNotification) {
+			lastNotification = record.LastNotification
+		}
+	}
+	expected, ok := s.ExpectedSubscriptions[supplier.Name]
+	if ok {
+		subscriptions.Expected = len(expected)
+		for _, subscriptionRef := range expected {
+			if active[subscriptionRef] {
+				subscriptions.Active++
+			}
+		}
+	} else {
+		subscriptions.Active = len(active)
+		subscriptions.Expected = len(active)
+	}
+	subscriptions.LastNotification = optionalTime(lastNotification)
+	if !lastNotification.IsZero() {
+		since := now.Sub(lastNotification).Milliseconds()
+		subscriptions.SinceLastNotificationMs = &since
+	}
+	health.Subscriptions = subscriptions
+
+	if subscriptions.Active < subscriptions.Expected {
+		health.Failures = append(health.Failures, fmt.Sprintf("%d of %d subscriptions active", subscriptions.Active, subscriptions.Expected))
+	}
+	if s.Health.NotificationMaxAge == 0 || subscriptions.Active == 0 {
+		return
+	}
+	if lastNotification.IsZero() {
+		health.Failures = append(health.Failures, "no notification")
+	} else if since := now.Sub(lastNotification); since > s.Health.NotificationMaxAge {
+		health.Failures = append(health.Failures, fmt.Sprintf("no notification for %s", since.Round(time.Second)))
+	}
+}
+
+// ExpectedSubscriptionRefs returns by supplier name the StopMonitoring
+// subscriptions of its stop list, one per stop or one per stop and line with
+// its `LineRefs` like `reconcile.NewPlan`, the suppliers without stop list
+// are left out
+func ExpectedSubscriptionRefs(suppliers []config.ConfigSupplier) (map[string][]string, error) {
+	expected := make(map[string][]string)
+	for _, supplier := range suppliers {
+		if supplier.StopListFile == "" {
+			continue
+		}
+		stopPointIds, err := stoplist.ReadFile(supplier.StopListFile)
+		if err != nil {
+			return nil, err
+		}
+		lineRefs := []string{""}
+		if len(supplier.LineRefs) > 0 {
+			lineRefs = supplier.LineRefs
+		}
+		subscriptionRefs := make([]string, 0, len(stopPointIds)*len(lineRefs))
+		seen := make(map[string]bool, len(stopPointIds)*len(lineRefs))
+		for _, stopPointId := range stopPointIds {
+			for _, lineRef := range lineRefs {
+				subscriptionRef, err := subscribe.SubscriptionIdentifier(supplier.SubscriberRef, stopPointId, lineRef)
+				if err != nil {
+					return nil, err
+				}
+				if seen[subscriptionRef] {
+					continue
+				}
+				seen[subscriptionRef] = true
+				subscriptionRefs = append(subscriptionRefs, subscriptionRef)
+			}
+		}
+		expected[supplier.Name] = subscriptionRefs
+	}
+	return expected, nil
+}
@@ -0,0 +1,288 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/julienbt/siri-sm/internal/clockskew"
+	"github.com/julienbt/siri-sm/internal/config"
+	"github.com/julienbt/siri-sm/internal/getstopmonitoring"
+	"github.com/julienbt/siri-sm/internal/heartbeat"
+	"github.com/julienbt/siri-sm/internal/subscriptionstate"
+	"github.com/julienbt/siri-sm/internal/supplierstatus"
+	"github.com/julienbt/siri-sm/internal/visitstore"
+)
+
+// Server exposes the real-time departures as JSON:
+//
+//	GET /stops/{id}/departures?line=&limit=&window=
+//	GET /lines/{id}?limit=&window=
+//	GET /suppliers/{name}/status
+//	GET /suppliers/{name}/subscriptions
+//	GET /metrics
+//	GET /healthz
+//	GET /readyz
+//	POST /notifications
+//
+// `/healthz` and `/readyz` report the CheckStatus, the store and the
+// subscriptions of every supplier, `/readyz` fails when one of them is older
+// than the `Health` limits or when subscriptions are missing
+type Server struct {
+	Suppliers     []config.ConfigSupplier
+	Store         *visitstore.Store
+	Statuses      *supplierstatus.Store
+	Tracker       *heartbeat.Tracker       // optional
+	Skews         *clockskew.Tracker       // optional
+	Subscriptions *subscriptionstate.Store // optional
+	Metrics       http.Handler             // optional, e.g. `metrics.Handler()`
+	Notifications http.Handler             // optional, e.g. a `consumer.Handler` feeding the `Tracker`
+	Health        HealthLimits
+	// ExpectedSubscriptions are the subscription refs expected by supplier
+	// name, e.g. from `ExpectedSubscriptionRefs`
+	ExpectedSubscriptions map[string][]string
+}
+
+type LineResponse struct {
+	LineRef    string      `json:"line_ref"`
+	StopRefs   []string    `json:"stop_refs"`
+	Departures []Departure `json:"departures"`
+}
+
+type SupplierStatusResponse struct {
+	Supplier                   string             `json:"supplier"`
+	Available                  bool               `json:"available"`
+	SupplierServiceStartedTime *time.Time         `json:"supplier_service_started_time,omitempty"`
+	LastSupplierCheckStatusOk  *time.Time         `json:"last_supplier_check_status_ok,omitempty"`
+	LastCheckStatusAt          *time.Time         `json:"last_check_status_at,omitempty"`
+	LastError                  string             `json:"last_error,omitempty"`
+	Liveness                   *LivenessResponse  `json:"liveness,omitempty"`
+	ClockSkew                  *ClockSkewResponse `json:"clock_skew,omitempty"`
+}
+
+type LivenessResponse struct {
+	LastHeartbeat *time.Time `json:"last_heartbeat,omitempty"`
+	LastDelivery  *time.Time `json:"last_delivery,omitempty"`
+	Stale         bool       `json:"stale"`
+}
+
+// ClockSkewResponse is the last skew measured, positive when the clock of
+// the supplier is ahead of ours
+type ClockSkewResponse struct {
+	OffsetMs          int64     `json:"offset_ms"`
+	RoundTripMs       int64     `json:"round_trip_ms"`
+	ResponseTimestamp time.Time `json:"response_timestamp"`
+	MeasuredAt        time.Time `json:"measured_at"`
+}
+
+type SubscriptionResponse struct {
+	SubscriptionRef  string     `json:"subscription_ref"`
+	MonitoringRef    string     `json:"monitoring_ref,omitempty"`
+	LineRef          string     `json:"line_ref,omitempty"`
+	Status           string     `json:"status"`
+	Active           bool       `json:"active"` // accepted and not expired
+	Error            string     `json:"error,omitempty"`
+	ValidUntil       *time.Time `json:"valid_until,omitempty"`
+	LastNotification *time.Time `json:"last_notification,omitempty"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/stops/", s.handleStops)
+	mux.HandleFunc("/lines/", s.handleLines)
+	mux.HandleFunc("/suppliers/", s.handleSuppliers)
+	mux.HandleFunc("/healthz", s.handleHealthz)
+	mux.HandleFunc("/readyz", s.handleReadyz)
+	if s.Metrics != nil {
+		mux.Handle("/metrics", s.Metrics)
+	}
+	if s.Notifications != nil {
+		mux.Handle("/notifications", s.Notifications)
+	}
+	return mux
+}
+
+func (s *Server) handleStops(w http.ResponseWriter, r *http.Request) {
+	parts := splitPath(r.URL.Path)
+	if len(parts) != 3 || parts[2] != "departures" {
+		writeError(w, http.StatusNotFound, fmt.Errorf("unknown path: %s", r.URL.Path))
+		return
+	}
+	filter, err := parseDepartureFilter(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	stops := s.Store.Stop(getstopmonitoring.StopPointRef(parts[1]))
+	if len(stops) == 0 {
+		writeError(w, http.StatusNotFound, fmt.Errorf("unknown stop: %s", parts[1]))
+		return
+	}
+	writeJSON(w, http.StatusOK, BuildDepartures(stops, filter))
+}
+
+func (s *Server) handleLines(w http.ResponseWriter, r *http.Request) {
+	parts := splitPath(r.URL.Path)
+	if len(parts) != 2 {
+		writeError(w, http.StatusNotFound, fmt.Errorf("unknown path: %s", r.URL.Path))
+		return
+	}
+	filter, err := parseDepartureFilter(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	filter.LineRef = parts[1]
+	departures := BuildDepartures(s.Store.All(), filter)
+	if len(departures) == 0 {
+		writeError(w, http.StatusNotFound, fmt.Errorf("no departure for the line: %s", parts[1]))
+		return
+	}
+	stopRefsSet := make(map[string]bool)
+	for _, departure := range departures {
+		stopRefsSet[departure.StopRef] = true
+	}
+	stopRefs := make([]string, 0, len(stopRefsSet))
+	for stopRef := range stopRefsSet {
+		stopRefs = append(stopRefs, stopRef)
+	}
+	sort.Strings(stopRefs)
+	writeJSON(w, http.StatusOK, LineResponse{
+		LineRef:    parts[1],
+		StopRefs:   stopRefs,
+		Departures: departures,
+	})
+}
+
+func (s *Server) handleSuppliers(w http.ResponseWriter, r *http.Request) {
+	parts := splitPath(r.URL.Path)
+	if len(parts) != 3 || (parts[2] != "status" && parts[2] != "subscriptions") {
+		writeError(w, http.StatusNotFound, fmt.Errorf("unknown path: %s", r.URL.Path))
+		return
+	}
+	supplier, ok := s.findSupplier(parts[1])
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Errorf("unknown supplier: %s", parts[1]))
+		return
+	}
+	if parts[2] == "subscriptions" {
+		s.handleSubscriptions(w, supplier)
+		return
+	}
+	response := SupplierStatusResponse{Supplier: supplier.Name}
+	if status, ok := s.Statuses.Get(supplier.Name); ok {
+		response.Available = status.Available()
+		response.SupplierServiceStartedTime = optionalTime(status.CheckStatusResult.SupplierServiceStartedTime)
+		response.LastSupplierCheckStatusOk = optionalTime(status.CheckStatusResult.LastSupplierCheckStatusOk)
+		response.LastCheckStatusAt = optionalTime(status.LastCheckStatusAt)
+		response.LastError = status.LastError
+	}
+	if s.Tracker != nil {
+		if liveness, ok := s.Tracker.Liveness(supplier.ProducerRef, time.Now()); ok {
+			response.Liveness = &LivenessResponse{
+				LastHeartbeat: optionalTime(liveness.LastHeartbeat),
+				LastDelivery:  optionalTime(liveness.LastDelivery),
+				Stale:         liveness.Stale,
+			}
+		}
+	}
+	if s.Skews != nil {
+		if skew, ok := s.Skews.Get(supplier.SupplierAddress); ok {
+			response.ClockSkew = &ClockSkewResponse{
+				OffsetMs:          skew.Offset.Milliseconds(),
+				RoundTripMs:       skew.RoundTrip.Milliseconds(),
+				ResponseTimestamp: skew.ResponseTimestamp,
+				MeasuredAt:        skew.MeasuredAt,
+			}
+		}
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+func (s *Server) handleSubscriptions(w http.ResponseWriter, supplier config.ConfigSupplier) {
+	if s.Subscriptions == nil {
+		writeError(w, http.StatusNotFound, fmt.Errorf("the subscriptions aren't persisted"))
+		return
+	}
+	records, err := s.Subscriptions.Supplier(supplier.SupplierAddress)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	now := time.Now()
+	response := make([]SubscriptionResponse, 0, len(records))
+	for _, record := range records {
+		response = append(response, SubscriptionResponse{
+			SubscriptionRef:  record.SubscriptionRef,
+			MonitoringRef:    record.MonitoringRef,
+			LineRef:          record.LineRef,
+			Status:           record.Status,
+			Active:           record.Active(now),
+			Error:            record.Error,
+			ValidUntil:       optionalTime(record.ValidUntil),
+			LastNotification: optionalTime(record.LastNotification),
+		})
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+func (s *Server) findSupplier(name string) (config.ConfigSupplier, bool) {
+	for _, supplier := range s.Suppliers {
+		if supplier.Name == name {
+			return supplier, true
+		}
+	}
+	return config.ConfigSupplier{}, false
+}
+
+func parseDepartureFilter(r *http.Request) (DepartureFilter, error) {
+	query := r.URL.Query()
+	filter := DepartureFilter{
+		LineRef: query.Get("line"),
+		From:    time.Now(),
+	}
+	if limit := query.Get("limit"); limit != "" {
+		value, err := strconv.Atoi(limit)
+		if err != nil || value < 0 {
+			return DepartureFilter{}, fmt.Errorf("invalid `limit` parameter: %s", limit)
+		}
+		filter.Limit = value
+	}
+	if window := query.Get("window"); window != "" {
+		value, err := time.ParseDuration(window)
+		if err != nil || value < 0 {
+			return DepartureFilter{}, fmt.Errorf("invalid `window` parameter: %s", window)
+		}
+		filter.Window = value
+	}
+	return filter, nil
+}
+
+func splitPath(path string) []string {
+	return strings.Split(strings.Trim(path, "/"), "/")
+}
+
+func optionalTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+func writeJSON(w http.ResponseWriter, statusCode int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+func writeError(w http.ResponseWriter, statusCode int, err error) {
+	writeJSON(w, statusCode, errorResponse{Error: err.Error()})
+}
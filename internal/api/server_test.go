@@ -0,0 +1,245 @@
+package api
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+
+	"github.com/julienbt/siri-sm/internal/checkstatus"
+	"github.com/julienbt/siri-sm/internal/clockskew"
+	siri_time "github.com/julienbt/siri-sm/internal/common/time"
+	"github.com/julienbt/siri-sm/internal/config"
+	"github.com/julienbt/siri-sm/internal/getstopmonitoring"
+	"github.com/julienbt/siri-sm/internal/subscriptionstate"
+	"github.com/julienbt/siri-sm/internal/supplierstatus"
+	"github.com/julienbt/siri-sm/internal/visitstore"
+)
+
+func newVisit(lineRef getstopmonitoring.LineRef, departure time.Time) getstopmonitoring.MonitoredStopVisit {
+	visit := getstopmonitoring.MonitoredStopVisit{}
+	visit.MonitoredVehicleJourney.LineRef = lineRef
+	visit.MonitoredVehicleJourney.MonitoredCall.AimedDepartureTime = siri_time.Time(departure)
+	visit.MonitoredVehicleJourney.MonitoredCall.ExpectedDepartureTime = siri_time.Time(departure.Add(time.Minute))
+	return visit
+}
+
+func newTestServer() *Server {
+	now := time.Now()
+	store := visitstore.NewStore()
+	store.Update("lille", "CAS001", []getstopmonitoring.MonitoredStopVisit{
+		newVisit("L2", now.Add(20*time.Minute)),
+		newVisit("L1", now.Add(10*time.Minute)),
+		newVisit("L1", now.Add(3*time.Hour)),
+	}, now)
+	store.Update("lille", "CAS002", []getstopmonitoring.MonitoredStopVisit{
+		newVisit("L1", now.Add(15*time.Minute)),
+	}, now)
+	statuses := supplierstatus.NewStore()
+	statuses.Update("lille", checkstatus.CheckStatusResult{LastSupplierCheckStatusOk: now}, nil, now)
+	skews := clockskew.NewTracker(0, false)
+	skews.Record(logrus.NewEntry(logrus.New()), "http://lille", now, now, now.Add(-90*time.Second))
+	return &Server{
+		Suppliers: []config.ConfigSupplier{{Name: "lille", SupplierAddress: "http://lille"}, {Name: "amiens"}},
+		Store:     store,
+		Statuses:  statuses,
+		Skews:     skews,
+	}
+}
+
+func get(t *testing.T, server *Server, target string, body interface{}) int {
+	recorder := httptest.NewRecorder()
+	server.Handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, target, nil))
+	require.Nil(t, json.Unmarshal(recorder.Body.Bytes(), body))
+	return recorder.Code
+}
+
+func TestStopDepartures(t *testing.T) {
+	require := require.New(t)
+	server := newTestServer()
+
+	departures := []Departure{}
+	require.Equal(http.StatusOK, get(t, server, "/stops/CAS001/departures", &departures))
+	require.Len(departures, 3)
+	require.Equal("L1", departures[0].LineRef)
+	require.Equal(int64(60), departures[0].DelaySeconds)
+
+	require.Equal(http.StatusOK, get(t, server, "/stops/CAS001/departures?line=L1&window=1h", &departures))
+	require.Len(departures, 1)
+
+	require.Equal(http.StatusOK, get(t, server, "/stops/CAS001/departures?limit=2", &departures))
+	require.Len(departures, 2)
+
+	errResp := errorResponse{}
+	require.Equal(http.StatusBadRequest, get(t, server, "/stops/CAS001/departures?limit=abc", &errResp))
+	require.Equal(http.StatusNotFound, get(t, server, "/stops/UNKNOWN/departures", &errResp))
+}
+
+func TestLine(t *testing.T) {
+	require := require.New(t)
+	server := newTestServer()
+
+	line := LineResponse{}
+	require.Equal(http.StatusOK, get(t, server, "/lines/L1?window=1h", &line))
+	require.Equal([]string{"CAS001", "CAS002"}, line.StopRefs)
+	require.Len(line.Departures, 2)
+}
+
+func TestSupplierStatus(t *testing.T) {
+	require := require.New(t)
+	server := newTestServer()
+
+	status := SupplierStatusResponse{}
+	require.Equal(http.StatusOK, get(t, server, "/suppliers/lille/status", &status))
+	require.True(status.Available)
+	require.NotNil(status.LastSupplierCheckStatusOk)
+	require.NotNil(status.ClockSkew)
+	require.Equal(int64(-90000), status.ClockSkew.OffsetMs)
+
+	status = SupplierStatusResponse{}
+	require.Equal(http.StatusOK, get(t, server, "/suppliers/amiens/status", &status))
+	require.False(status.Available)
+	require.Nil(status.ClockSkew)
+
+	errResp := errorResponse{}
+	require.Equal(http.StatusNotFound, get(t, server, "/suppliers/unknown/status", &errResp))
+}
+
+func TestSupplierSubscriptions(t *testing.T) {
+	require := require.New(t)
+	server := newTestServer()
+	errResp := errorResponse{}
+	require.Equal(http.StatusNotFound, get(t, server, "/suppliers/lille/subscriptions", &errResp))
+
+	state, err := subscriptionstate.Open(filepath.Join(t.TempDir(), "subscriptions.db"))
+	require.Nil(err)
+	now := time.Now()
+	require.Nil(state.Put(
+		subscriptionstate.Record{
+			Supplier:        "http://lille",
+			SubscriptionRef: "KISIO2:Subscription:arret_CAS001:LOC",
+			Status:          subscriptionstate.STATUS_ACTIVE,
+			ValidUntil:      now.Add(time.Hour),
+		},
+		subscriptionstate.Record{
+			Supplier:        "http://lille",
+			SubscriptionRef: "KISIO2:Subscription:arret_CAS002:LOC",
+			Status:          subscriptionstate.STATUS_ACTIVE,
+			ValidUntil:      now.Add(-time.Hour),
+		},
+	))
+	server.Subscriptions = state
+
+	subscriptions := []SubscriptionResponse{}
+	require.Equal(http.StatusOK, get(t, server, "/suppliers/lille/subscriptions", &subscriptions))
+	require.Len(subscriptions, 2)
+	require.True(subscriptions[0].Active)
+	require.False(subscriptions[1].Active)
+
+	subscriptions = []SubscriptionResponse{}
+	require.Equal(http.StatusOK, get(t, server, "/suppliers/amiens/subscriptions", &subscriptions))
+	require.Empty(subscriptions)
+}
+
+func TestHealth(t *testing.T) {
+	require := require.New(t)
+	server := newTestServer()
+	server.Suppliers[0].MonitoringRefs = []string{"ILEVIA:StopPoint:BP:CAS001:LOC"}
+	server.Health = HealthLimits{CheckStatusMaxAge: time.Minute, StoreMaxAge: time.Minute}
+
+	// amiens was never checked
+	health := HealthResponse{}
+	require.Equal(http.StatusOK, get(t, server, "/healthz", &health))
+	require.False(health.Ready)
+	require.Equal(http.StatusServiceUnavailable, get(t, server, "/readyz", &health))
+	require.Len(health.Suppliers, 2)
+	require.True(health.Suppliers[0].Ready)
+	require.NotNil(health.Suppliers[0].LastCheckStatusSuccessAt)
+	require.NotNil(health.Suppliers[0].StoreAgeMs)
+	require.Equal([]string{"no successful CheckStatus"}, health.Suppliers[1].Failures)
+
+	server.Suppliers = server.Suppliers[:1]
+	health = HealthResponse{}
+	require.Equal(http.StatusOK, get(t, server, "/readyz", &health))
+	require.True(health.Ready)
+
+	state, err := subscriptionstate.Open(filepath.Join(t.TempDir(), "subscriptions.db"))
+	require.Nil(err)
+	now := time.Now()
+	require.Nil(state.Put(
+		subscriptionstate.Record{
+			Supplier:         "http://lille",
+			SubscriptionRef:  "KISIO2:Subscription:arret_CAS001:LOC",
+			Status:           subscriptionstate.STATUS_ACTIVE,
+			ValidUntil:       now.Add(time.Hour),
+			LastNotification: now.Add(-10 * time.Minute),
+		},
+		subscriptionstate.Record{
+			Supplier:        "http://lille",
+			SubscriptionRef: "KISIO2:Subscription:arret_CAS002:LOC",
+			Status:          subscriptionstate.STATUS_TERMINATED,
+		},
+		subscriptionstate.Record{
+			Supplier:        "http://lille",
+			SubscriptionRef: "KISIO2:Subscription:arret_CAS003:LOC",
+			Status:          subscriptionstate.STATUS_REJECTED,
+		},
+		subscriptionstate.Record{
+			Supplier:        "http://lille",
+			SubscriptionRef: "KISIO2:Subscription:arret_CAS004:LOC",
+			Status:          subscriptionstate.STATUS_ACTIVE,
+			ValidUntil:      now.Add(-time.Minute),
+		},
+	))
+	server.Subscriptions = state
+	server.Health.NotificationMaxAge = 5 * time.Minute
+
+	// Without stop list, the active subscriptions are the expected ones
+	require.Equal(http.StatusServiceUnavailable, get(t, server, "/readyz", &health))
+	require.Equal(1, health.Suppliers[0].Subscriptions.Active)
+	require.Equal(1, health.Suppliers[0].Subscriptions.Expected)
+	require.Equal([]string{"no notification for 10m0s"}, health.Suppliers[0].Failures)
+
+	// The notifications are recorded by the consumer
+	unknown, err := state.RecordNotifications("http://lille", []string{"KISIO2:Subscription:arret_CAS001:LOC"}, now)
+	require.Nil(err)
+	require.Empty(unknown)
+	health = HealthResponse{}
+	require.Equal(http.StatusOK, get(t, server, "/readyz", &health))
+	require.True(health.Ready)
+
+	// The rejected and expired subscriptions of the stop list are missing
+	stopListFile := filepath.Join(t.TempDir(), "stops.txt")
+	require.Nil(ioutil.WriteFile(stopListFile, []byte("CAS001\nCAS003 # rejected\nCAS004 # expired\n"), 0644))
+	server.Suppliers[0].SubscriberRef = "KISIO2"
+	server.Suppliers[0].StopListFile = stopListFile
+	server.ExpectedSubscriptions, err = ExpectedSubscriptionRefs(server.Suppliers)
+	require.Nil(err)
+	health = HealthResponse{}
+	require.Equal(http.StatusServiceUnavailable, get(t, server, "/readyz", &health))
+	require.Equal(1, health.Suppliers[0].Subscriptions.Active)
+	require.Equal(3, health.Suppliers[0].Subscriptions.Expected)
+	require.Equal([]string{"1 of 3 subscriptions active"}, health.Suppliers[0].Failures)
+
+	// The subscription expires without renewal
+	require.Nil(ioutil.WriteFile(stopListFile, []byte("CAS001\n"), 0644))
+	server.ExpectedSubscriptions, err = ExpectedSubscriptionRefs(server.Suppliers)
+	require.Nil(err)
+	health = HealthResponse{}
+	require.Equal(http.StatusOK, get(t, server, "/readyz", &health))
+	health = server.CheckHealth(now.Add(2 * time.Hour))
+	require.False(health.Ready)
+	require.Equal(0, health.Suppliers[0].Subscriptions.Active)
+	require.Equal(1, health.Suppliers[0].Subscriptions.Expected)
+	require.Equal([]string{
+		"no successful CheckStatus for 2h0m0s",
+		"visits not updated for 2h0m0s",
+		"0 of 1 subscriptions active",
+	}, health.Suppliers[0].Failures)
+}
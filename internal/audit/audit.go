@@ -0,0 +1,136 @@
+// Package audit logs every exchange with the suppliers as structured fields,
+// and stores their bodies in an optional sink, with the refs and addresses
+// redacted, instead of printing them
+package audit
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/julienbt/siri-sm/internal/config"
+	"github.com/julienbt/siri-sm/internal/siri"
+)
+
+// Transport logs the requests sent through `Next`, `http.DefaultTransport`
+// when nil, and writes them to `Sink` when set
+type Transport struct {
+	Next   http.RoundTripper
+	Logger *logrus.Entry
+	// Suppliers names the suppliers by address, the host of the request is
+	// the supplier field of the other addresses
+	Suppliers map[string]string
+	Sink      *Sink
+	// Now is the clock of the transport, `time.Now` by default
+	Now func() time.Time
+}
+
+// Install logs the exchanges of `siri.SoapCall`, after the `recording`
+// package installed its own transport
+func Install(logger *logrus.Entry, cfg config.ConfigAudit, suppliers map[string]string) error {
+	transport := &Transport{Next: siri.Transport, Logger: logger, Suppliers: suppliers}
+	if cfg.AuditFile != "" {
+		sink, err := OpenSink(cfg.AuditFile, NewRedactor(cfg.AuditRedact), cfg.AuditMaxBodySize)
+		if err != nil {
+			return err
+		}
+		transport.Sink = sink
+	}
+	siri.Transport = transport
+	return nil
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	next := t.Next
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	now := t.Now
+	if now == nil {
+		now = time.Now
+	}
+	reqBody, err := siri.ReadRequestBody(req)
+	if err != nil {
+		return nil, err
+	}
+	entry := Entry{
+		Time:              now(),
+		Supplier:          siri.SupplierOf(req, t.Suppliers),
+		Action:            siri.ActionOf(req, reqBody),
+		MessageIdentifier: messageIdentifierOf(req, reqBody),
+		Method:            req.Method,
+		Url:               req.URL.Redacted(),
+	}
+	if t.Sink != nil {
+		entry.Url = t.Sink.Redactor.Url(req.URL)
+	}
+
+	resp, err := next.RoundTrip(req)
+	var respBody []byte
+	if err == nil {
+		respBody, err = ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		resp.Body = ioutil.NopCloser(bytes.NewReader(respBody))
+		entry.StatusCode = resp.StatusCode
+	}
+	entry.Latency = now().Sub(entry.Time)
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	t.log(entry, len(reqBody), len(respBody))
+	if t.Sink != nil {
+		if sinkErr := t.Sink.Write(entry, reqBody, respBody); sinkErr != nil {
+			t.logger().Warn(sinkErr)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (t *Transport) logger() *logrus.Entry {
+	if t.Logger == nil {
+		return logrus.NewEntry(logrus.StandardLogger())
+	}
+	return t.Logger
+}
+
+func (t *Transport) log(entry Entry, requestSize int, responseSize int) {
+	logger := t.logger().WithFields(logrus.Fields{
+		"supplier":          entry.Supplier,
+		"action":            entry.Action,
+		"messageIdentifier": entry.MessageIdentifier,
+		"latencyMs":         entry.Latency.Milliseconds(),
+		"requestSize":       requestSize,
+	})
+	if entry.Error != "" {
+		logger.WithField("error", entry.Error).Warn("SIRI exchange failed")
+		return
+	}
+	logger = logger.WithFields(logrus.Fields{
+		"status":       entry.StatusCode,
+		"responseSize": responseSize,
+	})
+	if entry.StatusCode >= http.StatusBadRequest {
+		logger.Warn("SIRI exchange")
+		return
+	}
+	logger.Info("SIRI exchange")
+}
+
+var messageIdentifierPattern = regexp.MustCompile(`<(?:[\w.-]+:)?MessageIdentifier(?:\s[^>]*)?>([^<]*)<`)
+
+// messageIdentifierOf returns the first `MessageIdentifier` of the request,
+// in its body or in its query (SIRI Lite)
+func messageIdentifierOf(req *http.Request, body []byte) string {
+	if match := messageIdentifierPattern.FindSubmatch(body); match != nil {
+		return strings.TrimSpace(string(match[1]))
+	}
+	return req.URL.Query().Get("MessageIdentifier")
+}
@@ -0,0 +1,76 @@
+package audit
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// REDACTED replaces the masked values
+const REDACTED string = "REDACTED"
+
+// Redactor masks the values of some elements (XML), keys (JSON) and query
+// parameters, e.g. `RequestorRef` or `ConsumerAddress`
+type Redactor struct {
+	names       map[string]bool
+	xmlElements *regexp.Regexp
+	jsonKeys    *regexp.Regexp
+}
+
+// NewRedactor masks the values of `names`, compared with the local name of
+// the elements, nothing is masked without names
+func NewRedactor(names []string) *Redactor {
+	r := &Redactor{names: make(map[string]bool, len(names))}
+	quoted := make([]string, 0, len(names))
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		r.names[name] = true
+		quoted = append(quoted, regexp.QuoteMeta(name))
+	}
+	if len(quoted) == 0 {
+		return r
+	}
+	alternatives := strings.Join(quoted, "|")
+	// Only the text of the leaf elements is masked
+	r.xmlElements = regexp.MustCompile(`(<(?:[\w.-]+:)?(?:` + alternatives + `)(?:\s[^>]*)?>)[^<]*(</)`)
+	r.jsonKeys = regexp.MustCompile(`("(?:` + alternatives + `)"\s*:\s*)"(?:[^"\\]|\\.)*"`)
+	return r
+}
+
+// Body returns a copy of the body with the values masked
+func (r *Redactor) Body(body []byte) []byte {
+	if r.xmlElements == nil {
+		return body
+	}
+	body = r.xmlElements.ReplaceAll(body, []byte("${1}"+REDACTED+"${2}"))
+	return r.jsonKeys.ReplaceAll(body, []byte(`${1}"`+REDACTED+`"`))
+}
+
+// Url returns the address without password and with the query parameters
+// masked
+func (r *Redactor) Url(u *url.URL) string {
+	masked := *u
+	query := masked.Query()
+	changed := false
+	for name := range query {
+		if r.names[name] {
+			query.Set(name, REDACTED)
+			changed = true
+		}
+	}
+	if changed {
+		masked.RawQuery = query.Encode()
+	}
+	return masked.Redacted()
+}
+
+// Truncate cuts the body beyond `maxSize` bytes, never when `maxSize` is 0
+func Truncate(body []byte, maxSize int) ([]byte, bool) {
+	if maxSize <= 0 || len(body) <= maxSize {
+		return body, false
+	}
+	return body[:maxSize], true
+}
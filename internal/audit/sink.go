@@ -0,0 +1,79 @@
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// Entry is an exchange stored by the sink, as a line of JSON
+type Entry struct {
+	Time              time.Time     `json:"time"`
+	Supplier          string        `json:"supplier"`
+	Action            string        `json:"action"`
+	MessageIdentifier string        `json:"message_identifier,omitempty"`
+	Method            string        `json:"method"`
+	Url               string        `json:"url"`
+	Latency           time.Duration `json:"latency"`
+	StatusCode        int           `json:"status_code,omitempty"`
+	Error             string        `json:"error,omitempty"` // transport error, without response
+	RequestSize       int           `json:"request_size"`    // before truncation
+	RequestBody       string        `json:"request_body,omitempty"`
+	RequestTruncated  bool          `json:"request_truncated,omitempty"`
+	ResponseSize      int           `json:"response_size"` // before truncation
+	ResponseBody      string        `json:"response_body,omitempty"`
+	ResponseTruncated bool          `json:"response_truncated,omitempty"`
+}
+
+// Sink appends the exchanges to a file, their bodies redacted and truncated
+type Sink struct {
+	Redactor    *Redactor
+	MaxBodySize int // in bytes, the bodies aren't truncated with 0
+
+	mu sync.Mutex
+	w  io.WriteCloser
+}
+
+// OpenSink appends to the file, created when missing
+func OpenSink(path string, redactor *Redactor, maxBodySize int) (*Sink, error) {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("error opening the audit file: %s", err)
+	}
+	return NewSink(f, redactor, maxBodySize), nil
+}
+
+func NewSink(w io.WriteCloser, redactor *Redactor, maxBodySize int) *Sink {
+	return &Sink{Redactor: redactor, MaxBodySize: maxBodySize, w: w}
+}
+
+// Write stores the entry with the bodies of the exchange, the sizes of the
+// entry are the ones of the bodies
+func (s *Sink) Write(entry Entry, reqBody []byte, respBody []byte) error {
+	var body []byte
+	entry.RequestSize = len(reqBody)
+	body, entry.RequestTruncated = Truncate(s.Redactor.Body(reqBody), s.MaxBodySize)
+	entry.RequestBody = string(body)
+	entry.ResponseSize = len(respBody)
+	body, entry.ResponseTruncated = Truncate(s.Redactor.Body(respBody), s.MaxBodySize)
+	entry.ResponseBody = string(body)
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err = s.w.Write(append(line, '\n'))
+	if err != nil {
+		return fmt.Errorf("error writing the audit file: %s", err)
+	}
+	return nil
+}
+
+func (s *Sink) Close() error {
+	return s.w.Close()
+}
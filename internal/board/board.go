@@ -0,0 +1,150 @@
+// Package board renders the visits of a stop as a departure board, grouped
+// by line and destination, for a quick look at what a supplier announces
+package board
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/julienbt/siri-sm/internal/getstopmonitoring"
+)
+
+const TIME_LAYOUT string = "15:04"
+
+type Departure struct {
+	AimedDepartureTime    time.Time
+	ExpectedDepartureTime time.Time
+	Cancelled             bool
+}
+
+// DepartureTime is the expected departure time, or the aimed one when unknown
+func (d *Departure) DepartureTime() time.Time {
+	if !d.ExpectedDepartureTime.IsZero() {
+		return d.ExpectedDepartureTime
+	}
+	return d.AimedDepartureTime
+}
+
+// Delay is only known when both the aimed and the expected times are
+func (d *Departure) Delay() (time.Duration, bool) {
+	if d.AimedDepartureTime.IsZero() || d.ExpectedDepartureTime.IsZero() {
+		return 0, false
+	}
+	return d.ExpectedDepartureTime.Sub(d.AimedDepartureTime), true
+}
+
+// Group are the next departures of a line towards a destination
+type Group struct {
+	LineRef         string
+	DestinationName string
+	Departures      []Departure
+}
+
+type Board struct {
+	StopRef   string
+	UpdatedAt time.Time
+	Groups    []Group
+}
+
+// Build groups the visits not yet departed at `now`, keeping the `limit`
+// next departures of each group (all of them when zero)
+func Build(stopRef string, visits []getstopmonitoring.MonitoredStopVisit, now time.Time, limit int) Board {
+	type groupKey struct {
+		lineRef         string
+		destinationName string
+	}
+	groups := make(map[groupKey]*Group)
+	for i := range visits {
+		journey := &visits[i].MonitoredVehicleJourney
+		departure := Departure{
+			AimedDepartureTime:    time.Time(journey.MonitoredCall.AimedDepartureTime),
+			ExpectedDepartureTime: time.Time(journey.MonitoredCall.ExpectedDepartureTime),
+			Cancelled:             journey.IsCancelled(),
+		}
+		if departure.DepartureTime().Before(now) {
+			continue
+		}
+		key := groupKey{lineRef: string(journey.LineRef), destinationName: journey.DestinationName}
+		group, ok := groups[key]
+		if !ok {
+			group = &Group{LineRef: key.lineRef, DestinationName: key.destinationName}
+			groups[key] = group
+		}
+		group.Departures = append(group.Departures, departure)
+	}
+
+	board := Board{StopRef: stopRef, UpdatedAt: now, Groups: make([]Group, 0, len(groups))}
+	for _, group := range groups {
+		sort.SliceStable(group.Departures, func(i, j int) bool {
+			return group.Departures[i].DepartureTime().Before(group.Departures[j].DepartureTime())
+		})
+		if limit > 0 && len(group.Departures) > limit {
+			group.Departures = group.Departures[:limit]
+		}
+		board.Groups = append(board.Groups, *group)
+	}
+	sort.Slice(board.Groups, func(i, j int) bool {
+		if board.Groups[i].LineRef != board.Groups[j].LineRef {
+			return board.Groups[i].LineRef < board.Groups[j].LineRef
+		}
+		return board.Groups[i].DestinationName < board.Groups[j].DestinationName
+	})
+	return board
+}
+
+// Render writes the board, the times are shown in `location`
+func (b *Board) Render(w io.Writer, location *time.Location) error {
+	fmt.Fprintf(w, "%s  updated at %s\n\n", b.StopRef, b.UpdatedAt.In(location).Format("15:04:05"))
+	if len(b.Groups) == 0 {
+		_, err := fmt.Fprintln(w, "no departure")
+		return err
+	}
+	writer := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "LINE\tDESTINATION\tAIMED\tEXPECTED\tDELAY")
+	for _, group := range b.Groups {
+		for i, departure := range group.Departures {
+			lineRef, destinationName := group.LineRef, group.DestinationName
+			if i > 0 {
+				lineRef, destinationName = "", ""
+			}
+			fmt.Fprintf(
+				writer,
+				"%s\t%s\t%s\t%s\t%s\n",
+				lineRef,
+				destinationName,
+				formatTime(departure.AimedDepartureTime, location),
+				formatTime(departure.ExpectedDepartureTime, location),
+				formatStatus(&departure),
+			)
+		}
+	}
+	return writer.Flush()
+}
+
+func formatTime(t time.Time, location *time.Location) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.In(location).Format(TIME_LAYOUT)
+}
+
+func formatStatus(departure *Departure) string {
+	if departure.Cancelled {
+		return "cancelled"
+	}
+	delay, ok := departure.Delay()
+	if !ok {
+		return "-"
+	}
+	minutes := int(delay.Round(time.Minute) / time.Minute)
+	switch {
+	case minutes == 0:
+		return "on time"
+	case minutes > 0:
+		return fmt.Sprintf("+%d min", minutes)
+	}
+	return fmt.Sprintf("%d min", minutes)
+}
@@ -0,0 +1,79 @@
+package board
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	siri_time "github.com/julienbt/siri-sm/internal/common/time"
+	"github.com/julienbt/siri-sm/internal/getstopmonitoring"
+)
+
+func newVisit(lineRef string, destinationName string, aimed time.Time, delay time.Duration, status string) getstopmonitoring.MonitoredStopVisit {
+	visit := getstopmonitoring.MonitoredStopVisit{MonitoringRef: "CAS001"}
+	journey := &visit.MonitoredVehicleJourney
+	journey.LineRef = getstopmonitoring.LineRef(lineRef)
+	journey.DestinationName = destinationName
+	journey.MonitoredCall.AimedDepartureTime = siri_time.Time(aimed)
+	journey.MonitoredCall.ExpectedDepartureTime = siri_time.Time(aimed.Add(delay))
+	journey.MonitoredCall.DepartureStatus = status
+	return visit
+}
+
+func TestBuild(t *testing.T) {
+	require := require.New(t)
+	now := time.Date(2022, 8, 30, 8, 0, 0, 0, time.UTC)
+	visits := []getstopmonitoring.MonitoredStopVisit{
+		newVisit("L2", "Gare", now.Add(20*time.Minute), 0, "onTime"),
+		newVisit("L1", "Terminus", now.Add(15*time.Minute), 2*time.Minute, "delayed"),
+		newVisit("L1", "Terminus", now.Add(5*time.Minute), 0, "onTime"),
+		newVisit("L1", "Terminus", now.Add(25*time.Minute), 0, "onTime"),
+		newVisit("L1", "Gare", now.Add(10*time.Minute), 0, getstopmonitoring.CALL_STATUS_CANCELLED),
+		newVisit("L1", "Terminus", now.Add(-5*time.Minute), time.Minute, "delayed"),
+	}
+
+	board := Build("CAS001", visits, now, 2)
+	require.Len(board.Groups, 3)
+	require.Equal("L1", board.Groups[0].LineRef)
+	require.Equal("Gare", board.Groups[0].DestinationName)
+	require.True(board.Groups[0].Departures[0].Cancelled)
+	require.Equal("Terminus", board.Groups[1].DestinationName)
+	require.Len(board.Groups[1].Departures, 2)
+	require.Equal(now.Add(5*time.Minute), board.Groups[1].Departures[0].DepartureTime())
+	delay, ok := board.Groups[1].Departures[1].Delay()
+	require.True(ok)
+	require.Equal(2*time.Minute, delay)
+	require.Equal("L2", board.Groups[2].LineRef)
+
+	board = Build("CAS001", visits, now, 0)
+	require.Len(board.Groups[1].Departures, 3)
+}
+
+func TestRender(t *testing.T) {
+	require := require.New(t)
+	location, err := time.LoadLocation("Europe/Paris")
+	require.Nil(err)
+	now := time.Date(2022, 8, 30, 8, 0, 0, 0, time.UTC)
+	board := Build("CAS001", []getstopmonitoring.MonitoredStopVisit{
+		newVisit("L1", "Terminus", now.Add(5*time.Minute), 0, "onTime"),
+		newVisit("L1", "Terminus", now.Add(15*time.Minute), 2*time.Minute, "delayed"),
+		newVisit("L2", "Gare", now.Add(10*time.Minute), 0, getstopmonitoring.CALL_STATUS_CANCELLED),
+	}, now, 0)
+
+	var buf bytes.Buffer
+	require.Nil(board.Render(&buf, location))
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Equal([]string{"CAS001", "updated", "at", "10:00:00"}, strings.Fields(lines[0]))
+	require.Equal([]string{"LINE", "DESTINATION", "AIMED", "EXPECTED", "DELAY"}, strings.Fields(lines[2]))
+	require.Equal([]string{"L1", "Terminus", "10:05", "10:05", "on", "time"}, strings.Fields(lines[3]))
+	require.Equal([]string{"10:15", "10:17", "+2", "min"}, strings.Fields(lines[4]))
+	require.Equal([]string{"L2", "Gare", "10:10", "10:10", "cancelled"}, strings.Fields(lines[5]))
+
+	buf.Reset()
+	empty := Build("CAS002", nil, now, 0)
+	require.Nil(empty.Render(&buf, location))
+	require.Contains(buf.String(), "no departure")
+}
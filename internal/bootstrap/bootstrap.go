@@ -0,0 +1,40 @@
+// Package bootstrap installs what every command needs before exchanging with
+// the suppliers, from the `SIRISM_*` variables
+package bootstrap
+
+import (
+	"github.com/sirupsen/logrus"
+
+	"github.com/julienbt/siri-sm/internal/audit"
+	"github.com/julienbt/siri-sm/internal/clockskew"
+	"github.com/julienbt/siri-sm/internal/config"
+	"github.com/julienbt/siri-sm/internal/recording"
+)
+
+// InstallTransports records or replays the exchanges, then logs and audits
+// them, and tracks the clock skew of the suppliers. `suppliers` names the
+// suppliers by address, nil for the commands requesting a single one
+func InstallTransports(logger *logrus.Entry, suppliers map[string]string) error {
+	recordingCfg, err := config.LoadRecording("SIRISM")
+	if err != nil {
+		return err
+	}
+	err = recording.Install(recordingCfg, suppliers)
+	if err != nil {
+		return err
+	}
+	auditCfg, err := config.LoadAudit("SIRISM")
+	if err != nil {
+		return err
+	}
+	err = audit.Install(logger, auditCfg, suppliers)
+	if err != nil {
+		return err
+	}
+	clockSkewCfg, err := config.LoadClockSkew("SIRISM")
+	if err != nil {
+		return err
+	}
+	clockskew.Install(clockSkewCfg)
+	return nil
+}
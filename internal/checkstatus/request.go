@@ -2,24 +2,24 @@ package checkstatus
 
 import (
 	"bytes"
-	"encoding/xml"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/url"
-	"strings"
 	"text/template"
 	"time"
 
+	"github.com/julienbt/siri-sm/internal/clockskew"
 	"github.com/julienbt/siri-sm/internal/config"
+	"github.com/julienbt/siri-sm/internal/messageid"
 	"github.com/julienbt/siri-sm/internal/siri"
 	"github.com/sirupsen/logrus"
 )
 
-const IDENTIFIER_TIME_LAYOUT string = "20060102_150405"
-
 type CheckStatusRequest struct {
 	SupplierAddress   url.URL
+	Binding           siri.Binding
+	Location          *time.Location // of the supplier
 	RequestTimestamp  time.Time
 	RequestorRef      string
 	MessageIdentifier string
@@ -44,15 +44,16 @@ func CheckStatus(
 			nil,
 			fmt.Errorf("error CheckStatus request initialization: %v", err)
 	}
-	httpReq, htmlReqBody, err := req.generateHttpSoapReq()
+	httpReq, htmlReqBody, err := req.generateHttpReq()
 	if err != nil {
 		return CheckStatusResult{},
 			"",
 			nil,
-			fmt.Errorf("error in building SOAP CheckStatus request: %s", err)
+			fmt.Errorf("error in building CheckStatus request: %s", err)
 	}
 
 	// Send HTTP request and receive the response
+	sentAt := time.Now()
 	resp, err := siri.SoapCall(httpReq)
 	if err != nil {
 		return CheckStatusResult{},
@@ -70,6 +71,7 @@ func CheckStatus(
 			nil,
 			&siri.RemoteError{Loc: remoteErrorLoc, Err: fmt.Errorf("unreadable response body: %s", err)}
 	}
+	receivedAt := time.Now()
 
 	if resp.StatusCode < 200 || resp.StatusCode > 299 {
 		return CheckStatusResult{},
@@ -79,21 +81,22 @@ func CheckStatus(
 	}
 
 	// Parse the succesfull HTTP Response
-	checkStatusResponse := &CheckStatusResponseEnv{}
-	err = xml.Unmarshal(htmlRespBody, &checkStatusResponse)
+	checkStatusAnswer, err := decodeCheckStatusAnswer(req.Binding, htmlRespBody, req.Location)
 	if err != nil {
 		return CheckStatusResult{},
 			htmlReqBody,
 			htmlRespBody,
 			&siri.RemoteError{Loc: remoteErrorLoc, Err: fmt.Errorf("unmarshallable response body: %s", err)}
 	}
-	if !checkStatusResponse.CheckStatusResponseBody.CheckStatusResponse.CheckStatusResponseAnswer.Status {
+	clockskew.Default.Record(logger, cfg.SupplierAddress, sentAt, receivedAt, checkStatusAnswer.ResponseTimestamp)
+	messageid.Check(logger, "CheckStatus", req.MessageIdentifier, checkStatusAnswer.RequestMessageRef)
+	if !checkStatusAnswer.Status {
 		return CheckStatusResult{},
 			htmlReqBody,
 			htmlRespBody,
 			&siri.RemoteError{Loc: remoteErrorLoc, Err: fmt.Errorf("status not true in response body")}
 	}
-	serviceStartedTime := checkStatusResponse.CheckStatusResponseBody.CheckStatusResponse.CheckStatusResponseAnswer.ServiceStartedTime.UTC()
+	serviceStartedTime := checkStatusAnswer.ServiceStartedTime.UTC()
 	result := CheckStatusResult{
 		SupplierServiceStartedTime: serviceStartedTime,
 		LastSupplierCheckStatusOk:  time.Now(),
@@ -106,32 +109,35 @@ func (req *CheckStatusRequest) populate(cfg *config.ConfigCheckStatus, requestTi
 	if err != nil {
 		return fmt.Errorf("error the supplier address is not a valid URL: %s", cfg.SupplierAddress)
 	}
-	req.RequestTimestamp = *requestTimestamp
+	binding, err := siri.ParseBinding(cfg.Binding)
+	if err != nil {
+		return err
+	}
+	req.Binding = binding
+	location, err := cfg.Location()
+	if err != nil {
+		return err
+	}
+	req.Location = location
+	req.RequestTimestamp = clockskew.Default.Compensate(cfg.SupplierAddress, requestTimestamp.In(location))
 	req.RequestorRef = cfg.SubscriberRef
-	req.MessageIdentifier = req.RequestorRef + ":ResponseMessage:" + requestTimestamp.Format(IDENTIFIER_TIME_LAYOUT)
+	req.MessageIdentifier, err = messageid.New(cfg.MessageIdPattern, req.RequestorRef, "ResponseMessage", req.RequestTimestamp)
+	if err != nil {
+		return err
+	}
 	req.SupplierAddress = *supplierAddressUrl
 	return nil
 }
 
-func (req *CheckStatusRequest) generateHttpSoapReq() (*http.Request, string, error) {
+func (req *CheckStatusRequest) generateHttpReq() (*http.Request, string, error) {
 	tmpl, err := template.ParseFiles("./template/checkstatus-request.tmpl")
 	if err != nil {
 		return nil, "", fmt.Errorf("error parsing template: %s", err)
 	}
-	htmlReqBodyBuffer := &bytes.Buffer{}
-	err = tmpl.Execute(htmlReqBodyBuffer, req)
+	payloadBuffer := &bytes.Buffer{}
+	err = tmpl.ExecuteTemplate(payloadBuffer, req.Binding.PayloadTemplateName(), req)
 	if err != nil {
 		return nil, "", fmt.Errorf("error building template: %s", err)
 	}
-	htmlReqBody := htmlReqBodyBuffer.String()
-	httpReq, err := http.NewRequest(http.MethodPost, req.SupplierAddress.String(), strings.NewReader(htmlReqBody))
-	headers := http.Header{
-		"Content-Type": []string{"text/xml; charset=utf-8"},
-		"SOAPAction":   []string{"CheckStatus"},
-	}
-	httpReq.Header = headers // better than .Header.Set to preserve case (for "SOAPAction")
-	if err != nil {
-		return nil, "", fmt.Errorf("error building http-request: %s", err)
-	}
-	return httpReq, htmlReqBody, nil
+	return req.Binding.NewHttpRequest(req.SupplierAddress.String(), "CheckStatus", payloadBuffer.String())
 }
@@ -3,8 +3,19 @@ package checkstatus
 import (
 	"encoding/xml"
 	"time"
+
+	"github.com/julienbt/siri-sm/internal/siri"
 )
 
+var CHECK_STATUS_ANSWER_PATH = siri.AnswerPath{
+	Soap: []string{"CheckStatusResponse", "Answer"},
+	Raw:  []string{"CheckStatusResponse"},
+}
+
+// CHECK_STATUS_ANSWER_INFO_PATH is the `CheckStatusAnswerInfo` of the SOAP
+// response, raw SIRI has no such element
+var CHECK_STATUS_ANSWER_INFO_PATH = []string{"Envelope", "Body", "CheckStatusResponse", "CheckStatusAnswerInfo"}
+
 type CheckStatusResponseEnv struct {
 	XMLName                 xml.Name `xml:"Envelope"`
 	CheckStatusResponseBody CheckStatusResponseBody
@@ -16,12 +27,42 @@ type CheckStatusResponseBody struct {
 }
 
 type CheckStatusResponse struct {
-	XMLName                   xml.Name `xml:"CheckStatusResponse"`
-	CheckStatusResponseAnswer CheckStatusResponseAnswer
+	XMLName                   xml.Name                  `xml:"CheckStatusResponse"`
+	CheckStatusResponseAnswer CheckStatusResponseAnswer `xml:"Answer"`
 }
 
+// CheckStatusResponseAnswer is the `Answer` element in SOAP, and the
+// `CheckStatusResponse` element in raw SIRI
 type CheckStatusResponseAnswer struct {
-	XMLName            xml.Name  `xml:"Answer"`
+	XMLName            xml.Name
+	ResponseTimestamp  time.Time `xml:"ResponseTimestamp"`
+	RequestMessageRef  string    `xml:"RequestMessageRef"` // in raw SIRI, copied from the `CheckStatusAnswerInfo` in SOAP
 	Status             bool      `xml:"Status"`
 	ServiceStartedTime time.Time `xml:"ServiceStartedTime"`
 }
+
+type CheckStatusAnswerInfo struct {
+	XMLName           xml.Name `xml:"CheckStatusAnswerInfo"`
+	ProducerRef       string   `xml:"ProducerRef"`
+	RequestMessageRef string   `xml:"RequestMessageRef"`
+}
+
+// decodeCheckStatusAnswer decodes the answer and, in SOAP, takes its
+// `RequestMessageRef` from the `CheckStatusAnswerInfo`
+func decodeCheckStatusAnswer(binding siri.Binding, body []byte, location *time.Location) (*CheckStatusResponseAnswer, error) {
+	answer := &CheckStatusResponseAnswer{}
+	err := binding.DecodeAnswerIn(body, CHECK_STATUS_ANSWER_PATH, answer, location)
+	if err != nil {
+		return nil, err
+	}
+	if !binding.IsSoap() || answer.RequestMessageRef != "" {
+		return answer, nil
+	}
+	answerInfo := CheckStatusAnswerInfo{}
+	_, err = siri.DecodeElementAt(body, CHECK_STATUS_ANSWER_INFO_PATH, &answerInfo)
+	if err != nil {
+		return nil, err
+	}
+	answer.RequestMessageRef = answerInfo.RequestMessageRef
+	return answer, nil
+}
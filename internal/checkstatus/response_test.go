@@ -0,0 +1,57 @@
+package checkstatus
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/julienbt/siri-sm/internal/siri"
+)
+
+const SOAP_CHECK_STATUS_RESPONSE string = `<S:Envelope xmlns:S="http://schemas.xmlsoap.org/soap/envelope/">
+	<S:Body>
+		<sw:CheckStatusResponse xmlns:sw="http://wsdl.siri.org.uk" xmlns:siri="http://www.siri.org.uk/siri">
+			<CheckStatusAnswerInfo>
+				<siri:ResponseTimestamp>2022-08-30T08:00:00.000+02:00</siri:ResponseTimestamp>
+				<siri:ProducerRef>ILEVIA</siri:ProducerRef>
+				<siri:RequestMessageRef>KISIO2:ResponseMessage:20220830_080000_3f9a1c07_1</siri:RequestMessageRef>
+			</CheckStatusAnswerInfo>
+			<Answer>
+				<siri:ResponseTimestamp>2022-08-30T08:00:00.000+02:00</siri:ResponseTimestamp>
+				<siri:Status>true</siri:Status>
+				<siri:ServiceStartedTime>2022-08-30T04:00:00.000+02:00</siri:ServiceStartedTime>
+			</Answer>
+			<AnswerExtension/>
+		</sw:CheckStatusResponse>
+	</S:Body>
+</S:Envelope>`
+
+const RAW_CHECK_STATUS_RESPONSE string = `<Siri xmlns="http://www.siri.org.uk/siri" version="2.0">
+	<CheckStatusResponse version="2.0">
+		<ResponseTimestamp>2022-08-30T08:00:00.000+02:00</ResponseTimestamp>
+		<RequestMessageRef>KISIO2:ResponseMessage:20220830_080000_3f9a1c07_1</RequestMessageRef>
+		<Status>true</Status>
+		<ServiceStartedTime>2022-08-30T04:00:00.000+02:00</ServiceStartedTime>
+	</CheckStatusResponse>
+</Siri>`
+
+func TestDecodeCheckStatusAnswer(t *testing.T) {
+	require := require.New(t)
+
+	testCases := []struct {
+		name    string
+		binding siri.Binding
+		body    string
+	}{
+		{"soap", siri.BINDING_SOAP_11, SOAP_CHECK_STATUS_RESPONSE},
+		{"raw", siri.BINDING_RAW, RAW_CHECK_STATUS_RESPONSE},
+	}
+	for _, testCase := range testCases {
+		answer, err := decodeCheckStatusAnswer(testCase.binding, []byte(testCase.body), time.UTC)
+		require.Nil(err, testCase.name)
+		require.True(answer.Status, testCase.name)
+		require.Equal("KISIO2:ResponseMessage:20220830_080000_3f9a1c07_1", answer.RequestMessageRef, testCase.name)
+		require.True(time.Date(2022, 8, 30, 2, 0, 0, 0, time.UTC).Equal(answer.ServiceStartedTime), testCase.name)
+	}
+}
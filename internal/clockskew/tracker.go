@@ -0,0 +1,130 @@
+// Package clockskew measures the offset between our clock and the clock of
+// each supplier, from the `ResponseTimestamp` of its answers: the suppliers
+// reject the requests whose `RequestTimestamp` drifts too far from theirs
+package clockskew
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/julienbt/siri-sm/internal/config"
+)
+
+// DEFAULT_THRESHOLD is the skew above which a warning is logged when no
+// configuration is installed
+const DEFAULT_THRESHOLD time.Duration = 30 * time.Second
+
+type Skew struct {
+	Supplier          string        // address of the supplier
+	Offset            time.Duration // clock of the supplier minus ours, positive when it is ahead
+	RoundTrip         time.Duration
+	ResponseTimestamp time.Time
+	MeasuredAt        time.Time
+}
+
+// Measure estimates the offset of a `ResponseTimestamp`, assuming it was
+// stamped halfway through the round trip of the request
+func Measure(sentAt time.Time, receivedAt time.Time, responseTimestamp time.Time) Skew {
+	roundTrip := receivedAt.Sub(sentAt)
+	return Skew{
+		Offset:            responseTimestamp.Sub(sentAt.Add(roundTrip / 2)),
+		RoundTrip:         roundTrip,
+		ResponseTimestamp: responseTimestamp,
+		MeasuredAt:        receivedAt,
+	}
+}
+
+// Exceeds is true when the offset is further than the threshold, in either
+// direction, a zero threshold is never exceeded
+func (s Skew) Exceeds(threshold time.Duration) bool {
+	if threshold <= 0 {
+		return false
+	}
+	return s.Offset > threshold || s.Offset < -threshold
+}
+
+// Tracker keeps the last skew measured for every supplier
+type Tracker struct {
+	mutex      sync.RWMutex
+	threshold  time.Duration
+	compensate bool
+	suppliers  map[string]Skew
+}
+
+func NewTracker(threshold time.Duration, compensate bool) *Tracker {
+	return &Tracker{
+		threshold:  threshold,
+		compensate: compensate,
+		suppliers:  make(map[string]Skew),
+	}
+}
+
+// Default is the tracker of the services, see `Install`
+var Default = NewTracker(DEFAULT_THRESHOLD, false)
+
+// Install replaces the `Default` tracker by a configured one
+func Install(cfg config.ConfigClockSkew) {
+	Default = NewTracker(cfg.ClockSkewThreshold, cfg.ClockSkewCompensate)
+}
+
+// Record measures the skew of an answer of the supplier, a warning is logged
+// above the threshold, answers without `ResponseTimestamp` are ignored
+func (t *Tracker) Record(
+	logger *logrus.Entry,
+	supplier string,
+	sentAt time.Time,
+	receivedAt time.Time,
+	responseTimestamp time.Time,
+) (Skew, bool) {
+	if responseTimestamp.IsZero() {
+		return Skew{}, false
+	}
+	skew := Measure(sentAt, receivedAt, responseTimestamp)
+	skew.Supplier = supplier
+	t.mutex.Lock()
+	t.suppliers[supplier] = skew
+	t.mutex.Unlock()
+
+	if skew.Exceeds(t.threshold) {
+		logger.WithFields(logrus.Fields{
+			"supplier":          supplier,
+			"offset":            skew.Offset.Round(time.Millisecond).String(),
+			"responseTimestamp": responseTimestamp,
+		}).Warnf("the clock of the supplier is off by more than %s", t.threshold)
+	}
+	return skew, true
+}
+
+func (t *Tracker) Get(supplier string) (Skew, bool) {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+	skew, ok := t.suppliers[supplier]
+	return skew, ok
+}
+
+func (t *Tracker) All() []Skew {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+	all := make([]Skew, 0, len(t.suppliers))
+	for _, skew := range t.suppliers {
+		all = append(all, skew)
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].Supplier < all[j].Supplier })
+	return all
+}
+
+// Compensate shifts a timestamp of a request to the clock of the supplier
+// when the compensation is enabled and its skew measured
+func (t *Tracker) Compensate(supplier string, timestamp time.Time) time.Time {
+	if !t.compensate {
+		return timestamp
+	}
+	skew, ok := t.Get(supplier)
+	if !ok {
+		return timestamp
+	}
+	return timestamp.Add(skew.Offset)
+}
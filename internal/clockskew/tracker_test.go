@@ -0,0 +1,68 @@
+package clockskew
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMeasure(t *testing.T) {
+	require := require.New(t)
+	sentAt := time.Date(2022, 8, 30, 8, 0, 0, 0, time.UTC)
+	receivedAt := sentAt.Add(400 * time.Millisecond)
+
+	skew := Measure(sentAt, receivedAt, sentAt.Add(200*time.Millisecond))
+	require.Equal(time.Duration(0), skew.Offset)
+	require.Equal(400*time.Millisecond, skew.RoundTrip)
+
+	skew = Measure(sentAt, receivedAt, sentAt.Add(-2*time.Hour))
+	require.Equal(-2*time.Hour-200*time.Millisecond, skew.Offset)
+	require.True(skew.Exceeds(30 * time.Second))
+	require.False(skew.Exceeds(0))
+}
+
+func TestRecord(t *testing.T) {
+	require := require.New(t)
+	logger, hook := test.NewNullLogger()
+	entry := logrus.NewEntry(logger)
+	tracker := NewTracker(30*time.Second, false)
+	sentAt := time.Date(2022, 8, 30, 8, 0, 0, 0, time.UTC)
+
+	_, ok := tracker.Record(entry, "http://lille", sentAt, sentAt, time.Time{})
+	require.False(ok)
+	_, ok = tracker.Get("http://lille")
+	require.False(ok)
+
+	_, ok = tracker.Record(entry, "http://lille", sentAt, sentAt, sentAt.Add(10*time.Second))
+	require.True(ok)
+	require.Empty(hook.AllEntries())
+
+	_, ok = tracker.Record(entry, "http://amiens", sentAt, sentAt, sentAt.Add(-time.Minute))
+	require.True(ok)
+	require.Len(hook.AllEntries(), 1)
+	require.Equal(logrus.WarnLevel, hook.LastEntry().Level)
+	require.Equal("http://amiens", hook.LastEntry().Data["supplier"])
+
+	all := tracker.All()
+	require.Len(all, 2)
+	require.Equal("http://amiens", all[0].Supplier)
+	require.Equal(-time.Minute, all[0].Offset)
+}
+
+func TestCompensate(t *testing.T) {
+	require := require.New(t)
+	entry := logrus.NewEntry(logrus.New())
+	sentAt := time.Date(2022, 8, 30, 8, 0, 0, 0, time.UTC)
+
+	tracker := NewTracker(0, true)
+	require.Equal(sentAt, tracker.Compensate("http://lille", sentAt))
+	tracker.Record(entry, "http://lille", sentAt, sentAt, sentAt.Add(-3*time.Minute))
+	require.Equal(sentAt.Add(-3*time.Minute), tracker.Compensate("http://lille", sentAt))
+
+	tracker = NewTracker(0, false)
+	tracker.Record(entry, "http://lille", sentAt, sentAt, sentAt.Add(-3*time.Minute))
+	require.Equal(sentAt, tracker.Compensate("http://lille", sentAt))
+}
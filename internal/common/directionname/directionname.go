@@ -19,13 +19,30 @@ func (dn *DirectionName) UnmarshalXML(d *xml.Decoder, start xml.StartElement) er
 		return err
 	}
 
-	if innerText == "ALLER" {
-		*dn = DirectionNameAller
-		return nil
-	} else if innerText == "RETOUR" {
-		*dn = DirectionNameRetour
-		return nil
+	directionName, err := Parse(innerText)
+	if err != nil {
+		return err
+	}
+	*dn = directionName
+	return nil
+}
+
+func Parse(s string) (DirectionName, error) {
+	if s == "ALLER" {
+		return DirectionNameAller, nil
+	} else if s == "RETOUR" {
+		return DirectionNameRetour, nil
 	}
 
-	return fmt.Errorf("the `DirectionName` is not well formatted: %s", innerText)
+	return DirectionNameAller, fmt.Errorf("the `DirectionName` is not well formatted: %s", s)
+}
+
+func (dn DirectionName) String() string {
+	switch dn {
+	case DirectionNameAller:
+		return "ALLER"
+	case DirectionNameRetour:
+		return "RETOUR"
+	}
+	return fmt.Sprintf("DirectionName(%d)", int(dn))
 }
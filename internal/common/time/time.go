@@ -2,23 +2,119 @@ package time
 
 import (
 	"encoding/xml"
+	"fmt"
+	"reflect"
 	"time"
 )
 
+// DEFAULT_TIMEZONE is the timezone of the suppliers without configured one
+const DEFAULT_TIMEZONE string = "Europe/Paris"
+
+// LAYOUT is the layout of the times sent to the suppliers, with milliseconds
+const LAYOUT string = "2006-01-02T15:04:05.000Z07:00"
+
+// UNZONED_LAYOUT parses the times sent without UTC offset by some suppliers
+const UNZONED_LAYOUT string = "2006-01-02T15:04:05.999999999"
+
+// UNZONED is the location of the times parsed without UTC offset, they are
+// wall clocks of the supplier set in its timezone by `In`
+var UNZONED = time.FixedZone("UNZONED", 0)
+
 type Time time.Time
 
 func (ct *Time) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
-	const CUSTUM_TIME_LAYOUT string = "2006-01-02T15:04:05.000Z07:00"
 	var s string
 	err := d.DecodeElement(&s, &start)
 	if err != nil {
 		return err
 	}
 
-	t, err := time.Parse(CUSTUM_TIME_LAYOUT, s)
+	t, err := Parse(s)
 	if err != nil {
 		return err
 	}
 	*ct = Time(t)
 	return nil
 }
+
+func (ct Time) MarshalJSON() ([]byte, error) {
+	return time.Time(ct).MarshalJSON()
+}
+
+// Parse parses a SIRI time, with or without fractional seconds and with or
+// without UTC offset (the time is then `UNZONED`)
+func Parse(s string) (time.Time, error) {
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err == nil {
+		return t, nil
+	}
+	t, unzonedErr := time.ParseInLocation(UNZONED_LAYOUT, s, UNZONED)
+	if unzonedErr == nil {
+		return t, nil
+	}
+	return time.Time{}, err
+}
+
+// LoadLocation loads the timezone of a supplier, `DEFAULT_TIMEZONE` when empty
+func LoadLocation(timezone string) (*time.Location, error) {
+	if timezone == "" {
+		timezone = DEFAULT_TIMEZONE
+	}
+	location, err := time.LoadLocation(timezone)
+	if err != nil {
+		return nil, fmt.Errorf("unknown timezone %s: %s", timezone, err)
+	}
+	return location, nil
+}
+
+// In returns the time in the location, an `UNZONED` time is read as a wall
+// clock of the location
+func In(t time.Time, location *time.Location) time.Time {
+	if t.IsZero() {
+		return t
+	}
+	if t.Location() == UNZONED {
+		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), location)
+	}
+	return t.In(location)
+}
+
+var (
+	timeType     = reflect.TypeOf(time.Time{})
+	siriTimeType = reflect.TypeOf(Time{})
+)
+
+// Normalize sets in the location, see `In`, every `Time` and `time.Time`
+// reachable from v through pointers, structs and slices
+func Normalize(v interface{}, location *time.Location) {
+	normalizeValue(reflect.ValueOf(v), location)
+}
+
+func normalizeValue(v reflect.Value, location *time.Location) {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if !v.IsNil() {
+			normalizeValue(v.Elem(), location)
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			normalizeValue(v.Index(i), location)
+		}
+	case reflect.Struct:
+		switch v.Type() {
+		case timeType:
+			if v.CanSet() {
+				v.Set(reflect.ValueOf(In(v.Interface().(time.Time), location)))
+			}
+		case siriTimeType:
+			if v.CanSet() {
+				t := In(time.Time(v.Interface().(Time)), location)
+				v.Set(reflect.ValueOf(Time(t)))
+			}
+		default:
+			for i := 0; i < v.NumField(); i++ {
+				normalizeValue(v.Field(i), location)
+			}
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package time
+
+import (
+	"encoding/xml"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	require := require.New(t)
+	expected := time.Date(2022, 8, 30, 6, 0, 0, 0, time.UTC)
+
+	for _, s := range []string{
+		"2022-08-30T08:00:00.000+02:00",
+		"2022-08-30T08:00:00+02:00",
+		"2022-08-30T06:00:00Z",
+		"2022-08-30T08:00:00.000000+02:00",
+	} {
+		parsed, err := Parse(s)
+		require.Nil(err, s)
+		require.True(expected.Equal(parsed), s)
+	}
+
+	parsed, err := Parse("2022-08-30T08:00:00.5")
+	require.Nil(err)
+	require.Equal(UNZONED, parsed.Location())
+	require.Equal(500*time.Millisecond, time.Duration(parsed.Nanosecond()))
+
+	_, err = Parse("30/08/2022 08:00")
+	require.NotNil(err)
+}
+
+func TestInAcrossDst(t *testing.T) {
+	require := require.New(t)
+	paris, err := LoadLocation("")
+	require.Nil(err)
+	require.Equal(DEFAULT_TIMEZONE, paris.String())
+
+	for _, tc := range []struct {
+		s        string
+		expected string
+	}{
+		// Winter and summer wall clocks
+		{s: "2022-03-27T01:30:00", expected: "2022-03-27T01:30:00.000+01:00"},
+		{s: "2022-03-27T03:30:00", expected: "2022-03-27T03:30:00.000+02:00"},
+		{s: "2022-10-30T03:30:00", expected: "2022-10-30T03:30:00.000+01:00"},
+		// A time with UTC offset keeps its instant
+		{s: "2022-03-27T00:30:00Z", expected: "2022-03-27T01:30:00.000+01:00"},
+		{s: "2022-03-27T01:30:00Z", expected: "2022-03-27T03:30:00.000+02:00"},
+		{s: "2022-10-30T00:30:00Z", expected: "2022-10-30T02:30:00.000+02:00"},
+		{s: "2022-10-30T01:30:00Z", expected: "2022-10-30T02:30:00.000+01:00"},
+	} {
+		parsed, err := Parse(tc.s)
+		require.Nil(err, tc.s)
+		require.Equal(tc.expected, In(parsed, paris).Format(LAYOUT), tc.s)
+	}
+
+	// The skipped wall clock of the spring forward is moved an hour later
+	parsed, err := Parse("2022-03-27T02:30:00")
+	require.Nil(err)
+	require.Equal("2022-03-27T03:30:00.000+02:00", In(parsed, paris).Format(LAYOUT))
+
+	require.True(In(time.Time{}, paris).IsZero())
+}
+
+func TestNormalize(t *testing.T) {
+	require := require.New(t)
+	montreal, err := LoadLocation("America/Montreal")
+	require.Nil(err)
+
+	type call struct {
+		AimedDepartureTime Time `xml:"AimedDepartureTime"`
+	}
+	var delivery struct {
+		ResponseTimestamp time.Time `xml:"ResponseTimestamp"`
+		Calls             []call    `xml:"Call"`
+		Previous          *call     `xml:"Previous"`
+	}
+	err = xml.Unmarshal([]byte(`<Delivery>
+		<ResponseTimestamp>2022-03-13T07:00:00Z</ResponseTimestamp>
+		<Call><AimedDepartureTime>2022-03-13T08:00:00</AimedDepartureTime></Call>
+		<Call><AimedDepartureTime>2022-03-13T12:00:00.000Z</AimedDepartureTime></Call>
+		<Previous><AimedDepartureTime>2022-03-12T08:00:00</AimedDepartureTime></Previous>
+	</Delivery>`), &delivery)
+	require.Nil(err)
+
+	Normalize(&delivery, montreal)
+	require.Equal("2022-03-13T03:00:00.000-04:00", delivery.ResponseTimestamp.Format(LAYOUT))
+	require.Equal("2022-03-13T08:00:00.000-04:00", time.Time(delivery.Calls[0].AimedDepartureTime).Format(LAYOUT))
+	require.Equal("2022-03-13T08:00:00.000-04:00", time.Time(delivery.Calls[1].AimedDepartureTime).Format(LAYOUT))
+	require.Equal("2022-03-12T08:00:00.000-05:00", time.Time(delivery.Previous.AimedDepartureTime).Format(LAYOUT))
+}
+
+func TestLoadLocationUnknown(t *testing.T) {
+	_, err := LoadLocation("Europ/Paris")
+	require.NotNil(t, err)
+}
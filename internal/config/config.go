@@ -1,13 +1,293 @@
 package config
 
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/kelseyhightower/envconfig"
+
+	siri_time "github.com/julienbt/siri-sm/internal/common/time"
+	"github.com/julienbt/siri-sm/internal/messageid"
+)
+
 type ConfigCheckStatus struct {
-	SupplierAddress string `required:"true" split_words:"true"` // CanalBox endpoint for SIRI-ET subscription
-	SubscriberRef   string `required:"true" split_words:"true"`
+	SupplierAddress  string `required:"true" split_words:"true"` // CanalBox endpoint for SIRI-ET subscription
+	SubscriberRef    string `required:"true" split_words:"true"`
+	Binding          string `default:"soap11"`       // "soap11", "soap12" or "raw"
+	Timezone         string `default:"Europe/Paris"` // of the request timestamps and of the response times
+	MessageIdPattern string `split_words:"true"`     // of the `MessageIdentifier`, `messageid.DEFAULT_PATTERN` when empty
+}
+
+func (cfg *ConfigCheckStatus) Location() (*time.Location, error) {
+	return siri_time.LoadLocation(cfg.Timezone)
 }
 
 type ConfigSubscribe struct {
-	SupplierAddress string `required:"true" split_words:"true"` // CanalBox endpoint for SIRI-ET subscription
-	SubscriberRef   string `required:"true" split_words:"true"`
-	ProducerRef     string `required:"true" split_words:"true"`
-	ConsumerAddress string `required:"true" split_words:"true"`
+	SupplierAddress   string        `required:"true" split_words:"true"` // CanalBox endpoint for SIRI-ET subscription
+	SubscriberRef     string        `required:"true" split_words:"true"`
+	ProducerRef       string        `required:"true" split_words:"true"`
+	ConsumerAddress   string        `required:"true" split_words:"true"`
+	Binding           string        `default:"soap11"`                  // "soap11", "soap12" or "raw"
+	Service           string        `default:"sm"`                      // "sm", "et", "vm", "gm" or "sx"
+	StopListFile      string        `split_words:"true"`                // stop ids to subscribe to, e.g. written by `stoppointsdiscovery`
+	LineRefs          []string      `split_words:"true"`                // one subscription per stop and line when set
+	ValidateLineRefs  bool          `default:"true" split_words:"true"` // check the `LineRefs` with a LinesDiscovery
+	Timezone          string        `default:"Europe/Paris"`            // of the request timestamps and of the response times
+	MessageIdPattern  string        `split_words:"true"`
+	ChunkSize         int           `default:"0" split_words:"true"`  // subscriptions per Subscribe call, all of them in one call with 0
+	ChunkConcurrency  int           `default:"2" split_words:"true"`  // Subscribe calls sent at once
+	SubscriptionsFile string        `split_words:"true"`              // active subscriptions reconciled by `sirism reconcile`
+	RenewalMargin     time.Duration `default:"1h" split_words:"true"` // `sirism reconcile` subscribes again the subscriptions expiring within this margin
+}
+
+func (cfg *ConfigSubscribe) Location() (*time.Location, error) {
+	return siri_time.LoadLocation(cfg.Timezone)
+}
+
+// CheckStatusConfig is the configuration of the other requests to the
+// supplier of the subscriptions, e.g. a LinesDiscovery
+func (cfg *ConfigSubscribe) CheckStatusConfig() ConfigCheckStatus {
+	return ConfigCheckStatus{
+		SupplierAddress:  cfg.SupplierAddress,
+		SubscriberRef:    cfg.SubscriberRef,
+		Binding:          cfg.Binding,
+		Timezone:         cfg.Timezone,
+		MessageIdPattern: cfg.MessageIdPattern,
+	}
+}
+
+const (
+	SERVICE_STOP_MONITORING     string = "sm"
+	SERVICE_ESTIMATED_TIMETABLE string = "et"
+	SERVICE_VEHICLE_MONITORING  string = "vm"
+	SERVICE_GENERAL_MESSAGE     string = "gm"
+	SERVICE_SITUATION_EXCHANGE  string = "sx"
+)
+
+const (
+	PROTOCOL_SOAP      string = "soap"
+	PROTOCOL_SIRI_LITE string = "lite" // JSON over REST
+)
+
+// ConfigSiriLite is the configuration of a SIRI Lite supplier, the `SupplierAddress`
+// is the base of the services, e.g. `https://example.com/siri/2.0`
+type ConfigSiriLite struct {
+	SupplierAddress string `required:"true" split_words:"true"`
+	ApiKey          string `split_words:"true"`     // sent in the `apikey` header when set
+	Timezone        string `default:"Europe/Paris"` // of the response times without UTC offset
+}
+
+func (cfg *ConfigSiriLite) Location() (*time.Location, error) {
+	return siri_time.LoadLocation(cfg.Timezone)
+}
+
+// ConfigSupplier is loaded from `<PREFIX>_SUPPLIER_<NAME>_*` variables
+type ConfigSupplier struct {
+	Name             string   `ignored:"true"`
+	Protocol         string   `default:"soap"`   // "soap" (SIRI XML, see Binding) or "lite"
+	Binding          string   `default:"soap11"` // "soap11", "soap12" or "raw"
+	SupplierAddress  string   `required:"true" split_words:"true"`
+	SubscriberRef    string   `required:"true" split_words:"true"`
+	ProducerRef      string   `split_words:"true"`
+	ConsumerAddress  string   `split_words:"true"`
+	ApiKey           string   `split_words:"true"`
+	MonitoringRefs   []string `split_words:"true"`
+	LineRefs         []string `split_words:"true"` // only the visits of these lines are kept
+	StopListFile     string   `split_words:"true"` // stops subscribed to, the expected subscriptions of the health checks
+	Timezone         string   `default:"Europe/Paris"`
+	MessageIdPattern string   `split_words:"true"`
+}
+
+func (cfg *ConfigSupplier) SiriLiteConfig() ConfigSiriLite {
+	return ConfigSiriLite{
+		SupplierAddress: cfg.SupplierAddress,
+		ApiKey:          cfg.ApiKey,
+		Timezone:        cfg.Timezone,
+	}
+}
+
+func (cfg *ConfigSupplier) CheckStatusConfig() ConfigCheckStatus {
+	return ConfigCheckStatus{
+		SupplierAddress:  cfg.SupplierAddress,
+		SubscriberRef:    cfg.SubscriberRef,
+		Binding:          cfg.Binding,
+		Timezone:         cfg.Timezone,
+		MessageIdPattern: cfg.MessageIdPattern,
+	}
+}
+
+type ConfigSuppliers struct {
+	Suppliers []string `required:"true"` // names of the suppliers, e.g. "lille,amiens"
+}
+
+// LoadSuppliers reads the list `<PREFIX>_SUPPLIERS` then the configuration of each supplier
+func LoadSuppliers(prefix string) ([]ConfigSupplier, error) {
+	var cfgSuppliers ConfigSuppliers
+	err := envconfig.Process(prefix, &cfgSuppliers)
+	if err != nil {
+		return nil, err
+	}
+	suppliers := make([]ConfigSupplier, 0, len(cfgSuppliers.Suppliers))
+	for _, name := range cfgSuppliers.Suppliers {
+		name = strings.TrimSpace(name)
+		supplier := ConfigSupplier{}
+		err = envconfig.Process(prefix+"_SUPPLIER_"+strings.ToUpper(name), &supplier)
+		if err != nil {
+			return nil, fmt.Errorf("error in configuration of the supplier %s: %s", name, err)
+		}
+		if supplier.Protocol != PROTOCOL_SOAP && supplier.Protocol != PROTOCOL_SIRI_LITE {
+			return nil, fmt.Errorf("error in configuration of the supplier %s: unknown protocol %s", name, supplier.Protocol)
+		}
+		_, err = siri_time.LoadLocation(supplier.Timezone)
+		if err != nil {
+			return nil, fmt.Errorf("error in configuration of the supplier %s: %s", name, err)
+		}
+		err = messageid.Validate(supplier.MessageIdPattern)
+		if err != nil {
+			return nil, fmt.Errorf("error in configuration of the supplier %s: %s", name, err)
+		}
+		supplier.Name = name
+		suppliers = append(suppliers, supplier)
+	}
+	return suppliers, nil
+}
+
+type ConfigGtfsRt struct {
+	ListenAddress string        `default:":8080" split_words:"true"`
+	OutputFile    string        `split_words:"true"` // the feed is also written to this file when set
+	MappingFile   string        `split_words:"true"` // CSV mapping of SIRI refs to GTFS ids
+	PollInterval  time.Duration `default:"30s" split_words:"true"`
+}
+
+type ConfigApi struct {
+	ListenAddress            string        `default:":8081" split_words:"true"`
+	PollInterval             time.Duration `default:"30s" split_words:"true"`
+	LivenessWindow           time.Duration `default:"5m" split_words:"true"` // a supplier is stale without heartbeat nor delivery notification during this window
+	HealthCheckStatusMaxAge  time.Duration `default:"5m" split_words:"true"` // not ready when the last successful CheckStatus of a supplier is older, disabled with 0
+	HealthStoreMaxAge        time.Duration `default:"5m" split_words:"true"` // not ready when the visits of a supplier were updated longer ago, disabled with 0
+	HealthNotificationMaxAge time.Duration `split_words:"true"`              // not ready when the active subscriptions of a supplier got no notification for longer, disabled with 0
+}
+
+type ConfigStopPointsDiscovery struct {
+	ConfigCheckStatus
+	StopListFile  string `required:"true" split_words:"true"`
+	OnlyMonitored bool   `default:"true" split_words:"true"` // skip the stop points flagged as not `Monitored`, monitored when the flag is absent
+}
+
+type ConfigGetStopMonitoring struct {
+	MonitoringRef    string   `split_words:"true"` // the stops of `sirism stop-monitoring` without --monitoring-ref nor --supplier, e.g. "ILEVIA:StopPoint:BP:CAS001:LOC"
+	LineRefs         []string `split_words:"true"`
+	ValidateLineRefs bool     `default:"true" split_words:"true"` // check the `LineRefs` with a LinesDiscovery
+}
+
+type ConfigLinesDiscovery struct {
+	ConfigCheckStatus
+}
+
+type ConfigGetEstimatedTimetable struct {
+	ConfigCheckStatus
+	LineRefs []string `split_words:"true"` // the whole network without line
+}
+
+type ConfigGetVehicleMonitoring struct {
+	ConfigCheckStatus
+	LineRefs []string `split_words:"true"` // all the vehicles without line
+}
+
+type ConfigGetGeneralMessage struct {
+	ConfigCheckStatus
+	InfoChannelRefs []string `split_words:"true"` // all the channels without info channel
+}
+
+type ConfigGetSituationExchange struct {
+	ConfigCheckStatus
+	LineRefs []string `split_words:"true"` // all the situations without line
+}
+
+// ConfigFakeProducer is the configuration of the fake SIRI producer, the
+// `*File` fixtures are replied as-is instead of the generated answers
+type ConfigFakeProducer struct {
+	ListenAddress         string        `default:":8090" split_words:"true"`
+	ProducerRef           string        `default:"FAKE" split_words:"true"`
+	Synthetic             bool          `default:"true"` // generate visits for every requested stop
+	CheckStatusFile       string        `split_words:"true"`
+	GetStopMonitoringFile string        `split_words:"true"`
+	SubscribeFile         string        `split_words:"true"`
+	Latency               time.Duration `default:"0s"`
+	StatusFalse           bool          `split_words:"true"` // answer `Status` false
+	Fault                 string        // reason of the SOAP fault answered to every request
+	NotifyInterval        time.Duration `default:"0s" split_words:"true"` // NotifyStopMonitoring push, disabled with 0
+}
+
+// ConfigRecording is loaded from the `<PREFIX>_RECORD_DIR` and
+// `<PREFIX>_REPLAY_DIR` variables, both are exclusive
+type ConfigRecording struct {
+	RecordDir string `split_words:"true"` // every exchange with a supplier is archived in this directory
+	ReplayDir string `split_words:"true"` // the responses are served from the exchanges of this directory
+}
+
+// ConfigClockSkew is loaded from the `<PREFIX>_CLOCK_SKEW_*` variables
+type ConfigClockSkew struct {
+	ClockSkewThreshold  time.Duration `default:"30s" split_words:"true"` // a warning is logged when a supplier clock is further off, disabled with 0
+	ClockSkewCompensate bool          `split_words:"true"`               // the request timestamps are shifted by the measured skew of the supplier
+}
+
+func LoadClockSkew(prefix string) (ConfigClockSkew, error) {
+	var cfg ConfigClockSkew
+	err := envconfig.Process(prefix, &cfg)
+	if err != nil {
+		return ConfigClockSkew{}, err
+	}
+	if cfg.ClockSkewThreshold < 0 {
+		return ConfigClockSkew{}, fmt.Errorf("error in configuration of the clock skew: negative threshold %s", cfg.ClockSkewThreshold)
+	}
+	return cfg, nil
+}
+
+// ConfigSubscriptionState is loaded from the `<PREFIX>_SUBSCRIPTION_STATE_FILE`
+// variable
+type ConfigSubscriptionState struct {
+	SubscriptionStateFile string `split_words:"true"` // bbolt file of the subscriptions, not persisted when empty
+}
+
+// ConfigAudit is loaded from the `<PREFIX>_AUDIT_*` variables, the exchanges
+// are logged without their bodies in any case
+type ConfigAudit struct {
+	AuditFile        string   `split_words:"true"`                                                                          // the exchanges are appended to this file as JSON lines with their bodies, not stored when empty
+	AuditRedact      []string `default:"RequestorRef,SubscriberRef,ConsumerRef,ConsumerAddress,Address" split_words:"true"` // elements, JSON keys and query parameters masked in the stored bodies
+	AuditMaxBodySize int      `default:"65536" split_words:"true"`                                                          // the stored bodies are truncated beyond this size in bytes, disabled with 0
+}
+
+func LoadAudit(prefix string) (ConfigAudit, error) {
+	var cfg ConfigAudit
+	err := envconfig.Process(prefix, &cfg)
+	if err != nil {
+		return ConfigAudit{}, err
+	}
+	if cfg.AuditMaxBodySize < 0 {
+		return ConfigAudit{}, fmt.Errorf("error in configuration of the audit: negative max body size %d", cfg.AuditMaxBodySize)
+	}
+	return cfg, nil
+}
+
+func LoadSubscriptionState(prefix string) (ConfigSubscriptionState, error) {
+	var cfg ConfigSubscriptionState
+	err := envconfig.Process(prefix, &cfg)
+	if err != nil {
+		return ConfigSubscriptionState{}, err
+	}
+	return cfg, nil
+}
+
+func LoadRecording(prefix string) (ConfigRecording, error) {
+	var cfg ConfigRecording
+	err := envconfig.Process(prefix, &cfg)
+	if err != nil {
+		return ConfigRecording{}, err
+	}
+	if cfg.RecordDir != "" && cfg.ReplayDir != "" {
+		return ConfigRecording{}, fmt.Errorf("error in configuration of the recording: both record and replay directories are set")
+	}
+	return cfg, nil
 }
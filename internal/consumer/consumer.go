@@ -0,0 +1,224 @@
+// Package consumer receives the notifications pushed by the suppliers to
+// the consumer address of the subscriptions
+package consumer
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/julienbt/siri-sm/internal/config"
+	"github.com/julienbt/siri-sm/internal/heartbeat"
+	"github.com/julienbt/siri-sm/internal/metrics"
+	"github.com/julienbt/siri-sm/internal/siri"
+	"github.com/julienbt/siri-sm/internal/subscriptionstate"
+)
+
+// Notification is what the consumer needs of a notification, the content
+// of the deliveries isn't decoded
+type Notification struct {
+	Binding          siri.Binding
+	Message          string // e.g. `NotifyStopMonitoring`, or `ServiceDelivery` in raw SIRI XML
+	ProducerRef      string
+	SubscriptionRefs []string                         // of the deliveries
+	Heartbeat        *heartbeat.HeartbeatNotification // of a heartbeat only
+}
+
+// IsHeartbeat is true for a `NotifyHeartbeat` (SOAP) or a
+// `HeartbeatNotification` (raw SIRI XML)
+func (n Notification) IsHeartbeat() bool {
+	return n.Heartbeat != nil
+}
+
+// Parse reads a heartbeat or a delivery notification
+func Parse(body []byte) (Notification, error) {
+	binding, name, err := siri.DetectMessage(body)
+	if err != nil {
+		return Notification{}, fmt.Errorf("not a SIRI notification: %s", err)
+	}
+	notification := Notification{Binding: binding, Message: name}
+	switch {
+	case name == "NotifyHeartbeat" || name == "HeartbeatNotification":
+		heartbeatNotification, err := heartbeat.ParseHeartbeatNotification(body)
+		if err != nil {
+			return Notification{}, err
+		}
+		notification.ProducerRef = heartbeatNotification.ProducerRef
+		notification.Heartbeat = &heartbeatNotification
+		return notification, nil
+	case (binding.IsSoap() && strings.HasPrefix(name, "Notify")) || (binding == siri.BINDING_RAW && name == "ServiceDelivery"):
+		notification.ProducerRef, notification.SubscriptionRefs, err = scanDelivery(body)
+		if err != nil {
+			return Notification{}, err
+		}
+		if notification.ProducerRef == "" {
+			return Notification{}, fmt.Errorf("missing `ProducerRef` in %s", name)
+		}
+		return notification, nil
+	}
+	return Notification{}, fmt.Errorf("not a SIRI notification: %s", name)
+}
+
+// scanDelivery returns the `ProducerRef` of the `ServiceDeliveryInfo` (SOAP)
+// or of the `ServiceDelivery` (raw SIRI XML), and the `SubscriptionRef` of
+// every delivery
+func scanDelivery(body []byte) (string, []string, error) {
+	d := xml.NewDecoder(bytes.NewReader(body))
+	producerRef := ""
+	subscriptionRefs := make([]string, 0)
+	ancestors := make([]string, 0)
+	for {
+		token, err := d.Token()
+		if err == io.EOF {
+			return producerRef, subscriptionRefs, nil
+		}
+		if err != nil {
+			return "", nil, fmt.Errorf("unmarshallable notification: %s", err)
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			parent := ""
+			if len(ancestors) > 0 {
+				parent = ancestors[len(ancestors)-1]
+			}
+			switch {
+			case t.Name.Local == "ProducerRef" && (parent == "ServiceDeliveryInfo" || parent == "ServiceDelivery"):
+				var ref string
+				if err := d.DecodeElement(&ref, &t); err != nil {
+					return "", nil, fmt.Errorf("unmarshallable notification: %s", err)
+				}
+				if producerRef == "" {
+					producerRef = strings.TrimSpace(ref)
+				}
+				continue
+			case t.Name.Local == "SubscriptionRef" && strings.HasSuffix(parent, "Delivery"):
+				var ref string
+				if err := d.DecodeElement(&ref, &t); err != nil {
+					return "", nil, fmt.Errorf("unmarshallable notification: %s", err)
+				}
+				subscriptionRefs = append(subscriptionRefs, strings.TrimSpace(ref))
+				continue
+			}
+			ancestors = append(ancestors, t.Name.Local)
+		case xml.EndElement:
+			ancestors = ancestors[:len(ancestors)-1]
+		}
+	}
+}
+
+// Handler receives the notifications POSTed by the suppliers: they are
+// counted by supplier and subscription, the heartbeats and the deliveries
+// update the liveness of `Tracker`, and the deliveries the last notification
+// of their subscriptions in `Subscriptions`
+type Handler struct {
+	Tracker *heartbeat.Tracker
+	// Subscriptions is optional, the records of a delivery are the ones of
+	// the first of the `Suppliers` with its `ProducerRef`
+	Subscriptions *subscriptionstate.Store
+	Suppliers     []config.ConfigSupplier
+	Logger        *logrus.Entry
+	// Now is the clock of the handler, `time.Now` by default
+	Now func() time.Time
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "the notifications are POSTed", http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	notification, err := Parse(body)
+	if err != nil {
+		h.Logger.Warn(err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	now := time.Now
+	if h.Now != nil {
+		now = h.Now
+	}
+	receivedAt := now()
+	supplier, ok := h.supplierOf(notification.ProducerRef)
+	supplierName := metrics.UNKNOWN_SUPPLIER
+	if ok {
+		supplierName = supplier.Name
+	}
+	if notification.IsHeartbeat() {
+		metrics.RecordNotification(supplierName, "")
+		h.Tracker.RecordHeartbeat(*notification.Heartbeat, receivedAt)
+	} else {
+		h.Tracker.RecordDelivery(notification.ProducerRef, receivedAt)
+		known := make(map[string]bool)
+		if ok {
+			known = h.recordSubscriptions(notification, supplier, receivedAt)
+		}
+		countDelivery(notification, supplierName, known)
+	}
+	h.Logger.WithFields(logrus.Fields{
+		"producerRef":   notification.ProducerRef,
+		"subscriptions": len(notification.SubscriptionRefs),
+	}).Debugf("%s received", notification.Message)
+	w.WriteHeader(http.StatusOK)
+}
+
+// supplierOf returns the first of the `Suppliers` with the producer ref
+func (h *Handler) supplierOf(producerRef string) (config.ConfigSupplier, bool) {
+	for _, supplier := range h.Suppliers {
+		if supplier.ProducerRef == producerRef {
+			return supplier, true
+		}
+	}
+	return config.ConfigSupplier{}, false
+}
+
+// recordSubscriptions sets the last notification of the subscriptions of a
+// delivery in one transaction, and returns the ones found in the state, the
+// unknown ones are only logged
+func (h *Handler) recordSubscriptions(notification Notification, supplier config.ConfigSupplier, receivedAt time.Time) map[string]bool {
+	known := make(map[string]bool)
+	if h.Subscriptions == nil || len(notification.SubscriptionRefs) == 0 {
+		return known
+	}
+	unknown, err := h.Subscriptions.RecordNotifications(supplier.SupplierAddress, notification.SubscriptionRefs, receivedAt)
+	if err != nil {
+		h.Logger.WithField("producerRef", notification.ProducerRef).Error(err)
+		return known
+	}
+	for _, subscriptionRef := range notification.SubscriptionRefs {
+		known[subscriptionRef] = true
+	}
+	for _, subscriptionRef := range unknown {
+		delete(known, subscriptionRef)
+		h.Logger.WithFields(logrus.Fields{
+			"producerRef":     notification.ProducerRef,
+			"subscriptionRef": subscriptionRef,
+		}).Debug("notification of an unknown subscription")
+	}
+	return known
+}
+
+// countDelivery counts the delivery for each of its subscriptions, the ones
+// missing from the state as `metrics.UNKNOWN_SUBSCRIPTION`
+func countDelivery(notification Notification, supplierName string, known map[string]bool) {
+	if len(notification.SubscriptionRefs) == 0 {
+		metrics.RecordNotification(supplierName, metrics.UNKNOWN_SUBSCRIPTION)
+		return
+	}
+	for _, subscriptionRef := range notification.SubscriptionRefs {
+		if !known[subscriptionRef] {
+			subscriptionRef = metrics.UNKNOWN_SUBSCRIPTION
+		}
+		metrics.RecordNotification(supplierName, subscriptionRef)
+	}
+}
@@ -0,0 +1,194 @@
+package consumer
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/require"
+
+	"github.com/julienbt/siri-sm/internal/config"
+	"github.com/julienbt/siri-sm/internal/heartbeat"
+	"github.com/julienbt/siri-sm/internal/metrics"
+	"github.com/julienbt/siri-sm/internal/siri"
+	"github.com/julienbt/siri-sm/internal/subscriptionstate"
+)
+
+var testDataDir string
+
+func TestMain(m *testing.M) {
+
+	testDataDir = os.Getenv("SIRISM_TEST_DATA_DIR")
+	if testDataDir == "" {
+		panic("$SIRISM_TEST_DATA_DIR isn't set")
+	}
+
+	os.Exit(m.Run())
+}
+
+const SOAP_NOTIFY_STOP_MONITORING string = `<S:Envelope xmlns:S="http://schemas.xmlsoap.org/soap/envelope/">
+	<S:Body>
+		<sw:NotifyStopMonitoring xmlns:sw="http://wsdl.siri.org.uk" xmlns:siri="http://www.siri.org.uk/siri">
+			<ServiceDeliveryInfo>
+				<siri:ResponseTimestamp>2022-08-30T08:00:00.000+02:00</siri:ResponseTimestamp>
+				<siri:ProducerRef>ILEVIA</siri:ProducerRef>
+			</ServiceDeliveryInfo>
+			<Notification>
+				<siri:StopMonitoringDelivery version="2.0">
+					<siri:SubscriberRef>KISIO2</siri:SubscriberRef>
+					<siri:SubscriptionRef>KISIO2:Subscription:arret_CAS001:LOC</siri:SubscriptionRef>
+					<siri:Status>true</siri:Status>
+				</siri:StopMonitoringDelivery>
+				<siri:StopMonitoringDelivery version="2.0">
+					<siri:SubscriptionRef>KISIO2:Subscription:arret_CAS002:LOC</siri:SubscriptionRef>
+					<siri:Status>true</siri:Status>
+				</siri:StopMonitoringDelivery>
+			</Notification>
+		</sw:NotifyStopMonitoring>
+	</S:Body>
+</S:Envelope>`
+
+const RAW_NOTIFY_STOP_MONITORING string = `<Siri xmlns="http://www.siri.org.uk/siri" version="2.0">
+	<ServiceDelivery>
+		<ResponseTimestamp>2022-08-30T08:00:00.000+02:00</ResponseTimestamp>
+		<ProducerRef>ILEVIA</ProducerRef>
+		<StopMonitoringDelivery version="2.0">
+			<SubscriptionRef>KISIO2:Subscription:arret_CAS001:LOC</SubscriptionRef>
+			<Status>true</Status>
+		</StopMonitoringDelivery>
+	</ServiceDelivery>
+</Siri>`
+
+func TestParse(t *testing.T) {
+	require := require.New(t)
+
+	notification, err := Parse([]byte(SOAP_NOTIFY_STOP_MONITORING))
+	require.Nil(err)
+	require.Equal(siri.BINDING_SOAP_11, notification.Binding)
+	require.Equal("NotifyStopMonitoring", notification.Message)
+	require.Equal("ILEVIA", notification.ProducerRef)
+	require.Equal([]string{"KISIO2:Subscription:arret_CAS001:LOC", "KISIO2:Subscription:arret_CAS002:LOC"}, notification.SubscriptionRefs)
+	require.False(notification.IsHeartbeat())
+
+	notification, err = Parse([]byte(RAW_NOTIFY_STOP_MONITORING))
+	require.Nil(err)
+	require.Equal("ServiceDelivery", notification.Message)
+	require.Equal("ILEVIA", notification.ProducerRef)
+	require.Equal([]string{"KISIO2:Subscription:arret_CAS001:LOC"}, notification.SubscriptionRefs)
+
+	body, err := ioutil.ReadFile(fmt.Sprintf("%s/examples/HEARTBEAT_NOTIF_000.xml", testDataDir))
+	require.Nil(err)
+	notification, err = Parse(body)
+	require.Nil(err)
+	require.True(notification.IsHeartbeat())
+	require.Equal("ILEVIA", notification.ProducerRef)
+
+	_, err = Parse([]byte(`<Siri><CheckStatusRequest/></Siri>`))
+	require.NotNil(err)
+	_, err = Parse([]byte(`<Siri><ServiceDelivery><StopMonitoringDelivery/></ServiceDelivery></Siri>`))
+	require.NotNil(err)
+}
+
+func TestHandler(t *testing.T) {
+	require := require.New(t)
+	now := time.Date(2022, time.August, 30, 6, 0, 0, 0, time.UTC)
+	tracker := heartbeat.NewTracker(5 * time.Minute)
+	logger, _ := test.NewNullLogger()
+	handler := &Handler{
+		Tracker: tracker,
+		Logger:  logrus.NewEntry(logger),
+		Now:     func() time.Time { return now },
+	}
+
+	post := func(body string) int {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/notifications", strings.NewReader(body)))
+		return recorder.Code
+	}
+	require.Equal(http.StatusOK, post(SOAP_NOTIFY_STOP_MONITORING))
+	liveness, ok := tracker.Liveness("ILEVIA", now)
+	require.True(ok)
+	require.Equal(now, liveness.LastDelivery)
+	require.True(liveness.LastHeartbeat.IsZero())
+
+	body, err := ioutil.ReadFile(fmt.Sprintf("%s/examples/HEARTBEAT_NOTIF_000.xml", testDataDir))
+	require.Nil(err)
+	require.Equal(http.StatusOK, post(string(body)))
+	liveness, _ = tracker.Liveness("ILEVIA", now)
+	require.Equal(now, liveness.LastHeartbeat)
+	require.True(liveness.Status)
+
+	require.Equal(http.StatusBadRequest, post(`not xml`))
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/notifications", nil))
+	require.Equal(http.StatusMethodNotAllowed, recorder.Code)
+}
+
+func TestHandlerRecordsTheNotificationsOfTheSubscriptions(t *testing.T) {
+	require := require.New(t)
+	now := time.Date(2022, time.August, 30, 6, 0, 0, 0, time.UTC)
+	state, err := subscriptionstate.Open(filepath.Join(t.TempDir(), "subscriptions.db"))
+	require.Nil(err)
+	require.Nil(state.Put(subscriptionstate.Record{
+		Supplier:        "http://lille",
+		SubscriptionRef: "KISIO2:Subscription:arret_CAS001:LOC",
+		Status:          subscriptionstate.STATUS_ACTIVE,
+	}))
+	logger, _ := test.NewNullLogger()
+	handler := &Handler{
+		Tracker:       heartbeat.NewTracker(5 * time.Minute),
+		Subscriptions: state,
+		Suppliers: []config.ConfigSupplier{
+			{Name: "amiens", SupplierAddress: "http://amiens", ProducerRef: "ametis"},
+			{Name: "lille", SupplierAddress: "http://lille", ProducerRef: "ILEVIA"},
+		},
+		Logger: logrus.NewEntry(logger),
+		Now:    func() time.Time { return now },
+	}
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/notifications", strings.NewReader(SOAP_NOTIFY_STOP_MONITORING)))
+	require.Equal(http.StatusOK, recorder.Code)
+	record, ok, err := state.Get("http://lille", "KISIO2:Subscription:arret_CAS001:LOC")
+	require.Nil(err)
+	require.True(ok)
+	require.Equal(now, record.LastNotification)
+	// The unknown subscriptions aren't created
+	_, ok, err = state.Get("http://lille", "KISIO2:Subscription:arret_CAS002:LOC")
+	require.Nil(err)
+	require.False(ok)
+
+	require.Equal(1.0, notificationsReceived(t, "lille", "KISIO2:Subscription:arret_CAS001:LOC"))
+	require.Equal(0.0, notificationsReceived(t, "lille", "KISIO2:Subscription:arret_CAS002:LOC"))
+	require.Equal(1.0, notificationsReceived(t, "lille", metrics.UNKNOWN_SUBSCRIPTION))
+}
+
+// notificationsReceived reads the counter of the notifications of a
+// subscription in the registry
+func notificationsReceived(t *testing.T, supplier string, subscriptionRef string) float64 {
+	families, err := metrics.Registry.Gather()
+	require.Nil(t, err)
+	for _, family := range families {
+		if family.GetName() != metrics.NAMESPACE+"_notifications_received_total" {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			labels := make(map[string]string)
+			for _, label := range metric.GetLabel() {
+				labels[label.GetName()] = label.GetValue()
+			}
+			if labels["supplier"] == supplier && labels["subscription_ref"] == subscriptionRef {
+				return metric.GetCounter().GetValue()
+			}
+		}
+	}
+	return 0
+}
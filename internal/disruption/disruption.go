@@ -0,0 +1,115 @@
+package disruption
+
+import (
+	"sort"
+	"time"
+
+	"github.com/julienbt/siri-sm/internal/getstopmonitoring"
+)
+
+const (
+	SOURCE_GENERAL_MESSAGE    string = "GeneralMessage"
+	SOURCE_SITUATION_EXCHANGE string = "SituationExchange"
+)
+
+// Disruption is the common model of a GeneralMessage and of a SituationExchange
+// `PtSituationElement`, the refs are the short ids of the lines and stops
+type Disruption struct {
+	Id              string
+	Source          string
+	Summary         string
+	Description     string
+	ValidityPeriods []Period
+	LineRefs        []getstopmonitoring.LineRef
+	StopRefs        []getstopmonitoring.StopPointRef
+}
+
+// Period is open-ended with a zero `End`
+type Period struct {
+	Start time.Time
+	End   time.Time
+}
+
+func (p *Period) Contains(t time.Time) bool {
+	if !p.Start.IsZero() && t.Before(p.Start) {
+		return false
+	}
+	if !p.End.IsZero() && t.After(p.End) {
+		return false
+	}
+	return true
+}
+
+// IsValidAt is always true without validity period
+func (d *Disruption) IsValidAt(t time.Time) bool {
+	if len(d.ValidityPeriods) == 0 {
+		return true
+	}
+	for i := range d.ValidityPeriods {
+		if d.ValidityPeriods[i].Contains(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// Affects is true when the stop is affected, or when one of the lines is
+// affected without any affected stop
+func (d *Disruption) Affects(stopRef getstopmonitoring.StopPointRef, lineRefs []getstopmonitoring.LineRef) bool {
+	for _, affectedStopRef := range d.StopRefs {
+		if affectedStopRef == stopRef {
+			return true
+		}
+	}
+	if len(d.StopRefs) > 0 {
+		return false
+	}
+	for _, affectedLineRef := range d.LineRefs {
+		for _, lineRef := range lineRefs {
+			if affectedLineRef == lineRef {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ForStop returns the disruptions valid at `at` which affect the stop or the
+// lines serving it, sorted by id
+func ForStop(
+	disruptions []Disruption,
+	stopRef getstopmonitoring.StopPointRef,
+	lineRefs []getstopmonitoring.LineRef,
+	at time.Time,
+) []Disruption {
+	stopDisruptions := make([]Disruption, 0)
+	for _, d := range disruptions {
+		if d.IsValidAt(at) && d.Affects(stopRef, lineRefs) {
+			stopDisruptions = append(stopDisruptions, d)
+		}
+	}
+	sort.Slice(stopDisruptions, func(i, j int) bool { return stopDisruptions[i].Id < stopDisruptions[j].Id })
+	return stopDisruptions
+}
+
+// ParseLineRefs keeps the short ids of the well formatted refs
+func ParseLineRefs(refs []string) []getstopmonitoring.LineRef {
+	lineRefs := make([]getstopmonitoring.LineRef, 0, len(refs))
+	for _, ref := range refs {
+		if lineRef, err := getstopmonitoring.ParseLineRef(ref); err == nil {
+			lineRefs = append(lineRefs, lineRef)
+		}
+	}
+	return lineRefs
+}
+
+// ParseStopPointRefs keeps the short ids of the well formatted refs
+func ParseStopPointRefs(refs []string) []getstopmonitoring.StopPointRef {
+	stopRefs := make([]getstopmonitoring.StopPointRef, 0, len(refs))
+	for _, ref := range refs {
+		if stopRef, err := getstopmonitoring.ParseStopPointRef(ref); err == nil {
+			stopRefs = append(stopRefs, stopRef)
+		}
+	}
+	return stopRefs
+}
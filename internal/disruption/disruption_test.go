@@ -0,0 +1,110 @@
+package disruption
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/julienbt/siri-sm/internal/getstopmonitoring"
+)
+
+var (
+	morning   = time.Date(2022, 8, 30, 8, 0, 0, 0, time.UTC)
+	noon      = time.Date(2022, 8, 30, 12, 0, 0, 0, time.UTC)
+	afternoon = time.Date(2022, 8, 30, 16, 0, 0, 0, time.UTC)
+)
+
+func TestPeriodContains(t *testing.T) {
+	require := require.New(t)
+
+	testCases := []struct {
+		name     string
+		period   Period
+		at       time.Time
+		expected bool
+	}{
+		{"unbounded", Period{}, noon, true},
+		{"inside", Period{Start: morning, End: afternoon}, noon, true},
+		{"at the start", Period{Start: noon, End: afternoon}, noon, true},
+		{"at the end", Period{Start: morning, End: noon}, noon, true},
+		{"before", Period{Start: noon, End: afternoon}, morning, false},
+		{"after", Period{Start: morning, End: noon}, afternoon, false},
+		{"open-ended after the start", Period{Start: morning}, afternoon, true},
+		{"open-ended before the start", Period{Start: noon}, morning, false},
+		{"open start before the end", Period{End: noon}, morning, true},
+		{"open start after the end", Period{End: noon}, afternoon, false},
+	}
+	for _, testCase := range testCases {
+		require.Equal(testCase.expected, testCase.period.Contains(testCase.at), testCase.name)
+	}
+}
+
+func TestIsValidAt(t *testing.T) {
+	require := require.New(t)
+
+	testCases := []struct {
+		name     string
+		periods  []Period
+		at       time.Time
+		expected bool
+	}{
+		{"without validity period", nil, noon, true},
+		{"in the period", []Period{{Start: morning, End: afternoon}}, noon, true},
+		{"out of the period", []Period{{Start: morning, End: noon}}, afternoon, false},
+		{"in the second period", []Period{{Start: morning, End: morning.Add(time.Hour)}, {Start: noon}}, afternoon, true},
+		{"between the periods", []Period{{End: morning}, {Start: afternoon}}, noon, false},
+	}
+	for _, testCase := range testCases {
+		d := Disruption{ValidityPeriods: testCase.periods}
+		require.Equal(testCase.expected, d.IsValidAt(testCase.at), testCase.name)
+	}
+}
+
+func TestAffects(t *testing.T) {
+	require := require.New(t)
+
+	testCases := []struct {
+		name     string
+		stopRefs []getstopmonitoring.StopPointRef
+		lineRefs []getstopmonitoring.LineRef
+		expected bool
+	}{
+		{"affected stop", []getstopmonitoring.StopPointRef{"CAS002", "CAS001"}, nil, true},
+		{"other stop", []getstopmonitoring.StopPointRef{"CAS002"}, nil, false},
+		{"other stop on an affected line", []getstopmonitoring.StopPointRef{"CAS002"}, []getstopmonitoring.LineRef{"L1"}, false},
+		{"affected line without stop", nil, []getstopmonitoring.LineRef{"L3", "L1"}, true},
+		{"other line without stop", nil, []getstopmonitoring.LineRef{"L3"}, false},
+		{"nothing affected", nil, nil, false},
+	}
+	for _, testCase := range testCases {
+		d := Disruption{StopRefs: testCase.stopRefs, LineRefs: testCase.lineRefs}
+		require.Equal(testCase.expected, d.Affects("CAS001", []getstopmonitoring.LineRef{"L1", "L2"}), testCase.name)
+	}
+}
+
+func TestForStop(t *testing.T) {
+	require := require.New(t)
+
+	disruptions := []Disruption{
+		{Id: "SX3", LineRefs: []getstopmonitoring.LineRef{"L2"}},
+		{Id: "GM1", StopRefs: []getstopmonitoring.StopPointRef{"CAS001"}, ValidityPeriods: []Period{{Start: morning, End: noon}}},
+		{Id: "GM2", StopRefs: []getstopmonitoring.StopPointRef{"CAS001"}, ValidityPeriods: []Period{{Start: afternoon}}},
+		{Id: "SX1", StopRefs: []getstopmonitoring.StopPointRef{"CAS002"}, LineRefs: []getstopmonitoring.LineRef{"L1"}},
+		{Id: "SX2", LineRefs: []getstopmonitoring.LineRef{"L3"}},
+	}
+	lineRefs := []getstopmonitoring.LineRef{"L1", "L2"}
+
+	ids := func(disruptions []Disruption) []string {
+		ids := make([]string, 0, len(disruptions))
+		for _, d := range disruptions {
+			ids = append(ids, d.Id)
+		}
+		return ids
+	}
+	require.Equal([]string{"GM1", "SX3"}, ids(ForStop(disruptions, "CAS001", lineRefs, noon)))
+	require.Equal([]string{"GM2", "SX3"}, ids(ForStop(disruptions, "CAS001", lineRefs, afternoon)))
+	require.Equal([]string{"SX1", "SX3"}, ids(ForStop(disruptions, "CAS002", lineRefs, noon)))
+	require.Empty(ForStop(disruptions, "CAS003", []getstopmonitoring.LineRef{"L4"}, noon))
+	require.Empty(ForStop(nil, "CAS001", lineRefs, noon))
+}
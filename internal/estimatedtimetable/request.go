@@ -0,0 +1,144 @@
+package estimatedtimetable
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"text/template"
+	"time"
+
+	"github.com/julienbt/siri-sm/internal/clockskew"
+	"github.com/julienbt/siri-sm/internal/config"
+	"github.com/julienbt/siri-sm/internal/messageid"
+	"github.com/julienbt/siri-sm/internal/siri"
+	"github.com/sirupsen/logrus"
+)
+
+const PREVIEW_INTERVAL string = "PT2H0M0.000S"
+
+type GetEstimatedTimetableRequest struct {
+	SupplierAddress   url.URL
+	Binding           siri.Binding
+	Location          *time.Location // of the supplier
+	RequestTimestamp  time.Time
+	RequestorRef      string
+	MessageIdentifier string
+	PreviewInterval   string
+	LineRefs          []string
+}
+
+// GetEstimatedTimetable requests the journeys of the lines, or of the whole
+// network without line
+func GetEstimatedTimetable(
+	cfg config.ConfigCheckStatus,
+	logger *logrus.Entry,
+	requestTimestamp *time.Time,
+	lineRefs []string,
+) ([]EstimatedVehicleJourney, string, []byte, error) {
+	var remoteErrorLoc = "GetEstimatedTimetable remote error"
+
+	req := GetEstimatedTimetableRequest{}
+	err := req.populate(&cfg, requestTimestamp, lineRefs)
+	if err != nil {
+		return nil,
+			"",
+			nil,
+			fmt.Errorf("error GetEstimatedTimetable request initialization: %v", err)
+	}
+	httpReq, htmlReqBody, err := req.generateHttpReq()
+	if err != nil {
+		return nil,
+			"",
+			nil,
+			fmt.Errorf("error in building GetEstimatedTimetable request: %s", err)
+	}
+
+	// Send HTTP request and receive the response
+	resp, err := siri.SoapCall(httpReq)
+	if err != nil {
+		return nil,
+			htmlReqBody,
+			nil,
+			&siri.RemoteError{Loc: remoteErrorLoc, Err: fmt.Errorf("call error: %s", err)}
+	}
+
+	// Get the HTTP response body
+	defer resp.Body.Close()
+	htmlRespBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil,
+			htmlReqBody,
+			nil,
+			&siri.RemoteError{Loc: remoteErrorLoc, Err: fmt.Errorf("unreadable response body: %s", err)}
+	}
+
+	// Check HTTP status code
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil,
+			htmlReqBody,
+			htmlRespBody,
+			&siri.RemoteError{Loc: remoteErrorLoc, Err: fmt.Errorf("bad http-response status: %s", resp.Status)}
+	}
+
+	delivery := &EstimatedTimetableDelivery{}
+	err = req.Binding.DecodeAnswerIn(htmlRespBody, ESTIMATED_TIMETABLE_DELIVERY_PATH, delivery, req.Location)
+	if err != nil {
+		return nil,
+			htmlReqBody,
+			htmlRespBody,
+			&siri.RemoteError{Loc: remoteErrorLoc, Err: fmt.Errorf("unmarshallable response body: %s", err)}
+	}
+	if delivery.Status != nil && !*delivery.Status {
+		return nil,
+			htmlReqBody,
+			htmlRespBody,
+			&siri.RemoteError{Loc: remoteErrorLoc, Err: fmt.Errorf("status not true in `EstimatedTimetableDelivery`")}
+	}
+	return delivery.EstimatedVehicleJourneys(), htmlReqBody, htmlRespBody, nil
+}
+
+func (req *GetEstimatedTimetableRequest) populate(
+	cfg *config.ConfigCheckStatus,
+	requestTimestamp *time.Time,
+	lineRefs []string,
+) error {
+	supplierAddressUrl, err := url.Parse(cfg.SupplierAddress)
+	if err != nil {
+		return fmt.Errorf("error the supplier address is not a valid URL: %s", cfg.SupplierAddress)
+	}
+	binding, err := siri.ParseBinding(cfg.Binding)
+	if err != nil {
+		return err
+	}
+	req.Binding = binding
+	location, err := cfg.Location()
+	if err != nil {
+		return err
+	}
+	req.Location = location
+	req.RequestTimestamp = clockskew.Default.Compensate(cfg.SupplierAddress, requestTimestamp.In(location))
+	req.RequestorRef = cfg.SubscriberRef
+	req.MessageIdentifier, err = messageid.New(cfg.MessageIdPattern, cfg.SubscriberRef, "ResponseMessage", req.RequestTimestamp)
+	if err != nil {
+		return err
+	}
+	req.PreviewInterval = PREVIEW_INTERVAL
+	req.LineRefs = lineRefs
+	req.SupplierAddress = *supplierAddressUrl
+	return nil
+}
+
+func (req *GetEstimatedTimetableRequest) generateHttpReq() (*http.Request, string, error) {
+	tmpl, err := template.ParseFiles("./template/getestimatedtimetable-request.tmpl")
+	if err != nil {
+		return nil, "", fmt.Errorf("error parsing template: %s", err)
+	}
+	payloadBuffer := &bytes.Buffer{}
+	err = tmpl.ExecuteTemplate(payloadBuffer, req.Binding.PayloadTemplateName(), req)
+	if err != nil {
+		return nil, "", fmt.Errorf("error building template: %s", err)
+	}
+	return req.Binding.NewHttpRequest(req.SupplierAddress.String(), "GetEstimatedTimetable", payloadBuffer.String())
+}
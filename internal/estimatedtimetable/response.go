@@ -0,0 +1,103 @@
+package estimatedtimetable
+
+import (
+	"encoding/xml"
+
+	siri_time "github.com/julienbt/siri-sm/internal/common/time"
+	"github.com/julienbt/siri-sm/internal/getstopmonitoring"
+	"github.com/julienbt/siri-sm/internal/siri"
+)
+
+var ESTIMATED_TIMETABLE_DELIVERY_PATH = siri.AnswerPath{
+	Soap: []string{"GetEstimatedTimetableResponse", "Answer", "EstimatedTimetableDelivery"},
+	Raw:  []string{"ServiceDelivery", "EstimatedTimetableDelivery"},
+}
+
+type EstimatedTimetableDelivery struct {
+	XMLName                       xml.Name                       `xml:"EstimatedTimetableDelivery"`
+	ResponseTimestamp             siri_time.Time                 `xml:"ResponseTimestamp"`
+	Status                        *bool                          `xml:"Status"`
+	EstimatedJourneyVersionFrames []EstimatedJourneyVersionFrame `xml:"EstimatedJourneyVersionFrame"`
+}
+
+// EstimatedVehicleJourneys returns the journeys of all the version frames
+func (d *EstimatedTimetableDelivery) EstimatedVehicleJourneys() []EstimatedVehicleJourney {
+	journeys := make([]EstimatedVehicleJourney, 0)
+	for _, frame := range d.EstimatedJourneyVersionFrames {
+		journeys = append(journeys, frame.EstimatedVehicleJourneys...)
+	}
+	return journeys
+}
+
+type EstimatedJourneyVersionFrame struct {
+	XMLName                  xml.Name                  `xml:"EstimatedJourneyVersionFrame"`
+	RecordedAtTime           siri_time.Time            `xml:"RecordedAtTime"`
+	EstimatedVehicleJourneys []EstimatedVehicleJourney `xml:"EstimatedVehicleJourney"`
+}
+
+type EstimatedVehicleJourney struct {
+	XMLName                 xml.Name                                  `xml:"EstimatedVehicleJourney"`
+	RecordedAtTime          siri_time.Time                            `xml:"RecordedAtTime"`
+	LineRef                 getstopmonitoring.LineRef                 `xml:"LineRef"`
+	DirectionRef            string                                    `xml:"DirectionRef"`
+	FramedVehicleJourneyRef getstopmonitoring.FramedVehicleJourneyRef `xml:"FramedVehicleJourneyRef"`
+	DatedVehicleJourneyRef  string                                    `xml:"DatedVehicleJourneyRef"`
+	PublishedLineName       string                                    `xml:"PublishedLineName"`
+	OperatorRef             string                                    `xml:"OperatorRef"`
+	OriginName              string                                    `xml:"OriginName"`
+	DestinationRef          string                                    `xml:"DestinationRef"`
+	DestinationName         string                                    `xml:"DestinationName"`
+	Cancellation            bool                                      `xml:"Cancellation"`
+	ExtraJourney            bool                                      `xml:"ExtraJourney"`
+	Monitored               bool                                      `xml:"Monitored"`
+	RecordedCalls           []RecordedCall                            `xml:"RecordedCalls>RecordedCall"`
+	EstimatedCalls          []EstimatedCall                           `xml:"EstimatedCalls>EstimatedCall"`
+	IsCompleteStopSequence  bool                                      `xml:"IsCompleteStopSequence"`
+}
+
+// VehicleJourneyRef is the `DatedVehicleJourneyRef` of the `FramedVehicleJourneyRef`,
+// or the one of the journey when not framed
+func (j *EstimatedVehicleJourney) VehicleJourneyRef() string {
+	if j.FramedVehicleJourneyRef.DatedVehicleJourneyRef != "" {
+		return j.FramedVehicleJourneyRef.DatedVehicleJourneyRef
+	}
+	return j.DatedVehicleJourneyRef
+}
+
+// RecordedCall is a call already made by the vehicle
+type RecordedCall struct {
+	XMLName             xml.Name                       `xml:"RecordedCall"`
+	StopPointRef        getstopmonitoring.StopPointRef `xml:"StopPointRef"`
+	Order               int                            `xml:"Order"`
+	StopPointName       string                         `xml:"StopPointName"`
+	Cancellation        bool                           `xml:"Cancellation"`
+	AimedArrivalTime    siri_time.Time                 `xml:"AimedArrivalTime"`
+	ActualArrivalTime   siri_time.Time                 `xml:"ActualArrivalTime"`
+	AimedDepartureTime  siri_time.Time                 `xml:"AimedDepartureTime"`
+	ActualDepartureTime siri_time.Time                 `xml:"ActualDepartureTime"`
+}
+
+type EstimatedCall struct {
+	XMLName               xml.Name                       `xml:"EstimatedCall"`
+	StopPointRef          getstopmonitoring.StopPointRef `xml:"StopPointRef"`
+	Order                 int                            `xml:"Order"`
+	StopPointName         string                         `xml:"StopPointName"`
+	VisitNumber           int                            `xml:"VisitNumber"`
+	Cancellation          bool                           `xml:"Cancellation"`
+	ExtraCall             bool                           `xml:"ExtraCall"`
+	DestinationDisplay    string                         `xml:"DestinationDisplay"`
+	AimedArrivalTime      siri_time.Time                 `xml:"AimedArrivalTime"`
+	ExpectedArrivalTime   siri_time.Time                 `xml:"ExpectedArrivalTime"`
+	ArrivalStatus         string                         `xml:"ArrivalStatus"`
+	ArrivalPlatformName   string                         `xml:"ArrivalPlatformName"`
+	AimedDepartureTime    siri_time.Time                 `xml:"AimedDepartureTime"`
+	ExpectedDepartureTime siri_time.Time                 `xml:"ExpectedDepartureTime"`
+	DepartureStatus       string                         `xml:"DepartureStatus"`
+	DeparturePlatformName string                         `xml:"DeparturePlatformName"`
+}
+
+func (ec *EstimatedCall) IsCancelled() bool {
+	return ec.Cancellation ||
+		ec.DepartureStatus == getstopmonitoring.CALL_STATUS_CANCELLED ||
+		(ec.DepartureStatus == "" && ec.ArrivalStatus == getstopmonitoring.CALL_STATUS_CANCELLED)
+}
@@ -0,0 +1,68 @@
+package estimatedtimetable
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/julienbt/siri-sm/internal/getstopmonitoring"
+	"github.com/julienbt/siri-sm/internal/siri"
+)
+
+var testDataDir string
+
+func TestMain(m *testing.M) {
+
+	testDataDir = os.Getenv("SIRISM_TEST_DATA_DIR")
+	if testDataDir == "" {
+		panic("$SIRISM_TEST_DATA_DIR isn't set")
+	}
+
+	os.Exit(m.Run())
+}
+
+func TestEstimatedTimetableDeliveryDecode(t *testing.T) {
+	require := require.New(t)
+
+	htmlRespBody, err := ioutil.ReadFile(
+		fmt.Sprintf(
+			"%s/examples/ET_RESP_000.xml",
+			testDataDir,
+		),
+	)
+	require.Nil(err)
+
+	delivery := EstimatedTimetableDelivery{}
+	err = siri.BINDING_SOAP_11.DecodeAnswer(htmlRespBody, ESTIMATED_TIMETABLE_DELIVERY_PATH, &delivery)
+	require.Nil(err)
+	require.NotNil(delivery.Status)
+	require.True(*delivery.Status)
+
+	journeys := delivery.EstimatedVehicleJourneys()
+	require.Len(journeys, 1)
+	journey := journeys[0]
+	require.Equal(getstopmonitoring.LineRef("L1"), journey.LineRef)
+	require.Equal("ILEVIA:VehicleJourney:BP:4242:LOC", journey.VehicleJourneyRef())
+	require.Equal("2022-05-25", journey.FramedVehicleJourneyRef.DataFrameRef)
+	require.Equal("Lezennes", journey.DestinationName)
+	require.True(journey.Monitored)
+	require.False(journey.Cancellation)
+
+	require.Len(journey.RecordedCalls, 1)
+	require.Equal(getstopmonitoring.StopPointRef("CAR001"), journey.RecordedCalls[0].StopPointRef)
+
+	require.Len(journey.EstimatedCalls, 2)
+	call := journey.EstimatedCalls[0]
+	require.Equal(getstopmonitoring.StopPointRef("CAS001"), call.StopPointRef)
+	require.Equal(2, call.Order)
+	require.Equal(
+		90*time.Second,
+		time.Time(call.ExpectedDepartureTime).Sub(time.Time(call.AimedDepartureTime)),
+	)
+	require.False(call.IsCancelled())
+	require.True(journey.EstimatedCalls[1].IsCancelled())
+}
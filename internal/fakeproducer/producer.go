@@ -0,0 +1,531 @@
+// Package fakeproducer is a scriptable SIRI producer answering CheckStatus,
+// GetStopMonitoring, Subscribe and DeleteSubscription, to develop and test
+// without a real supplier
+package fakeproducer
+
+import (
+	"bytes"
+	"embed"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/julienbt/siri-sm/internal/siri"
+)
+
+// Operation is a SIRI request handled by the producer
+type Operation string
+
+const (
+	OPERATION_CHECK_STATUS        Operation = "CheckStatus"
+	OPERATION_GET_STOP_MONITORING Operation = "GetStopMonitoring"
+	OPERATION_SUBSCRIBE           Operation = "Subscribe"
+	OPERATION_DELETE_SUBSCRIPTION Operation = "DeleteSubscription"
+)
+
+// Operations are the operations handled by the producer
+var Operations = []Operation{
+	OPERATION_CHECK_STATUS,
+	OPERATION_GET_STOP_MONITORING,
+	OPERATION_SUBSCRIBE,
+	OPERATION_DELETE_SUBSCRIPTION,
+}
+
+const SIRI_TIME_LAYOUT string = "2006-01-02T15:04:05.000Z07:00"
+
+//go:embed template/responses.tmpl
+var templateFS embed.FS
+
+var responseTemplate = template.Must(
+	template.New("responses.tmpl").
+		Funcs(template.FuncMap{"siriTime": func(t time.Time) string { return t.Format(SIRI_TIME_LAYOUT) }}).
+		ParseFS(templateFS, "template/responses.tmpl"),
+)
+
+// Response scripts the answer to a request, the zero value is a successful
+// answer generated by the producer
+type Response struct {
+	Body        []byte        // replied as-is when set (e.g. a file of `data/examples`)
+	StatusCode  int           // HTTP status code, 200 by default
+	Latency     time.Duration // delay before answering
+	Fault       string        // reason of a SOAP fault (HTTP 500)
+	StatusFalse bool          // `Status` false in the generated answer
+}
+
+// Visit is a stop visit served by GetStopMonitoring and NotifyStopMonitoring
+type Visit struct {
+	ItemIdentifier         string
+	MonitoringRef          string // e.g. `ILEVIA:StopPoint:BP:CAS001:LOC`
+	LineRef                string // e.g. `ILEVIA:Line:BP:L1:LOC`
+	DirectionName          string // "ALLER" or "RETOUR"
+	DataFrameRef           string
+	DatedVehicleJourneyRef string
+	DestinationRef         string
+	DestinationName        string
+	AimedDepartureTime     time.Time
+	ExpectedDepartureTime  time.Time
+	DepartureStatus        string
+}
+
+// Subscription is recorded from a Subscribe request
+type Subscription struct {
+	Service                string // local name of the request, e.g. `StopMonitoringSubscriptionRequest`
+	SubscriberRef          string
+	SubscriptionIdentifier string
+	MonitoringRef          string
+	ConsumerAddress        string
+	Binding                siri.Binding
+	Status                 bool
+}
+
+// Producer is an `http.Handler`, it can be served by `httptest.NewServer`
+type Producer struct {
+	ProducerRef        string
+	ServiceStartedTime time.Time
+	// Visits are filtered by the `MonitoringRef` of the requests
+	Visits []Visit
+	// Synthetic generates visits for the stops without `Visits`
+	Synthetic bool
+	// Now is the clock of the producer, `time.Now` by default
+	Now func() time.Time
+
+	mu            sync.Mutex
+	responses     map[Operation]Response
+	queues        map[Operation][]Response
+	calls         map[Operation]int
+	subscriptions []Subscription
+}
+
+func New(producerRef string) *Producer {
+	return &Producer{
+		ProducerRef:        producerRef,
+		ServiceStartedTime: time.Now(),
+		Now:                time.Now,
+		responses:          make(map[Operation]Response),
+		queues:             make(map[Operation][]Response),
+		calls:              make(map[Operation]int),
+	}
+}
+
+// SetResponse scripts the answer to every request of the operation
+func (p *Producer) SetResponse(op Operation, r Response) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.responses[op] = r
+}
+
+// Enqueue scripts the answers to the next requests of the operation, the
+// response set by `SetResponse` is used once the queue is empty
+func (p *Producer) Enqueue(op Operation, rs ...Response) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.queues[op] = append(p.queues[op], rs...)
+}
+
+// Calls returns the number of requests received for the operation
+func (p *Producer) Calls(op Operation) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.calls[op]
+}
+
+// Subscriptions returns the subscriptions received so far
+func (p *Producer) Subscriptions() []Subscription {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]Subscription(nil), p.subscriptions...)
+}
+
+func (p *Producer) nextResponse(op Operation) Response {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.calls[op]++
+	if queue := p.queues[op]; len(queue) > 0 {
+		p.queues[op] = queue[1:]
+		return queue[0]
+	}
+	return p.responses[op]
+}
+
+func (p *Producer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "only POST is supported", http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("unreadable request body: %s", err), http.StatusBadRequest)
+		return
+	}
+	binding, name, err := siri.DetectMessage(body)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("unknown request: %s", err), http.StatusBadRequest)
+		return
+	}
+	op, ok := operationOf(name)
+	if !ok {
+		p.writeFault(w, binding, fmt.Sprintf("unsupported operation: %s", name))
+		return
+	}
+
+	response := p.nextResponse(op)
+	if response.Latency > 0 {
+		select {
+		case <-time.After(response.Latency):
+		case <-r.Context().Done():
+			return
+		}
+	}
+	if response.Fault != "" {
+		p.writeFault(w, binding, response.Fault)
+		return
+	}
+	statusCode := response.StatusCode
+	if statusCode == 0 {
+		statusCode = http.StatusOK
+	}
+	respBody := response.Body
+	if respBody == nil {
+		respBody, err = p.answer(op, binding, body, !response.StatusFalse)
+		if err != nil {
+			p.writeFault(w, binding, err.Error())
+			return
+		}
+	}
+	w.Header().Set("Content-Type", binding.ContentType())
+	w.WriteHeader(statusCode)
+	w.Write(respBody)
+}
+
+func operationOf(name string) (Operation, bool) {
+	switch name {
+	case "CheckStatus", "CheckStatusRequest":
+		return OPERATION_CHECK_STATUS, true
+	case "GetStopMonitoring", "ServiceRequest":
+		return OPERATION_GET_STOP_MONITORING, true
+	case "Subscribe", "SubscriptionRequest":
+		return OPERATION_SUBSCRIBE, true
+	case "DeleteSubscription", "TerminateSubscriptionRequest":
+		return OPERATION_DELETE_SUBSCRIPTION, true
+	}
+	return "", false
+}
+
+// responseData is the data of the response templates
+type responseData struct {
+	ResponseTimestamp         time.Time
+	ProducerRef               string
+	RequestMessageRef         string
+	ResponseMessageIdentifier string
+	Status                    bool
+	ServiceStartedTime        time.Time
+	SubscriberRef             string
+	SubscriptionRef           string
+	MonitoringRef             string
+	Visits                    []Visit
+	Subscriptions             []responseStatus
+	Terminations              []responseStatus
+}
+
+type responseStatus struct {
+	ResponseTimestamp time.Time
+	RequestMessageRef string
+	SubscriberRef     string
+	SubscriptionRef   string
+	Status            bool
+	ValidUntil        time.Time
+}
+
+func (p *Producer) answer(op Operation, binding siri.Binding, reqBody []byte, status bool) ([]byte, error) {
+	now := p.Now()
+	data := responseData{
+		ResponseTimestamp:  now,
+		ProducerRef:        p.ProducerRef,
+		RequestMessageRef:  firstElementText(reqBody, "MessageIdentifier"),
+		Status:             status,
+		ServiceStartedTime: p.ServiceStartedTime,
+	}
+	switch op {
+	case OPERATION_GET_STOP_MONITORING:
+		data.MonitoringRef = firstElementText(reqBody, "MonitoringRef")
+		data.Visits = p.visits(data.MonitoringRef, firstElementText(reqBody, "LineRef"), now)
+	case OPERATION_SUBSCRIBE:
+		subscriptions, err := readSubscriptions(reqBody, binding, status)
+		if err != nil {
+			return nil, err
+		}
+		p.mu.Lock()
+		p.subscriptions = append(p.subscriptions, subscriptions...)
+		p.mu.Unlock()
+		for _, s := range subscriptions {
+			data.Subscriptions = append(data.Subscriptions, responseStatus{
+				ResponseTimestamp: now,
+				RequestMessageRef: s.SubscriptionIdentifier,
+				SubscriberRef:     s.SubscriberRef,
+				SubscriptionRef:   s.SubscriptionIdentifier,
+				Status:            status,
+				ValidUntil:        now.AddDate(0, 0, 1),
+			})
+		}
+	case OPERATION_DELETE_SUBSCRIPTION:
+		data.Terminations = p.deleteSubscriptions(reqBody, now, status)
+	}
+	return render(binding, string(op), data)
+}
+
+func render(binding siri.Binding, name string, data interface{}) ([]byte, error) {
+	payload, err := renderPayload(binding, name, data)
+	if err != nil {
+		return nil, err
+	}
+	envelope, err := binding.WrapPayload(payload)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(envelope), nil
+}
+
+func renderPayload(binding siri.Binding, name string, data interface{}) (string, error) {
+	payload := &bytes.Buffer{}
+	err := responseTemplate.ExecuteTemplate(payload, binding.PayloadTemplateName()+":"+name, data)
+	if err != nil {
+		return "", fmt.Errorf("error building template: %s", err)
+	}
+	return payload.String(), nil
+}
+
+func (p *Producer) writeFault(w http.ResponseWriter, binding siri.Binding, reason string) {
+	if !binding.IsSoap() {
+		http.Error(w, reason, http.StatusInternalServerError)
+		return
+	}
+	payload := &bytes.Buffer{}
+	err := responseTemplate.ExecuteTemplate(payload, string(binding)+":Fault", reason)
+	if err != nil {
+		http.Error(w, reason, http.StatusInternalServerError)
+		return
+	}
+	envelope, _ := binding.WrapPayload(payload.String())
+	w.Header().Set("Content-Type", binding.ContentType())
+	w.WriteHeader(http.StatusInternalServerError)
+	w.Write([]byte(envelope))
+}
+
+func (p *Producer) visits(monitoringRef string, lineRef string, now time.Time) []Visit {
+	visits := make([]Visit, 0)
+	for _, v := range p.Visits {
+		if v.MonitoringRef != monitoringRef {
+			continue
+		}
+		if lineRef != "" && v.LineRef != lineRef {
+			continue
+		}
+		visits = append(visits, v)
+	}
+	if len(visits) == 0 && p.Synthetic && monitoringRef != "" {
+		for _, v := range SyntheticVisits(p.ProducerRef, monitoringRef, now) {
+			if lineRef == "" || v.LineRef == lineRef {
+				visits = append(visits, v)
+			}
+		}
+	}
+	return visits
+}
+
+// SyntheticVisits returns a departure every 10 minutes during the next hour
+func SyntheticVisits(producerRef string, monitoringRef string, now time.Time) []Visit {
+	const NUMBER_OF_VISITS int = 6
+	const HEADWAY time.Duration = 10 * time.Minute
+	const DELAY time.Duration = time.Minute
+	start := now.Truncate(HEADWAY)
+	visits := make([]Visit, 0, NUMBER_OF_VISITS)
+	for i := 1; i <= NUMBER_OF_VISITS; i++ {
+		aimed := start.Add(time.Duration(i) * HEADWAY)
+		journeyRef := fmt.Sprintf("%s:VehicleJourney::%s_%d:LOC", producerRef, aimed.Format("1504"), i)
+		visits = append(visits, Visit{
+			ItemIdentifier:         fmt.Sprintf("%s-%s", journeyRef, monitoringRef),
+			MonitoringRef:          monitoringRef,
+			LineRef:                producerRef + ":Line:BP:L1:LOC",
+			DirectionName:          "ALLER",
+			DataFrameRef:           aimed.Format("2006-01-02"),
+			DatedVehicleJourneyRef: journeyRef,
+			DestinationName:        "Terminus",
+			AimedDepartureTime:     aimed,
+			ExpectedDepartureTime:  aimed.Add(DELAY),
+			DepartureStatus:        "delayed",
+		})
+	}
+	return visits
+}
+
+// NotifyStopMonitoring pushes the visits of every StopMonitoring subscription
+// to its consumer address, and returns the first error
+func (p *Producer) NotifyStopMonitoring() error {
+	var firstErr error
+	for _, s := range p.Subscriptions() {
+		if !s.Status || s.MonitoringRef == "" || s.ConsumerAddress == "" {
+			continue
+		}
+		err := p.notify(s)
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+func (p *Producer) notify(s Subscription) error {
+	now := p.Now()
+	data := responseData{
+		ResponseTimestamp:         now,
+		ProducerRef:               p.ProducerRef,
+		ResponseMessageIdentifier: p.ProducerRef + ":ResponseMessage:" + now.Format("20060102_150405.000"),
+		Status:                    true,
+		SubscriberRef:             s.SubscriberRef,
+		SubscriptionRef:           s.SubscriptionIdentifier,
+		MonitoringRef:             s.MonitoringRef,
+		Visits:                    p.visits(s.MonitoringRef, "", now),
+	}
+	payload, err := renderPayload(s.Binding, "NotifyStopMonitoring", data)
+	if err != nil {
+		return err
+	}
+	httpReq, _, err := s.Binding.NewHttpRequest(s.ConsumerAddress, "NotifyStopMonitoring", payload)
+	if err != nil {
+		return err
+	}
+	resp, err := siri.SoapCall(httpReq)
+	if err != nil {
+		return fmt.Errorf("notification of %s: %s", s.SubscriptionIdentifier, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("notification of %s: bad http-response status: %s", s.SubscriptionIdentifier, resp.Status)
+	}
+	return nil
+}
+
+// deleteSubscriptions terminates the requested subscriptions, or all the
+// subscriptions of the subscriber with `All`, an unknown one is answered
+// with `Status` false
+func (p *Producer) deleteSubscriptions(reqBody []byte, now time.Time, status bool) []responseStatus {
+	subscriberRef := firstElementText(reqBody, "SubscriberRef")
+	all := false
+	refs := make([]string, 0)
+	forEachElement(reqBody, func(d *xml.Decoder, start *xml.StartElement) error {
+		switch start.Name.Local {
+		case "All":
+			all = true
+		case "SubscriptionRef":
+			var ref string
+			if err := d.DecodeElement(&ref, start); err != nil {
+				return err
+			}
+			refs = append(refs, strings.TrimSpace(ref))
+		}
+		return nil
+	})
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if all {
+		refs = refs[:0]
+		for _, s := range p.subscriptions {
+			if s.SubscriberRef == subscriberRef {
+				refs = append(refs, s.SubscriptionIdentifier)
+			}
+		}
+	}
+	terminations := make([]responseStatus, 0, len(refs))
+	for _, ref := range refs {
+		known := false
+		if status {
+			kept := p.subscriptions[:0]
+			for _, s := range p.subscriptions {
+				if s.SubscriptionIdentifier == ref && s.SubscriberRef == subscriberRef {
+					known = true
+					continue
+				}
+				kept = append(kept, s)
+			}
+			p.subscriptions = kept
+		}
+		terminations = append(terminations, responseStatus{
+			ResponseTimestamp: now,
+			SubscriberRef:     subscriberRef,
+			SubscriptionRef:   ref,
+			Status:            known,
+		})
+	}
+	return terminations
+}
+
+type subscriptionRequest struct {
+	SubscriberRef          string `xml:"SubscriberRef"`
+	SubscriptionIdentifier string `xml:"SubscriptionIdentifier"`
+	MonitoringRef          string `xml:"StopMonitoringRequest>MonitoringRef"`
+}
+
+func readSubscriptions(body []byte, binding siri.Binding, status bool) ([]Subscription, error) {
+	consumerAddress := firstElementText(body, "ConsumerAddress")
+	subscriptions := make([]Subscription, 0)
+	err := forEachElement(body, func(d *xml.Decoder, start *xml.StartElement) error {
+		name := start.Name.Local
+		if name == "SubscriptionRequest" || !strings.HasSuffix(name, "SubscriptionRequest") {
+			return nil
+		}
+		req := subscriptionRequest{}
+		if err := d.DecodeElement(&req, start); err != nil {
+			return err
+		}
+		subscriptions = append(subscriptions, Subscription{
+			Service:                name,
+			SubscriberRef:          req.SubscriberRef,
+			SubscriptionIdentifier: req.SubscriptionIdentifier,
+			MonitoringRef:          req.MonitoringRef,
+			ConsumerAddress:        consumerAddress,
+			Binding:                binding,
+			Status:                 status,
+		})
+		return nil
+	})
+	return subscriptions, err
+}
+
+// firstElementText returns the text of the first element with the local
+// name, at any depth
+func firstElementText(body []byte, localName string) string {
+	var text string
+	forEachElement(body, func(d *xml.Decoder, start *xml.StartElement) error {
+		if text != "" || start.Name.Local != localName {
+			return nil
+		}
+		return d.DecodeElement(&text, start)
+	})
+	return strings.TrimSpace(text)
+}
+
+// forEachElement calls fn on each start element, fn may decode the element
+func forEachElement(body []byte, fn func(d *xml.Decoder, start *xml.StartElement) error) error {
+	d := xml.NewDecoder(bytes.NewReader(body))
+	for {
+		token, err := d.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if start, ok := token.(xml.StartElement); ok {
+			if err := fn(d, &start); err != nil {
+				return err
+			}
+		}
+	}
+}
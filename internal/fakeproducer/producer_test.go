@@ -0,0 +1,366 @@
+package fakeproducer
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/julienbt/siri-sm/internal/checkstatus"
+	"github.com/julienbt/siri-sm/internal/config"
+	"github.com/julienbt/siri-sm/internal/getstopmonitoring"
+	"github.com/julienbt/siri-sm/internal/reconcile"
+	"github.com/julienbt/siri-sm/internal/siri"
+	"github.com/julienbt/siri-sm/internal/subscribe"
+	"github.com/julienbt/siri-sm/internal/unsubscribe"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+)
+
+var testDataDir string
+
+const MONITORING_REF string = "ILEVIA:StopPoint:BP:CAS001:LOC"
+
+func TestMain(m *testing.M) {
+
+	testDataDir = os.Getenv("SIRISM_TEST_DATA_DIR")
+	if testDataDir == "" {
+		panic("$SIRISM_TEST_DATA_DIR isn't set")
+	}
+	// The requests are built from the templates of `./template`
+	err := os.Chdir(filepath.Join(testDataDir, ".."))
+	if err != nil {
+		panic(err)
+	}
+
+	os.Exit(m.Run())
+}
+
+func newLogger() *logrus.Entry {
+	logger := logrus.New()
+	logger.SetOutput(ioutil.Discard)
+	return logrus.NewEntry(logger)
+}
+
+func newCheckStatusConfig(address string, binding siri.Binding) config.ConfigCheckStatus {
+	return config.ConfigCheckStatus{
+		SupplierAddress: address,
+		SubscriberRef:   "TEST",
+		Binding:         string(binding),
+	}
+}
+
+func newVisit(lineRef string, aimed time.Time) Visit {
+	return Visit{
+		ItemIdentifier:         "ILEVIA:Item::" + lineRef,
+		MonitoringRef:          MONITORING_REF,
+		LineRef:                lineRef,
+		DirectionName:          "ALLER",
+		DataFrameRef:           "2022-08-30",
+		DatedVehicleJourneyRef: "ILEVIA:VehicleJourney::1:LOC",
+		DestinationRef:         "ILEVIA:StopPoint:BP:CAS002:LOC",
+		DestinationName:        "Gare",
+		AimedDepartureTime:     aimed,
+		ExpectedDepartureTime:  aimed.Add(time.Minute),
+		DepartureStatus:        "delayed",
+	}
+}
+
+func TestCheckStatus(t *testing.T) {
+	for _, binding := range []siri.Binding{siri.BINDING_SOAP_11, siri.BINDING_SOAP_12, siri.BINDING_RAW} {
+		t.Run(string(binding), func(t *testing.T) {
+			require := require.New(t)
+			producer := New("ILEVIA")
+			server := httptest.NewServer(producer)
+			defer server.Close()
+			cfg := newCheckStatusConfig(server.URL, binding)
+			now := time.Now()
+
+			_, _, _, err := checkstatus.CheckStatus(cfg, newLogger(), &now)
+			require.Nil(err)
+
+			producer.SetResponse(OPERATION_CHECK_STATUS, Response{StatusFalse: true})
+			_, _, _, err = checkstatus.CheckStatus(cfg, newLogger(), &now)
+			require.NotNil(err)
+
+			producer.SetResponse(OPERATION_CHECK_STATUS, Response{Fault: "maintenance"})
+			_, _, htmlRespBody, err := checkstatus.CheckStatus(cfg, newLogger(), &now)
+			require.NotNil(err)
+			require.Contains(string(htmlRespBody), "maintenance")
+
+			require.Equal(3, producer.Calls(OPERATION_CHECK_STATUS))
+		})
+	}
+}
+
+func TestEnqueueAndLatency(t *testing.T) {
+	require := require.New(t)
+	producer := New("ILEVIA")
+	producer.Enqueue(OPERATION_CHECK_STATUS,
+		Response{StatusCode: http.StatusServiceUnavailable},
+		Response{Latency: 50 * time.Millisecond},
+	)
+	server := httptest.NewServer(producer)
+	defer server.Close()
+	cfg := newCheckStatusConfig(server.URL, siri.BINDING_SOAP_11)
+	now := time.Now()
+
+	_, _, _, err := checkstatus.CheckStatus(cfg, newLogger(), &now)
+	require.IsType(&siri.RemoteError{}, err)
+
+	start := time.Now()
+	_, _, _, err = checkstatus.CheckStatus(cfg, newLogger(), &now)
+	require.Nil(err)
+	require.GreaterOrEqual(time.Since(start), 50*time.Millisecond)
+
+	_, _, _, err = checkstatus.CheckStatus(cfg, newLogger(), &now)
+	require.Nil(err)
+}
+
+func TestGetStopMonitoring(t *testing.T) {
+	for _, binding := range []siri.Binding{siri.BINDING_SOAP_11, siri.BINDING_RAW} {
+		t.Run(string(binding), func(t *testing.T) {
+			require := require.New(t)
+			now := time.Date(2022, 8, 30, 8, 0, 0, 0, time.UTC)
+			producer := New("ILEVIA")
+			producer.Visits = []Visit{
+				newVisit("ILEVIA:Line:BP:L1:LOC", now.Add(5*time.Minute)),
+				newVisit("ILEVIA:Line:BP:L2:LOC", now.Add(10*time.Minute)),
+			}
+			server := httptest.NewServer(producer)
+			defer server.Close()
+			cfg := newCheckStatusConfig(server.URL, binding)
+
+			visits, _, _, err := getstopmonitoring.GetStopMonitoring(cfg, newLogger(), &now, MONITORING_REF, nil)
+			require.Nil(err)
+			require.Len(visits, 2)
+			require.Equal(getstopmonitoring.StopPointRef("CAS001"), visits[0].MonitoringRef)
+
+			visits, _, _, err = getstopmonitoring.GetStopMonitoring(cfg, newLogger(), &now, MONITORING_REF, []string{"ILEVIA:Line:BP:L2:LOC"})
+			require.Nil(err)
+			require.Len(visits, 1)
+			require.Equal(getstopmonitoring.LineRef("L2"), visits[0].MonitoredVehicleJourney.LineRef)
+
+			visits, _, _, err = getstopmonitoring.GetStopMonitoring(cfg, newLogger(), &now, "ILEVIA:StopPoint:BP:CAS002:LOC", nil)
+			require.Nil(err)
+			require.Len(visits, 0)
+		})
+	}
+}
+
+func TestSyntheticVisits(t *testing.T) {
+	require := require.New(t)
+	now := time.Date(2022, 8, 30, 8, 3, 0, 0, time.UTC)
+	producer := New("ILEVIA")
+	producer.Synthetic = true
+	server := httptest.NewServer(producer)
+	defer server.Close()
+	cfg := newCheckStatusConfig(server.URL, siri.BINDING_SOAP_12)
+
+	visits, _, _, err := getstopmonitoring.GetStopMonitoring(cfg, newLogger(), &now, MONITORING_REF, nil)
+	require.Nil(err)
+	require.Len(visits, 6)
+}
+
+func newSubscribeConfig(t *testing.T, supplierAddress string, consumerAddress string) config.ConfigSubscribe {
+	stopListFile := filepath.Join(t.TempDir(), "stops.txt")
+	err := ioutil.WriteFile(stopListFile, []byte("CAS001\nCAS002\n"), 0644)
+	require.Nil(t, err)
+	return config.ConfigSubscribe{
+		SupplierAddress: supplierAddress,
+		SubscriberRef:   "TEST",
+		ProducerRef:     "ILEVIA",
+		ConsumerAddress: consumerAddress,
+		Binding:         string(siri.BINDING_SOAP_11),
+		Service:         config.SERVICE_STOP_MONITORING,
+		StopListFile:    stopListFile,
+	}
+}
+
+func TestSubscribe(t *testing.T) {
+	require := require.New(t)
+	producer := New("ILEVIA")
+	server := httptest.NewServer(producer)
+	defer server.Close()
+	cfg := newSubscribeConfig(t, server.URL, "http://consumer.test/notify")
+	now := time.Now()
+
+	result, _, _, err := subscribe.Subscribe(cfg, newLogger(), &now)
+	require.Nil(err)
+	require.Len(result.ResponseStatus, 2)
+	for _, status := range result.ResponseStatus {
+		require.True(status.Status)
+	}
+	subscriptions := producer.Subscriptions()
+	require.Len(subscriptions, 2)
+	require.Equal("ILEVIA:StopPoint:BP:CAS001:LOC", subscriptions[0].MonitoringRef)
+	require.Equal("http://consumer.test/notify", subscriptions[0].ConsumerAddress)
+	require.Equal("StopMonitoringSubscriptionRequest", subscriptions[0].Service)
+
+	producer.SetResponse(OPERATION_SUBSCRIBE, Response{StatusFalse: true})
+	result, _, _, err = subscribe.Subscribe(cfg, newLogger(), &now)
+	require.Nil(err)
+	require.False(result.ResponseStatus[0].Status)
+}
+
+func TestSubscribeInChunks(t *testing.T) {
+	require := require.New(t)
+	producer := New("ILEVIA")
+	calls := make(chan string, 4)
+	// The chunk of the second stop fails
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		calls <- firstElementText(body, "MessageIdentifier")
+		if strings.Contains(string(body), "CAS002") {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+		producer.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+	cfg := newSubscribeConfig(t, server.URL, "http://consumer.test/notify")
+	cfg.ChunkSize = 1
+	now := time.Now()
+
+	result, _, _, err := subscribe.Subscribe(cfg, newLogger(), &now)
+	require.Nil(err)
+	require.Len(calls, 2)
+	require.NotEqual(<-calls, <-calls)
+	require.Len(result.ResponseStatus, 2)
+	require.True(result.ResponseStatus[0].Status)
+	require.False(result.ResponseStatus[1].Status)
+	require.Equal("TEST:Subscription:arret_CAS002:LOC", result.ResponseStatus[1].SubscriptionRef)
+	require.Contains(result.ResponseStatus[1].ErrorText, "500")
+	require.Equal("ILEVIA:StopPoint:BP:CAS002:LOC", result.Targets["TEST:Subscription:arret_CAS002:LOC"].MonitoringRef)
+	require.Len(producer.Subscriptions(), 1)
+
+	// Every chunk failing fails the call
+	producer.SetResponse(OPERATION_SUBSCRIBE, Response{Fault: "overloaded"})
+	_, _, _, err = subscribe.Subscribe(cfg, newLogger(), &now)
+	require.NotNil(err)
+}
+
+func TestSubscribeFixture(t *testing.T) {
+	require := require.New(t)
+	fixture, err := ioutil.ReadFile(fmt.Sprintf("%s/examples/SUB_RESP_000.xml", testDataDir))
+	require.Nil(err)
+	producer := New("ILEVIA")
+	producer.SetResponse(OPERATION_SUBSCRIBE, Response{Body: fixture})
+	server := httptest.NewServer(producer)
+	defer server.Close()
+	cfg := newSubscribeConfig(t, server.URL, "http://consumer.test/notify")
+	now := time.Now()
+
+	result, _, htmlRespBody, err := subscribe.Subscribe(cfg, newLogger(), &now)
+	require.Nil(err)
+	require.Equal(fixture, htmlRespBody)
+	require.NotEmpty(result.ResponseStatus)
+	require.Equal("KISIO2", result.ResponseStatus[0].SubscriberRef)
+}
+
+func TestNotifyStopMonitoring(t *testing.T) {
+	require := require.New(t)
+	notifications := make(chan []byte, 4)
+	consumer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		notifications <- body
+	}))
+	defer consumer.Close()
+
+	now := time.Date(2022, 8, 30, 8, 0, 0, 0, time.UTC)
+	producer := New("ILEVIA")
+	producer.Now = func() time.Time { return now }
+	producer.Visits = []Visit{newVisit("ILEVIA:Line:BP:L1:LOC", now.Add(5*time.Minute))}
+	server := httptest.NewServer(producer)
+	defer server.Close()
+	cfg := newSubscribeConfig(t, server.URL, consumer.URL)
+
+	_, _, _, err := subscribe.Subscribe(cfg, newLogger(), &now)
+	require.Nil(err)
+	require.Nil(producer.NotifyStopMonitoring())
+	require.Len(notifications, 2)
+
+	delivery := &getstopmonitoring.StopMonitoringDelivery{}
+	found, err := siri.DecodeElementAt(
+		<-notifications,
+		[]string{"Envelope", "Body", "NotifyStopMonitoring", "Notification", "StopMonitoringDelivery"},
+		delivery,
+	)
+	require.Nil(err)
+	require.True(found)
+	require.Equal(getstopmonitoring.StopPointRef("CAS001"), delivery.MonitoringRef)
+	require.Len(delivery.MonitoredStopVisits, 1)
+}
+
+func TestUnsubscribe(t *testing.T) {
+	require := require.New(t)
+	producer := New("ILEVIA")
+	server := httptest.NewServer(producer)
+	defer server.Close()
+	now := time.Now()
+	_, _, _, err := subscribe.Subscribe(newSubscribeConfig(t, server.URL, "http://consumer.test/notify"), newLogger(), &now)
+	require.Nil(err)
+	require.Len(producer.Subscriptions(), 2)
+	cfg := newCheckStatusConfig(server.URL, siri.BINDING_SOAP_11)
+	subscriptionRef := producer.Subscriptions()[0].SubscriptionIdentifier
+
+	statuses, _, _, err := unsubscribe.Unsubscribe(cfg, newLogger(), &now, []string{subscriptionRef, "TEST:Subscription:unknown:LOC"})
+	require.Nil(err)
+	require.Len(statuses, 2)
+	require.True(statuses[0].Status)
+	require.False(statuses[1].Status)
+	require.Equal("Unknown subscription", statuses[1].Error())
+	require.Len(producer.Subscriptions(), 1)
+
+	cfg.Binding = string(siri.BINDING_RAW)
+	statuses, _, _, err = unsubscribe.Unsubscribe(cfg, newLogger(), &now, nil)
+	require.Nil(err)
+	require.Len(statuses, 1)
+	require.True(statuses[0].Status)
+	require.Empty(producer.Subscriptions())
+}
+
+func TestReconcile(t *testing.T) {
+	require := require.New(t)
+	producer := New("ILEVIA")
+	server := httptest.NewServer(producer)
+	defer server.Close()
+	cfg := newSubscribeConfig(t, server.URL, "http://consumer.test/notify")
+	now := time.Now()
+	_, _, _, err := subscribe.SubscribeStops(cfg, newLogger(), &now, []string{"CAS001", "CAT001"})
+	require.Nil(err)
+	active := []reconcile.Subscription{
+		{SubscriptionRef: "TEST:Subscription:arret_CAS001:LOC"},
+		{SubscriptionRef: "TEST:Subscription:arret_CAT001:LOC"},
+	}
+
+	plan, err := reconcile.NewPlan(&cfg, []string{"CAS001", "CAS002"}, active, now)
+	require.Nil(err)
+	plan, _, _, err = reconcile.Apply(cfg, newLogger(), &now, plan)
+	require.Nil(err)
+	require.Equal(0, plan.Failed())
+	subscriptions := producer.Subscriptions()
+	require.Len(subscriptions, 2)
+	require.Equal("TEST:Subscription:arret_CAS001:LOC", subscriptions[0].SubscriptionIdentifier)
+	require.Equal("TEST:Subscription:arret_CAS002:LOC", subscriptions[1].SubscriptionIdentifier)
+
+	active = plan.Active(cfg.SubscriberRef)
+	require.Len(active, 2)
+	require.False(active[1].ValidUntil.IsZero())
+
+	// Nothing is sent once reconciled
+	plan, err = reconcile.NewPlan(&cfg, []string{"CAS001", "CAS002"}, active, now)
+	require.Nil(err)
+	require.Equal(2, plan.Count(reconcile.ACTION_KEEP))
+	_, _, _, err = reconcile.Apply(cfg, newLogger(), &now, plan)
+	require.Nil(err)
+	require.Equal(2, producer.Calls(OPERATION_SUBSCRIBE))
+	require.Equal(1, producer.Calls(OPERATION_DELETE_SUBSCRIPTION))
+}
@@ -0,0 +1,101 @@
+package generalmessage
+
+import (
+	"encoding/xml"
+	"strings"
+	"time"
+
+	siri_time "github.com/julienbt/siri-sm/internal/common/time"
+	"github.com/julienbt/siri-sm/internal/disruption"
+	"github.com/julienbt/siri-sm/internal/siri"
+)
+
+var GENERAL_MESSAGE_DELIVERY_PATH = siri.AnswerPath{
+	Soap: []string{"GetGeneralMessageResponse", "Answer", "GeneralMessageDelivery"},
+	Raw:  []string{"ServiceDelivery", "GeneralMessageDelivery"},
+}
+
+type GeneralMessageDelivery struct {
+	XMLName                     xml.Name                     `xml:"GeneralMessageDelivery"`
+	ResponseTimestamp           siri_time.Time               `xml:"ResponseTimestamp"`
+	Status                      *bool                        `xml:"Status"`
+	GeneralMessages             []GeneralMessage             `xml:"GeneralMessage"`
+	GeneralMessageCancellations []GeneralMessageCancellation `xml:"GeneralMessageCancellation"`
+}
+
+type GeneralMessage struct {
+	XMLName               xml.Name       `xml:"GeneralMessage"`
+	RecordedAtTime        siri_time.Time `xml:"RecordedAtTime"`
+	ItemIdentifier        string         `xml:"ItemIdentifier"`
+	InfoMessageIdentifier string         `xml:"InfoMessageIdentifier"`
+	InfoMessageVersion    int            `xml:"InfoMessageVersion"`
+	InfoChannelRef        string         `xml:"InfoChannelRef"`
+	ValidUntilTime        siri_time.Time `xml:"ValidUntilTime"`
+	Content               Content        `xml:"Content"`
+}
+
+// Content follows the IDF profile of the GeneralMessage `Content`
+type Content struct {
+	LineRefs        []string  `xml:"LineRef"`
+	StopPointRefs   []string  `xml:"StopPointRef"`
+	DestinationRefs []string  `xml:"DestinationRef"`
+	Messages        []Message `xml:"Message"`
+}
+
+type Message struct {
+	MessageType  string `xml:"MessageType"` // e.g. "shortMessage", "longMessage"
+	MessageTexts []Text `xml:"MessageText"`
+}
+
+type Text struct {
+	Lang  string `xml:"lang,attr"`
+	Value string `xml:",chardata"`
+}
+
+type GeneralMessageCancellation struct {
+	XMLName               xml.Name `xml:"GeneralMessageCancellation"`
+	InfoMessageIdentifier string   `xml:"InfoMessageIdentifier"`
+	InfoChannelRef        string   `xml:"InfoChannelRef"`
+}
+
+const (
+	MESSAGE_TYPE_SHORT string = "shortMessage"
+	MESSAGE_TYPE_LONG  string = "longMessage"
+)
+
+// Text returns the first text of the messages of the type, or of any type
+// when there is none of this type
+func (c *Content) Text(messageType string) string {
+	for _, message := range c.Messages {
+		if message.MessageType == messageType && len(message.MessageTexts) > 0 {
+			return strings.TrimSpace(message.MessageTexts[0].Value)
+		}
+	}
+	for _, message := range c.Messages {
+		if len(message.MessageTexts) > 0 {
+			return strings.TrimSpace(message.MessageTexts[0].Value)
+		}
+	}
+	return ""
+}
+
+func (gm *GeneralMessage) Disruption() disruption.Disruption {
+	d := disruption.Disruption{
+		Id:          gm.InfoMessageIdentifier,
+		Source:      disruption.SOURCE_GENERAL_MESSAGE,
+		Summary:     gm.Content.Text(MESSAGE_TYPE_SHORT),
+		Description: gm.Content.Text(MESSAGE_TYPE_LONG),
+		LineRefs:    disruption.ParseLineRefs(gm.Content.LineRefs),
+		StopRefs:    disruption.ParseStopPointRefs(gm.Content.StopPointRefs),
+	}
+	if d.Id == "" {
+		d.Id = gm.ItemIdentifier
+	}
+	if validUntil := time.Time(gm.ValidUntilTime); !validUntil.IsZero() {
+		d.ValidityPeriods = []disruption.Period{{
+			Start: time.Time(gm.RecordedAtTime),
+			End:   validUntil,
+		}}
+	}
+	return d
+}
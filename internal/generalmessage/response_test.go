@@ -0,0 +1,63 @@
+package generalmessage
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/julienbt/siri-sm/internal/disruption"
+	"github.com/julienbt/siri-sm/internal/getstopmonitoring"
+	"github.com/julienbt/siri-sm/internal/siri"
+)
+
+var testDataDir string
+
+func TestMain(m *testing.M) {
+
+	testDataDir = os.Getenv("SIRISM_TEST_DATA_DIR")
+	if testDataDir == "" {
+		panic("$SIRISM_TEST_DATA_DIR isn't set")
+	}
+
+	os.Exit(m.Run())
+}
+
+func TestGeneralMessageDeliveryDecode(t *testing.T) {
+	require := require.New(t)
+
+	htmlRespBody, err := ioutil.ReadFile(
+		fmt.Sprintf(
+			"%s/examples/GM_RESP_000.xml",
+			testDataDir,
+		),
+	)
+	require.Nil(err)
+
+	delivery := GeneralMessageDelivery{}
+	err = siri.BINDING_SOAP_11.DecodeAnswer(htmlRespBody, GENERAL_MESSAGE_DELIVERY_PATH, &delivery)
+	require.Nil(err)
+	require.Len(delivery.GeneralMessages, 1)
+
+	generalMessage := delivery.GeneralMessages[0]
+	require.Equal("Perturbation", generalMessage.InfoChannelRef)
+	require.Equal(2, generalMessage.InfoMessageVersion)
+	require.Equal("FR", generalMessage.Content.Messages[0].MessageTexts[0].Lang)
+
+	d := generalMessage.Disruption()
+	require.Equal("ILEVIA:InfoMessage:GM42:LOC", d.Id)
+	require.Equal(disruption.SOURCE_GENERAL_MESSAGE, d.Source)
+	require.Equal("Arrêt Casino non desservi", d.Summary)
+	require.Equal("En raison de travaux, l'arrêt Casino n'est pas desservi.", d.Description)
+	require.Equal([]getstopmonitoring.LineRef{"L1"}, d.LineRefs)
+	require.Equal([]getstopmonitoring.StopPointRef{"CAS001"}, d.StopRefs)
+	require.Len(d.ValidityPeriods, 1)
+
+	paris, err := time.LoadLocation("Europe/Paris")
+	require.Nil(err)
+	require.True(d.IsValidAt(time.Date(2022, 5, 25, 12, 0, 0, 0, paris)))
+	require.False(d.IsValidAt(time.Date(2022, 5, 25, 21, 0, 0, 0, paris)))
+}
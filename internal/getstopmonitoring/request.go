@@ -2,30 +2,31 @@ package getstopmonitoring
 
 import (
 	"bytes"
-	"encoding/xml"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/url"
-	"strings"
 	"text/template"
 	"time"
 
+	"github.com/julienbt/siri-sm/internal/clockskew"
 	"github.com/julienbt/siri-sm/internal/config"
+	"github.com/julienbt/siri-sm/internal/messageid"
 	"github.com/julienbt/siri-sm/internal/siri"
 	"github.com/sirupsen/logrus"
 )
 
-const IDENTIFIER_TIME_LAYOUT string = "20060102_150405"
-
 const MINIMUM_STOP_VISITS_PER_LINE int = 2
 
 type GetStopMonitoringRequest struct {
 	SupplierAddress          url.URL
+	Binding                  siri.Binding
+	Location                 *time.Location // of the supplier
 	RequestTimestamp         time.Time
 	RequestorRef             string
 	MessageIdentifier        string
 	MonitoringRef            string
+	LineRef                  string
 	MinimumStopVisitsPerLine int
 }
 
@@ -34,19 +35,27 @@ func GetStopMonitoring(
 	logger *logrus.Entry,
 	requestTimestamp *time.Time,
 	monitoringRef string,
+	lineRefs []string,
 ) ([]MonitoredStopVisit, string, []byte, error) {
 	var remoteErrorLoc = "GetStopMonitoring remote error"
 
 	getStopMonitoringRequest := GetStopMonitoringRequest{}
-	getStopMonitoringRequest.populate(&cfg, requestTimestamp, monitoringRef)
-	httpReq, htmlReqBody, err := getStopMonitoringRequest.generateHttpSoapReq()
+	err := getStopMonitoringRequest.populate(&cfg, requestTimestamp, monitoringRef, lineRefs)
 	if err != nil {
 		return nil,
 			"",
 			nil,
-			fmt.Errorf("error in building SOAP GetStopMonitoring request: %s", err)
+			fmt.Errorf("error GetStopMonitoring request initialization: %v", err)
+	}
+	httpReq, htmlReqBody, err := getStopMonitoringRequest.generateHttpReq()
+	if err != nil {
+		return nil,
+			"",
+			nil,
+			fmt.Errorf("error in building GetStopMonitoring request: %s", err)
 	}
 	// Send HTTP request and receive the response
+	sentAt := time.Now()
 	resp, err := siri.SoapCall(httpReq)
 	if err != nil {
 		return nil,
@@ -64,6 +73,7 @@ func GetStopMonitoring(
 			htmlRespBody,
 			&siri.RemoteError{Loc: remoteErrorLoc, Err: fmt.Errorf("unreadable response body: %s", err)}
 	}
+	receivedAt := time.Now()
 
 	// Check HTTP status code
 	if resp.StatusCode < 200 || resp.StatusCode > 299 {
@@ -73,27 +83,35 @@ func GetStopMonitoring(
 			&siri.RemoteError{Loc: remoteErrorLoc, Err: fmt.Errorf("bad http-response status: %s", resp.Status)}
 	}
 
-	getStopMonitoringEnv := &GetStopMonitoringEnv{}
-	err = xml.Unmarshal(htmlRespBody, &getStopMonitoringEnv)
+	stopMonitoringDelivery := &StopMonitoringDelivery{}
+	err = getStopMonitoringRequest.Binding.DecodeAnswerIn(htmlRespBody, STOP_MONITORING_DELIVERY_PATH, stopMonitoringDelivery, getStopMonitoringRequest.Location)
 	if err != nil {
 		return nil,
 			htmlReqBody,
 			htmlRespBody,
 			&siri.RemoteError{Loc: remoteErrorLoc, Err: fmt.Errorf("unmarshallable response body: %s", err)}
 	}
-	monitoredStopVisits, err := checkAndExtractMonitoredStopVisit(getStopMonitoringEnv)
+	clockskew.Default.Record(logger, cfg.SupplierAddress, sentAt, receivedAt, time.Time(stopMonitoringDelivery.ResponseTimestamp))
+	messageid.Check(logger, "GetStopMonitoring", getStopMonitoringRequest.MessageIdentifier, stopMonitoringDelivery.RequestMessageRef)
+	monitoredStopVisits, err := ExtractMonitoredStopVisits(stopMonitoringDelivery)
 	if err != nil {
 		return nil,
 			htmlReqBody,
 			htmlRespBody,
 			&siri.RemoteError{Loc: remoteErrorLoc, Err: err}
 	}
+	monitoredStopVisits, err = FilterByLineRefs(monitoredStopVisits, lineRefs)
+	if err != nil {
+		return nil,
+			htmlReqBody,
+			htmlRespBody,
+			err
+	}
 	return monitoredStopVisits, htmlReqBody, htmlRespBody, nil
 }
 
-func checkAndExtractMonitoredStopVisit(envelope *GetStopMonitoringEnv) ([]MonitoredStopVisit, error) {
+func ExtractMonitoredStopVisits(stopMonitoringDelivery *StopMonitoringDelivery) ([]MonitoredStopVisit, error) {
 	const EXPECTED_NUMBER_OF_MONITORED_STOP_VISIT_CANCELLATIONS int = 0
-	stopMonitoringDelivery := envelope.StopMonitoringDelivery
 	if len(stopMonitoringDelivery.MonitoredStopVisitCancellations) !=
 		EXPECTED_NUMBER_OF_MONITORED_STOP_VISIT_CANCELLATIONS {
 		err := fmt.Errorf("invalid number of MonitoredStopVisitCancellation")
@@ -102,43 +120,73 @@ func checkAndExtractMonitoredStopVisit(envelope *GetStopMonitoringEnv) ([]Monito
 	return stopMonitoringDelivery.MonitoredStopVisits, nil
 }
 
+// FilterByLineRefs keeps the visits of the lines, all the visits are kept without line
+func FilterByLineRefs(monitoredStopVisits []MonitoredStopVisit, lineRefs []string) ([]MonitoredStopVisit, error) {
+	if len(lineRefs) == 0 {
+		return monitoredStopVisits, nil
+	}
+	lineIds := make(map[LineRef]bool, len(lineRefs))
+	for _, lineRef := range lineRefs {
+		lineId, err := ParseLineRef(lineRef)
+		if err != nil {
+			return nil, err
+		}
+		lineIds[lineId] = true
+	}
+	filteredStopVisits := make([]MonitoredStopVisit, 0, len(monitoredStopVisits))
+	for _, monitoredStopVisit := range monitoredStopVisits {
+		if lineIds[monitoredStopVisit.MonitoredVehicleJourney.LineRef] {
+			filteredStopVisits = append(filteredStopVisits, monitoredStopVisit)
+		}
+	}
+	return filteredStopVisits, nil
+}
+
 func (req *GetStopMonitoringRequest) populate(
 	cfg *config.ConfigCheckStatus,
 	requestTimestamp *time.Time,
 	monitoringRef string,
+	lineRefs []string,
 ) error {
 	supplierAddressUrl, err := url.Parse(cfg.SupplierAddress)
 	if err != nil {
 		return fmt.Errorf("error the supplier address is not a valid URL: %s", cfg.SupplierAddress)
 	}
-	req.RequestTimestamp = *requestTimestamp
+	binding, err := siri.ParseBinding(cfg.Binding)
+	if err != nil {
+		return err
+	}
+	req.Binding = binding
+	location, err := cfg.Location()
+	if err != nil {
+		return err
+	}
+	req.Location = location
+	req.RequestTimestamp = clockskew.Default.Compensate(cfg.SupplierAddress, requestTimestamp.In(location))
 	req.RequestorRef = cfg.SubscriberRef
-	req.MessageIdentifier = cfg.SubscriberRef + ":ResponseMessage:" + requestTimestamp.Format(IDENTIFIER_TIME_LAYOUT)
+	req.MessageIdentifier, err = messageid.New(cfg.MessageIdPattern, cfg.SubscriberRef, "ResponseMessage", req.RequestTimestamp)
+	if err != nil {
+		return err
+	}
 	req.MonitoringRef = monitoringRef
+	// A request has at most one `LineRef`, several lines are filtered on the response
+	if len(lineRefs) == 1 {
+		req.LineRef = lineRefs[0]
+	}
 	req.MinimumStopVisitsPerLine = MINIMUM_STOP_VISITS_PER_LINE
 	req.SupplierAddress = *supplierAddressUrl
 	return nil
 }
 
-func (req *GetStopMonitoringRequest) generateHttpSoapReq() (*http.Request, string, error) {
+func (req *GetStopMonitoringRequest) generateHttpReq() (*http.Request, string, error) {
 	tmpl, err := template.ParseFiles("./template/getstopmonitoring-request.tmpl")
 	if err != nil {
 		return nil, "", fmt.Errorf("error parsing template: %s", err)
 	}
-	htmlReqBodyBuffer := &bytes.Buffer{}
-	err = tmpl.Execute(htmlReqBodyBuffer, req)
+	payloadBuffer := &bytes.Buffer{}
+	err = tmpl.ExecuteTemplate(payloadBuffer, req.Binding.PayloadTemplateName(), req)
 	if err != nil {
 		return nil, "", fmt.Errorf("error building template: %s", err)
 	}
-	htmlReqBody := htmlReqBodyBuffer.String()
-	httpReq, err := http.NewRequest(http.MethodPost, req.SupplierAddress.String(), strings.NewReader(htmlReqBody))
-	headers := http.Header{
-		"Content-Type": []string{"text/xml; charset=utf-8"},
-		"SOAPAction":   []string{"GetStopMonitoring"},
-	}
-	httpReq.Header = headers // better than .Header.Set to preserve case (for "SOAPAction")
-	if err != nil {
-		return nil, "", fmt.Errorf("error building http-request: %s", err)
-	}
-	return httpReq, htmlReqBody, nil
+	return req.Binding.NewHttpRequest(req.SupplierAddress.String(), "GetStopMonitoring", payloadBuffer.String())
 }
@@ -0,0 +1,30 @@
+package getstopmonitoring
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFilterByLineRefs(t *testing.T) {
+	require := require.New(t)
+
+	visits := []MonitoredStopVisit{
+		{ItemIdentifier: "1", MonitoredVehicleJourney: MonitoredVehicleJourney{LineRef: "L1"}},
+		{ItemIdentifier: "2", MonitoredVehicleJourney: MonitoredVehicleJourney{LineRef: "L2"}},
+		{ItemIdentifier: "3", MonitoredVehicleJourney: MonitoredVehicleJourney{LineRef: "L1"}},
+	}
+
+	filtered, err := FilterByLineRefs(visits, nil)
+	require.Nil(err)
+	require.Equal(visits, filtered)
+
+	filtered, err = FilterByLineRefs(visits, []string{"ILEVIA:Line:BP:L1:LOC"})
+	require.Nil(err)
+	require.Len(filtered, 2)
+	require.Equal("1", filtered[0].ItemIdentifier)
+	require.Equal("3", filtered[1].ItemIdentifier)
+
+	_, err = FilterByLineRefs(visits, []string{"L1"})
+	require.NotNil(err)
+}
@@ -7,8 +7,14 @@ import (
 
 	"github.com/julienbt/siri-sm/internal/common/directionname"
 	siri_time "github.com/julienbt/siri-sm/internal/common/time"
+	"github.com/julienbt/siri-sm/internal/siri"
 )
 
+var STOP_MONITORING_DELIVERY_PATH = siri.AnswerPath{
+	Soap: []string{"GetStopMonitoringResponse", "Answer", "StopMonitoringDelivery"},
+	Raw:  []string{"ServiceDelivery", "StopMonitoringDelivery"},
+}
+
 type GetStopMonitoringEnv struct {
 	XMLName                xml.Name               `xml:"Envelope"`
 	StopMonitoringDelivery StopMonitoringDelivery `xml:"Body>GetStopMonitoringResponse>Answer>StopMonitoringDelivery"`
@@ -16,6 +22,8 @@ type GetStopMonitoringEnv struct {
 
 type StopMonitoringDelivery struct {
 	XMLName                         xml.Name                         `xml:"StopMonitoringDelivery"`
+	ResponseTimestamp               siri_time.Time                   `xml:"ResponseTimestamp"`
+	RequestMessageRef               string                           `xml:"RequestMessageRef"`
 	MonitoringRef                   StopPointRef                     `xml:"MonitoringRef"`
 	MonitoredStopVisits             []MonitoredStopVisit             `xml:"MonitoredStopVisit"`
 	MonitoredStopVisitCancellations []MonitoredStopVisitCancellation `xml:"MonitoredStopVisitCancellation"`
@@ -30,16 +38,25 @@ func (mr *StopPointRef) UnmarshalXML(d *xml.Decoder, start xml.StartElement) err
 		return err
 	}
 
-	splittedInnerText := strings.Split(innerText, ":")
+	stopPointRef, err := ParseStopPointRef(innerText)
+	if err != nil {
+		return err
+	}
+	*mr = stopPointRef
+	return nil
+}
+
+// ParseStopPointRef extracts the stop id of a ref like `ILEVIA:StopPoint:BP:CAS001:LOC`
+func ParseStopPointRef(ref string) (StopPointRef, error) {
+	splittedRef := strings.Split(ref, ":")
 	const EXPECTED_NUM_OF_PARTS int = 5
-	if len(splittedInnerText) != EXPECTED_NUM_OF_PARTS {
-		return fmt.Errorf("the `MonitoringRef` is not well formatted: %s", innerText)
+	if len(splittedRef) != EXPECTED_NUM_OF_PARTS {
+		return "", fmt.Errorf("the `MonitoringRef` is not well formatted: %s", ref)
 	}
-	if splittedInnerText[1] != "StopPoint" {
-		return fmt.Errorf("the `MonitoringRef` is not well formatted: %s", innerText)
+	if splittedRef[1] != "StopPoint" {
+		return "", fmt.Errorf("the `MonitoringRef` is not well formatted: %s", ref)
 	}
-	*mr = StopPointRef(splittedInnerText[3])
-	return nil
+	return StopPointRef(splittedRef[3]), nil
 }
 
 type MonitoredStopVisit struct {
@@ -50,12 +67,25 @@ type MonitoredStopVisit struct {
 }
 
 type MonitoredVehicleJourney struct {
-	XMLName         xml.Name                    `xml:"MonitoredVehicleJourney"`
-	LineRef         LineRef                     `xml:"LineRef"`
-	DirectionName   directionname.DirectionName `xml:"DirectionName"`
-	DestinationRef  StopPointRef                `xml:"DestinationRef"`
-	DestinationName string                      `xml:"DestinationName"`
-	MonitoredCall   MonitoredCall               `xml:"MonitoredCall"`
+	XMLName                 xml.Name                    `xml:"MonitoredVehicleJourney"`
+	LineRef                 LineRef                     `xml:"LineRef"`
+	FramedVehicleJourneyRef FramedVehicleJourneyRef     `xml:"FramedVehicleJourneyRef"`
+	DirectionName           directionname.DirectionName `xml:"DirectionName"`
+	DestinationRef          StopPointRef                `xml:"DestinationRef"`
+	DestinationName         string                      `xml:"DestinationName"`
+	Cancellation            bool                        `xml:"Cancellation"` // the whole journey is cancelled
+	MonitoredCall           MonitoredCall               `xml:"MonitoredCall"`
+}
+
+// IsCancelled is true when the journey or its call at the stop is cancelled
+func (mvj *MonitoredVehicleJourney) IsCancelled() bool {
+	return mvj.Cancellation || mvj.MonitoredCall.IsCancelled()
+}
+
+type FramedVehicleJourneyRef struct {
+	XMLName                xml.Name `xml:"FramedVehicleJourneyRef"`
+	DataFrameRef           string   `xml:"DataFrameRef"`
+	DatedVehicleJourneyRef string   `xml:"DatedVehicleJourneyRef"`
 }
 
 type MonitoredStopVisitCancellation struct {
@@ -73,21 +103,41 @@ func (lr *LineRef) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
 		return err
 	}
 
-	splittedInnerText := strings.Split(innerText, ":")
+	lineRef, err := ParseLineRef(innerText)
+	if err != nil {
+		return err
+	}
+	*lr = lineRef
+	return nil
+}
+
+// ParseLineRef extracts the line id of a ref like `ILEVIA:Line:BP:L1:LOC`
+func ParseLineRef(ref string) (LineRef, error) {
+	splittedRef := strings.Split(ref, ":")
 	const EXPECTED_NUM_OF_PARTS int = 5
-	if len(splittedInnerText) != EXPECTED_NUM_OF_PARTS {
-		return fmt.Errorf("the `LineRef` is not well formatted: %s", innerText)
+	if len(splittedRef) != EXPECTED_NUM_OF_PARTS {
+		return "", fmt.Errorf("the `LineRef` is not well formatted: %s", ref)
 	}
-	if splittedInnerText[1] != "Line" {
-		return fmt.Errorf("the `LineRef` is not well formatted: %s", innerText)
+	if splittedRef[1] != "Line" {
+		return "", fmt.Errorf("the `LineRef` is not well formatted: %s", ref)
 	}
-	*lr = LineRef(splittedInnerText[3])
-	return nil
+	return LineRef(splittedRef[3]), nil
 }
 
+const CALL_STATUS_CANCELLED string = "cancelled"
+
 type MonitoredCall struct {
 	XMLName               xml.Name       `xml:"MonitoredCall"`
 	StopPointRef          StopPointRef   `xml:"StopPointRef"`
+	AimedArrivalTime      siri_time.Time `xml:"AimedArrivalTime"`
+	ExpectedArrivalTime   siri_time.Time `xml:"ExpectedArrivalTime"`
+	ArrivalStatus         string         `xml:"ArrivalStatus"`
 	AimedDepartureTime    siri_time.Time `xml:"AimedDepartureTime"`
 	ExpectedDepartureTime siri_time.Time `xml:"ExpectedDepartureTime"`
+	DepartureStatus       string         `xml:"DepartureStatus"`
+}
+
+func (mc *MonitoredCall) IsCancelled() bool {
+	return mc.DepartureStatus == CALL_STATUS_CANCELLED ||
+		(mc.DepartureStatus == "" && mc.ArrivalStatus == CALL_STATUS_CANCELLED)
 }
@@ -0,0 +1,64 @@
+package gtfsrt
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"google.golang.org/protobuf/encoding/prototext"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/julienbt/siri-sm/internal/visitstore"
+)
+
+// Exporter publishes the content of the store as a GTFS-RT TripUpdates feed
+type Exporter struct {
+	Store   *visitstore.Store
+	Mapping *Mapping
+}
+
+func (e *Exporter) Marshal(now time.Time) ([]byte, error) {
+	feedMessage := BuildFeedMessage(e.Store.All(), e.Mapping, now)
+	return proto.Marshal(feedMessage)
+}
+
+// ServeHTTP writes the feed as protobuf, or as text with `?format=text`
+func (e *Exporter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	feedMessage := BuildFeedMessage(e.Store.All(), e.Mapping, time.Now())
+	if r.URL.Query().Get("format") == "text" {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		_, _ = w.Write([]byte(prototext.Format(feedMessage)))
+		return
+	}
+	body, err := proto.Marshal(feedMessage)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("error marshalling the feed: %s", err), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/x-protobuf")
+	_, _ = w.Write(body)
+}
+
+// WriteFile atomically replaces the file with the current feed
+func (e *Exporter) WriteFile(path string) error {
+	body, err := e.Marshal(time.Now())
+	if err != nil {
+		return fmt.Errorf("error marshalling the feed: %s", err)
+	}
+	tmpFile, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("error creating the feed file: %s", err)
+	}
+	_, err = tmpFile.Write(body)
+	if closeErr := tmpFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmpFile.Name())
+		return fmt.Errorf("error writing the feed file: %s", err)
+	}
+	return os.Rename(tmpFile.Name(), path)
+}
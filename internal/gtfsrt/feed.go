@@ -0,0 +1,158 @@
+package gtfsrt
+
+import (
+	"sort"
+	"time"
+
+	"github.com/MobilityData/gtfs-realtime-bindings/golang/gtfs"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/julienbt/siri-sm/internal/getstopmonitoring"
+	"github.com/julienbt/siri-sm/internal/visitstore"
+)
+
+const GTFS_REALTIME_VERSION string = "2.0"
+
+const DATA_FRAME_REF_LAYOUT string = "2006-01-02"
+const GTFS_DATE_LAYOUT string = "20060102"
+
+type tripCall struct {
+	stopRef getstopmonitoring.StopPointRef
+	call    getstopmonitoring.MonitoredCall
+}
+
+type trip struct {
+	datedVehicleJourneyRef string
+	dataFrameRef           string
+	lineRef                getstopmonitoring.LineRef
+	cancelled              bool // a visit flags the whole journey as cancelled
+	calls                  []tripCall
+}
+
+// BuildFeedMessage converts the stop visits into one `TripUpdate` per vehicle journey.
+// A cancelled call is a SKIPPED stop time, only a journey flagged with
+// `Cancellation` is a CANCELED trip: the monitored calls are usually a few
+// stops of the trip.
+func BuildFeedMessage(stops []visitstore.StopVisits, mapping *Mapping, now time.Time) *gtfs.FeedMessage {
+	trips := groupByTrip(stops)
+	feedMessage := &gtfs.FeedMessage{
+		Header: &gtfs.FeedHeader{
+			GtfsRealtimeVersion: proto.String(GTFS_REALTIME_VERSION),
+			Incrementality:      gtfs.FeedHeader_FULL_DATASET.Enum(),
+			Timestamp:           proto.Uint64(uint64(now.Unix())),
+		},
+		Entity: make([]*gtfs.FeedEntity, 0, len(trips)),
+	}
+	for _, t := range trips {
+		tripId := mapping.TripId(t.datedVehicleJourneyRef)
+		feedMessage.Entity = append(feedMessage.Entity, &gtfs.FeedEntity{
+			Id:         proto.String(tripId),
+			TripUpdate: buildTripUpdate(t, tripId, mapping, now),
+		})
+	}
+	return feedMessage
+}
+
+func groupByTrip(stops []visitstore.StopVisits) []*trip {
+	tripsByRef := make(map[string]*trip)
+	for _, stop := range stops {
+		for _, visit := range stop.Visits {
+			journey := visit.MonitoredVehicleJourney
+			ref := journey.FramedVehicleJourneyRef.DatedVehicleJourneyRef
+			if ref == "" {
+				continue
+			}
+			t, ok := tripsByRef[ref]
+			if !ok {
+				t = &trip{
+					datedVehicleJourneyRef: ref,
+					dataFrameRef:           journey.FramedVehicleJourneyRef.DataFrameRef,
+					lineRef:                journey.LineRef,
+				}
+				tripsByRef[ref] = t
+			}
+			if journey.Cancellation {
+				t.cancelled = true
+			}
+			stopRef := journey.MonitoredCall.StopPointRef
+			if stopRef == "" {
+				stopRef = stop.StopRef
+			}
+			t.calls = append(t.calls, tripCall{stopRef: stopRef, call: journey.MonitoredCall})
+		}
+	}
+	trips := make([]*trip, 0, len(tripsByRef))
+	for _, t := range tripsByRef {
+		sort.SliceStable(t.calls, func(i, j int) bool {
+			return time.Time(t.calls[i].call.AimedDepartureTime).Before(time.Time(t.calls[j].call.AimedDepartureTime))
+		})
+		trips = append(trips, t)
+	}
+	sort.Slice(trips, func(i, j int) bool {
+		return trips[i].datedVehicleJourneyRef < trips[j].datedVehicleJourneyRef
+	})
+	return trips
+}
+
+func buildTripUpdate(t *trip, tripId string, mapping *Mapping, now time.Time) *gtfs.TripUpdate {
+	tripDescriptor := &gtfs.TripDescriptor{
+		TripId:               proto.String(tripId),
+		RouteId:              proto.String(mapping.RouteId(string(t.lineRef))),
+		ScheduleRelationship: gtfs.TripDescriptor_SCHEDULED.Enum(),
+	}
+	if startDate, err := time.Parse(DATA_FRAME_REF_LAYOUT, t.dataFrameRef); err == nil {
+		tripDescriptor.StartDate = proto.String(startDate.Format(GTFS_DATE_LAYOUT))
+	}
+	tripUpdate := &gtfs.TripUpdate{
+		Trip:      tripDescriptor,
+		Timestamp: proto.Uint64(uint64(now.Unix())),
+	}
+
+	if t.cancelled {
+		tripDescriptor.ScheduleRelationship = gtfs.TripDescriptor_CANCELED.Enum()
+		return tripUpdate
+	}
+
+	for _, c := range t.calls {
+		tripUpdate.StopTimeUpdate = append(tripUpdate.StopTimeUpdate, buildStopTimeUpdate(c, mapping))
+	}
+	return tripUpdate
+}
+
+func buildStopTimeUpdate(c tripCall, mapping *Mapping) *gtfs.TripUpdate_StopTimeUpdate {
+	stopTimeUpdate := &gtfs.TripUpdate_StopTimeUpdate{
+		StopId: proto.String(mapping.StopId(string(c.stopRef))),
+	}
+	if c.call.IsCancelled() {
+		stopTimeUpdate.ScheduleRelationship = gtfs.TripUpdate_StopTimeUpdate_SKIPPED.Enum()
+		return stopTimeUpdate
+	}
+	stopTimeUpdate.Arrival = buildStopTimeEvent(
+		time.Time(c.call.AimedArrivalTime),
+		time.Time(c.call.ExpectedArrivalTime),
+	)
+	stopTimeUpdate.Departure = buildStopTimeEvent(
+		time.Time(c.call.AimedDepartureTime),
+		time.Time(c.call.ExpectedDepartureTime),
+	)
+	// A SCHEDULED update carries an arrival or a departure at least
+	if stopTimeUpdate.Arrival == nil && stopTimeUpdate.Departure == nil {
+		stopTimeUpdate.ScheduleRelationship = gtfs.TripUpdate_StopTimeUpdate_NO_DATA.Enum()
+		return stopTimeUpdate
+	}
+	stopTimeUpdate.ScheduleRelationship = gtfs.TripUpdate_StopTimeUpdate_SCHEDULED.Enum()
+	return stopTimeUpdate
+}
+
+func buildStopTimeEvent(aimed time.Time, expected time.Time) *gtfs.TripUpdate_StopTimeEvent {
+	if expected.IsZero() {
+		return nil
+	}
+	stopTimeEvent := &gtfs.TripUpdate_StopTimeEvent{
+		Time: proto.Int64(expected.Unix()),
+	}
+	if !aimed.IsZero() {
+		stopTimeEvent.Delay = proto.Int32(int32(expected.Sub(aimed) / time.Second))
+	}
+	return stopTimeEvent
+}
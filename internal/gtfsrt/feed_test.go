@@ -0,0 +1,132 @@
+package gtfsrt
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/MobilityData/gtfs-realtime-bindings/golang/gtfs"
+	"github.com/stretchr/testify/require"
+
+	siri_time "github.com/julienbt/siri-sm/internal/common/time"
+	"github.com/julienbt/siri-sm/internal/getstopmonitoring"
+	"github.com/julienbt/siri-sm/internal/visitstore"
+)
+
+func newVisit(
+	datedVehicleJourneyRef string,
+	stopRef getstopmonitoring.StopPointRef,
+	aimed time.Time,
+	expected time.Time,
+	departureStatus string,
+) getstopmonitoring.MonitoredStopVisit {
+	visit := getstopmonitoring.MonitoredStopVisit{MonitoringRef: stopRef}
+	journey := &visit.MonitoredVehicleJourney
+	journey.LineRef = "L1"
+	journey.FramedVehicleJourneyRef.DataFrameRef = "2022-08-30"
+	journey.FramedVehicleJourneyRef.DatedVehicleJourneyRef = datedVehicleJourneyRef
+	journey.MonitoredCall.StopPointRef = stopRef
+	journey.MonitoredCall.AimedDepartureTime = siri_time.Time(aimed)
+	journey.MonitoredCall.ExpectedDepartureTime = siri_time.Time(expected)
+	journey.MonitoredCall.DepartureStatus = departureStatus
+	return visit
+}
+
+func TestBuildFeedMessage(t *testing.T) {
+	require := require.New(t)
+
+	aimed := time.Date(2022, time.August, 30, 8, 0, 0, 0, time.UTC)
+	stops := []visitstore.StopVisits{
+		{
+			Supplier: "lille",
+			StopRef:  "CAS001",
+			Visits: []getstopmonitoring.MonitoredStopVisit{
+				newVisit("VJ1", "CAS001", aimed, aimed.Add(90*time.Second), "delayed"),
+				newVisit("VJ2", "CAS001", aimed, time.Time{}, "cancelled"),
+				newVisit("VJ3", "CAS001", aimed, aimed, "onTime"),
+			},
+		},
+		{
+			Supplier: "lille",
+			StopRef:  "CAS002",
+			Visits: []getstopmonitoring.MonitoredStopVisit{
+				newVisit("VJ1", "CAS002", aimed.Add(5*time.Minute), aimed.Add(6*time.Minute), "delayed"),
+				newVisit("VJ3", "CAS002", aimed.Add(5*time.Minute), time.Time{}, "cancelled"),
+				newVisit("VJ4", "CAS002", aimed.Add(5*time.Minute), time.Time{}, "cancelled"),
+			},
+		},
+	}
+	stops[1].Visits[2].MonitoredVehicleJourney.Cancellation = true
+	mapping, err := ReadMapping(strings.NewReader(
+		"kind,siri_ref,gtfs_id\n" +
+			"trip,VJ1,trip_1\n" +
+			"stop,CAS001,stop_1\n" +
+			"route,L1,route_1\n",
+	))
+	require.Nil(err)
+
+	feedMessage := BuildFeedMessage(stops, mapping, aimed)
+	require.Equal(GTFS_REALTIME_VERSION, feedMessage.GetHeader().GetGtfsRealtimeVersion())
+	require.Len(feedMessage.GetEntity(), 4)
+
+	// Delayed trip with mapped ids
+	tripUpdate := feedMessage.GetEntity()[0].GetTripUpdate()
+	require.Equal("trip_1", tripUpdate.GetTrip().GetTripId())
+	require.Equal("route_1", tripUpdate.GetTrip().GetRouteId())
+	require.Equal("20220830", tripUpdate.GetTrip().GetStartDate())
+	require.Len(tripUpdate.GetStopTimeUpdate(), 2)
+	require.Equal("stop_1", tripUpdate.GetStopTimeUpdate()[0].GetStopId())
+	require.Equal(int32(90), tripUpdate.GetStopTimeUpdate()[0].GetDeparture().GetDelay())
+	require.Equal("CAS002", tripUpdate.GetStopTimeUpdate()[1].GetStopId())
+	require.Equal(int32(60), tripUpdate.GetStopTimeUpdate()[1].GetDeparture().GetDelay())
+
+	// Trip whose only monitored call is cancelled, the other stops may be served
+	tripUpdate = feedMessage.GetEntity()[1].GetTripUpdate()
+	require.Equal("VJ2", tripUpdate.GetTrip().GetTripId())
+	require.Equal(gtfs.TripDescriptor_SCHEDULED, tripUpdate.GetTrip().GetScheduleRelationship())
+	require.Len(tripUpdate.GetStopTimeUpdate(), 1)
+	require.Equal(
+		gtfs.TripUpdate_StopTimeUpdate_SKIPPED,
+		tripUpdate.GetStopTimeUpdate()[0].GetScheduleRelationship(),
+	)
+
+	// Trip with a skipped stop
+	tripUpdate = feedMessage.GetEntity()[2].GetTripUpdate()
+	require.Equal(gtfs.TripDescriptor_SCHEDULED, tripUpdate.GetTrip().GetScheduleRelationship())
+	require.Len(tripUpdate.GetStopTimeUpdate(), 2)
+	require.Equal(
+		gtfs.TripUpdate_StopTimeUpdate_SKIPPED,
+		tripUpdate.GetStopTimeUpdate()[1].GetScheduleRelationship(),
+	)
+
+	// Journey cancelled as a whole
+	tripUpdate = feedMessage.GetEntity()[3].GetTripUpdate()
+	require.Equal("VJ4", tripUpdate.GetTrip().GetTripId())
+	require.Equal(gtfs.TripDescriptor_CANCELED, tripUpdate.GetTrip().GetScheduleRelationship())
+	require.Empty(tripUpdate.GetStopTimeUpdate())
+}
+
+func TestBuildFeedMessageWithoutExpectedTime(t *testing.T) {
+	require := require.New(t)
+
+	aimed := time.Date(2022, time.August, 30, 8, 0, 0, 0, time.UTC)
+	stops := []visitstore.StopVisits{
+		{
+			Supplier: "lille",
+			StopRef:  "CAS001",
+			Visits: []getstopmonitoring.MonitoredStopVisit{
+				newVisit("VJ1", "CAS001", aimed, time.Time{}, "noReport"),
+			},
+		},
+	}
+	mapping, err := ReadMapping(strings.NewReader("kind,siri_ref,gtfs_id\n"))
+	require.Nil(err)
+
+	feedMessage := BuildFeedMessage(stops, mapping, aimed)
+	require.Len(feedMessage.GetEntity(), 1)
+	stopTimeUpdates := feedMessage.GetEntity()[0].GetTripUpdate().GetStopTimeUpdate()
+	require.Len(stopTimeUpdates, 1)
+	require.Equal(gtfs.TripUpdate_StopTimeUpdate_NO_DATA, stopTimeUpdates[0].GetScheduleRelationship())
+	require.Nil(stopTimeUpdates[0].GetArrival())
+	require.Nil(stopTimeUpdates[0].GetDeparture())
+}
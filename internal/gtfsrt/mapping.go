@@ -0,0 +1,96 @@
+package gtfsrt
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+const (
+	MAPPING_KIND_TRIP  string = "trip"
+	MAPPING_KIND_STOP  string = "stop"
+	MAPPING_KIND_ROUTE string = "route"
+)
+
+// Mapping translates SIRI refs to GTFS ids, a ref without mapping is kept as is
+type Mapping struct {
+	trips  map[string]string
+	stops  map[string]string
+	routes map[string]string
+}
+
+func NewMapping() *Mapping {
+	return &Mapping{
+		trips:  make(map[string]string),
+		stops:  make(map[string]string),
+		routes: make(map[string]string),
+	}
+}
+
+// LoadMappingFile reads a CSV file with the header `kind,siri_ref,gtfs_id`
+// where `kind` is one of `trip`, `stop` or `route`
+func LoadMappingFile(path string) (*Mapping, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening the mapping file: %s", err)
+	}
+	defer file.Close()
+	return ReadMapping(file)
+}
+
+func ReadMapping(r io.Reader) (*Mapping, error) {
+	const EXPECTED_NUM_OF_FIELDS int = 3
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = EXPECTED_NUM_OF_FIELDS
+	reader.TrimLeadingSpace = true
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("error reading the mapping: %s", err)
+	}
+	mapping := NewMapping()
+	for i, record := range records {
+		if i == 0 && record[0] == "kind" {
+			continue
+		}
+		err = mapping.Add(record[0], record[1], record[2])
+		if err != nil {
+			return nil, fmt.Errorf("error in the mapping line %d: %s", i+1, err)
+		}
+	}
+	return mapping, nil
+}
+
+func (m *Mapping) Add(kind string, siriRef string, gtfsId string) error {
+	switch strings.ToLower(kind) {
+	case MAPPING_KIND_TRIP:
+		m.trips[siriRef] = gtfsId
+	case MAPPING_KIND_STOP:
+		m.stops[siriRef] = gtfsId
+	case MAPPING_KIND_ROUTE:
+		m.routes[siriRef] = gtfsId
+	default:
+		return fmt.Errorf("unknown mapping kind: %s", kind)
+	}
+	return nil
+}
+
+func (m *Mapping) TripId(siriRef string) string {
+	return lookup(m.trips, siriRef)
+}
+
+func (m *Mapping) StopId(siriRef string) string {
+	return lookup(m.stops, siriRef)
+}
+
+func (m *Mapping) RouteId(siriRef string) string {
+	return lookup(m.routes, siriRef)
+}
+
+func lookup(ids map[string]string, siriRef string) string {
+	if gtfsId, ok := ids[siriRef]; ok {
+		return gtfsId
+	}
+	return siriRef
+}
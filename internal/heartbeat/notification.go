@@ -0,0 +1,105 @@
+package heartbeat
+
+import (
+	"encoding/xml"
+	"fmt"
+	"time"
+
+	"github.com/julienbt/siri-sm/internal/siri"
+)
+
+type HeartbeatNotificationEnv struct {
+	XMLName         xml.Name        `xml:"Envelope"`
+	NotifyHeartbeat NotifyHeartbeat `xml:"Body>NotifyHeartbeat"`
+}
+
+type NotifyHeartbeat struct {
+	XMLName             xml.Name            `xml:"NotifyHeartbeat"`
+	HeartbeatNotifyInfo HeartbeatNotifyInfo `xml:"HeartbeatNotifyInfo"`
+	Notification        Notification        `xml:"Notification"`
+}
+
+type HeartbeatNotifyInfo struct {
+	XMLName           xml.Name  `xml:"HeartbeatNotifyInfo"`
+	RequestTimestamp  time.Time `xml:"RequestTimestamp"`
+	ProducerRef       string    `xml:"ProducerRef"`
+	MessageIdentifier string    `xml:"MessageIdentifier"`
+}
+
+// RawHeartbeatNotification is the `HeartbeatNotification` of the raw SIRI
+// XML binding, below the `Siri` root element
+type RawHeartbeatNotification struct {
+	XMLName            xml.Name  `xml:"HeartbeatNotification"`
+	RequestTimestamp   time.Time `xml:"RequestTimestamp"`
+	ProducerRef        string    `xml:"ProducerRef"`
+	MessageIdentifier  string    `xml:"MessageIdentifier"`
+	Status             bool      `xml:"Status"`
+	ValidUntil         time.Time `xml:"ValidUntil"`
+	ServiceStartedTime time.Time `xml:"ServiceStartedTime"`
+}
+
+type Notification struct {
+	XMLName            xml.Name  `xml:"Notification"`
+	Status             bool      `xml:"Status"`
+	ValidUntil         time.Time `xml:"ValidUntil"`
+	ServiceStartedTime time.Time `xml:"ServiceStartedTime"`
+}
+
+// HeartbeatNotification is the flattened content of a `NotifyHeartbeat`
+type HeartbeatNotification struct {
+	ProducerRef        string
+	RequestTimestamp   time.Time
+	MessageIdentifier  string
+	Status             bool
+	ServiceStartedTime time.Time
+	ValidUntil         time.Time
+}
+
+// ParseHeartbeatNotification reads a `NotifyHeartbeat` (SOAP) or a
+// `HeartbeatNotification` (raw SIRI XML)
+func ParseHeartbeatNotification(body []byte) (HeartbeatNotification, error) {
+	binding, _, err := siri.DetectMessage(body)
+	if err == nil && binding == siri.BINDING_RAW {
+		return parseRawHeartbeatNotification(body)
+	}
+	envelope := HeartbeatNotificationEnv{}
+	err = xml.Unmarshal(body, &envelope)
+	if err != nil {
+		return HeartbeatNotification{}, fmt.Errorf("unmarshallable heartbeat notification: %s", err)
+	}
+	notifyInfo := envelope.NotifyHeartbeat.HeartbeatNotifyInfo
+	if notifyInfo.ProducerRef == "" {
+		return HeartbeatNotification{}, fmt.Errorf("missing `ProducerRef` in heartbeat notification")
+	}
+	notification := envelope.NotifyHeartbeat.Notification
+	return HeartbeatNotification{
+		ProducerRef:        notifyInfo.ProducerRef,
+		RequestTimestamp:   notifyInfo.RequestTimestamp,
+		MessageIdentifier:  notifyInfo.MessageIdentifier,
+		Status:             notification.Status,
+		ServiceStartedTime: notification.ServiceStartedTime,
+		ValidUntil:         notification.ValidUntil,
+	}, nil
+}
+
+func parseRawHeartbeatNotification(body []byte) (HeartbeatNotification, error) {
+	raw := RawHeartbeatNotification{}
+	found, err := siri.DecodeElementAt(body, []string{"Siri", "HeartbeatNotification"}, &raw)
+	if err != nil {
+		return HeartbeatNotification{}, fmt.Errorf("unmarshallable heartbeat notification: %s", err)
+	}
+	if !found {
+		return HeartbeatNotification{}, fmt.Errorf("missing `HeartbeatNotification` in raw SIRI XML")
+	}
+	if raw.ProducerRef == "" {
+		return HeartbeatNotification{}, fmt.Errorf("missing `ProducerRef` in heartbeat notification")
+	}
+	return HeartbeatNotification{
+		ProducerRef:        raw.ProducerRef,
+		RequestTimestamp:   raw.RequestTimestamp,
+		MessageIdentifier:  raw.MessageIdentifier,
+		Status:             raw.Status,
+		ServiceStartedTime: raw.ServiceStartedTime,
+		ValidUntil:         raw.ValidUntil,
+	}, nil
+}
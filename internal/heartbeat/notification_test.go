@@ -0,0 +1,85 @@
+package heartbeat
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+var testDataDir string
+
+const SECONDS_PER_HOUR int = 3_600
+
+var EXPECTED_LOCATION *time.Location = time.FixedZone("", 2*SECONDS_PER_HOUR)
+
+func TestMain(m *testing.M) {
+
+	testDataDir = os.Getenv("SIRISM_TEST_DATA_DIR")
+	if testDataDir == "" {
+		panic("$SIRISM_TEST_DATA_DIR isn't set")
+	}
+
+	os.Exit(m.Run())
+}
+
+func TestParseHeartbeatNotification(t *testing.T) {
+	require := require.New(t)
+
+	htmlNotifBody, err := ioutil.ReadFile(
+		fmt.Sprintf(
+			"%s/examples/HEARTBEAT_NOTIF_000.xml",
+			testDataDir,
+		),
+	)
+	require.Nil(err)
+
+	notification, err := ParseHeartbeatNotification(htmlNotifBody)
+	require.Nil(err)
+
+	require.Equal("ILEVIA", notification.ProducerRef)
+	require.Equal("ILEVIA:HeartbeatNotification:20220830_044000", notification.MessageIdentifier)
+	require.True(notification.Status)
+	// 2022-08-29T03:00:00.000+02:00
+	require.True(time.Date(
+		2022, time.August, 29,
+		3, 0, 0, 0,
+		EXPECTED_LOCATION,
+	).Equal(notification.ServiceStartedTime))
+	// 2022-08-30T04:45:00.000+02:00
+	require.True(time.Date(
+		2022, time.August, 30,
+		4, 45, 0, 0,
+		EXPECTED_LOCATION,
+	).Equal(notification.ValidUntil))
+}
+
+func TestParseHeartbeatNotificationWithoutProducerRef(t *testing.T) {
+	require := require.New(t)
+
+	_, err := ParseHeartbeatNotification([]byte(
+		`<Envelope><Body><NotifyHeartbeat><Notification><Status>true</Status></Notification></NotifyHeartbeat></Body></Envelope>`,
+	))
+	require.NotNil(err)
+}
+
+func TestParseRawHeartbeatNotification(t *testing.T) {
+	require := require.New(t)
+
+	notification, err := ParseHeartbeatNotification([]byte(`<Siri xmlns="http://www.siri.org.uk/siri" version="2.0">
+	<HeartbeatNotification>
+		<RequestTimestamp>2022-08-30T04:40:00.125+02:00</RequestTimestamp>
+		<ProducerRef>ILEVIA</ProducerRef>
+		<MessageIdentifier>ILEVIA:HeartbeatNotification:20220830_044000</MessageIdentifier>
+		<Status>true</Status>
+		<ValidUntil>2022-08-30T04:45:00.000+02:00</ValidUntil>
+	</HeartbeatNotification>
+</Siri>`))
+	require.Nil(err)
+	require.Equal("ILEVIA", notification.ProducerRef)
+	require.True(notification.Status)
+	require.True(time.Date(2022, time.August, 30, 4, 45, 0, 0, EXPECTED_LOCATION).Equal(notification.ValidUntil))
+}
@@ -0,0 +1,105 @@
+package heartbeat
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+type Liveness struct {
+	ProducerRef        string
+	LastHeartbeat      time.Time
+	LastDelivery       time.Time
+	Status             bool
+	ServiceStartedTime time.Time
+	ValidUntil         time.Time
+	Stale              bool
+}
+
+// LastSeen is the most recent time a heartbeat or a delivery was received
+func (l Liveness) LastSeen() time.Time {
+	if l.LastDelivery.After(l.LastHeartbeat) {
+		return l.LastDelivery
+	}
+	return l.LastHeartbeat
+}
+
+// Tracker flags a supplier as stale when neither heartbeats nor deliveries
+// were received within the window
+type Tracker struct {
+	mutex     sync.RWMutex
+	window    time.Duration
+	suppliers map[string]*Liveness
+}
+
+func NewTracker(window time.Duration) *Tracker {
+	return &Tracker{
+		window:    window,
+		suppliers: make(map[string]*Liveness),
+	}
+}
+
+func (t *Tracker) RecordHeartbeat(notification HeartbeatNotification, receivedAt time.Time) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	liveness := t.getOrCreate(notification.ProducerRef)
+	liveness.LastHeartbeat = receivedAt
+	liveness.Status = notification.Status
+	liveness.ServiceStartedTime = notification.ServiceStartedTime
+	liveness.ValidUntil = notification.ValidUntil
+}
+
+func (t *Tracker) RecordDelivery(producerRef string, receivedAt time.Time) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	liveness := t.getOrCreate(producerRef)
+	liveness.LastDelivery = receivedAt
+}
+
+func (t *Tracker) Liveness(producerRef string, now time.Time) (Liveness, bool) {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+	liveness, ok := t.suppliers[producerRef]
+	if !ok {
+		return Liveness{}, false
+	}
+	return t.evaluate(*liveness, now), true
+}
+
+func (t *Tracker) All(now time.Time) []Liveness {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+	all := make([]Liveness, 0, len(t.suppliers))
+	for _, liveness := range t.suppliers {
+		all = append(all, t.evaluate(*liveness, now))
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].ProducerRef < all[j].ProducerRef })
+	return all
+}
+
+// evaluate flags the supplier as stale beyond the window after the last
+// delivery or heartbeat, a heartbeat keeping it alive until its ValidUntil at
+// most, and as soon as its last heartbeat reports a Status false
+func (t *Tracker) evaluate(liveness Liveness, now time.Time) Liveness {
+	liveUntil := liveness.LastDelivery.Add(t.window)
+	if !liveness.LastHeartbeat.IsZero() {
+		heartbeatUntil := liveness.LastHeartbeat.Add(t.window)
+		if !liveness.ValidUntil.IsZero() && liveness.ValidUntil.Before(heartbeatUntil) {
+			heartbeatUntil = liveness.ValidUntil
+		}
+		if heartbeatUntil.After(liveUntil) {
+			liveUntil = heartbeatUntil
+		}
+	}
+	liveness.Stale = now.After(liveUntil) || (!liveness.LastHeartbeat.IsZero() && !liveness.Status)
+	return liveness
+}
+
+func (t *Tracker) getOrCreate(producerRef string) *Liveness {
+	liveness, ok := t.suppliers[producerRef]
+	if !ok {
+		liveness = &Liveness{ProducerRef: producerRef}
+		t.suppliers[producerRef] = liveness
+	}
+	return liveness
+}
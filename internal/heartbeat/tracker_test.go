@@ -0,0 +1,85 @@
+package heartbeat
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTrackerStaleness(t *testing.T) {
+	require := require.New(t)
+
+	const WINDOW time.Duration = 5 * time.Minute
+	start := time.Date(2022, time.August, 30, 4, 0, 0, 0, time.UTC)
+	tracker := NewTracker(WINDOW)
+
+	_, ok := tracker.Liveness("ILEVIA", start)
+	require.False(ok)
+
+	tracker.RecordHeartbeat(HeartbeatNotification{ProducerRef: "ILEVIA", Status: true}, start)
+	liveness, ok := tracker.Liveness("ILEVIA", start.Add(WINDOW))
+	require.True(ok)
+	require.True(liveness.Status)
+	require.False(liveness.Stale)
+
+	liveness, _ = tracker.Liveness("ILEVIA", start.Add(WINDOW+time.Second))
+	require.True(liveness.Stale)
+
+	// A delivery keeps the supplier alive even without heartbeat
+	tracker.RecordDelivery("ILEVIA", start.Add(4*time.Minute))
+	liveness, _ = tracker.Liveness("ILEVIA", start.Add(WINDOW+time.Second))
+	require.False(liveness.Stale)
+	require.Equal(start.Add(4*time.Minute), liveness.LastSeen())
+
+	tracker.RecordDelivery("ametis", start)
+	all := tracker.All(start.Add(WINDOW + time.Second))
+	require.Len(all, 2)
+	require.Equal("ILEVIA", all[0].ProducerRef)
+	require.Equal("ametis", all[1].ProducerRef)
+	require.True(all[1].Stale)
+}
+
+func TestTrackerHeartbeatStatus(t *testing.T) {
+	require := require.New(t)
+
+	const WINDOW time.Duration = 5 * time.Minute
+	start := time.Date(2022, time.August, 30, 4, 0, 0, 0, time.UTC)
+	tracker := NewTracker(WINDOW)
+
+	// The supplier reports it is down
+	tracker.RecordHeartbeat(HeartbeatNotification{ProducerRef: "ILEVIA", Status: false}, start)
+	liveness, _ := tracker.Liveness("ILEVIA", start)
+	require.True(liveness.Stale)
+	tracker.RecordDelivery("ILEVIA", start)
+	liveness, _ = tracker.Liveness("ILEVIA", start)
+	require.True(liveness.Stale)
+
+	tracker.RecordHeartbeat(HeartbeatNotification{ProducerRef: "ILEVIA", Status: true}, start.Add(time.Minute))
+	liveness, _ = tracker.Liveness("ILEVIA", start.Add(time.Minute))
+	require.False(liveness.Stale)
+}
+
+func TestTrackerHeartbeatValidUntil(t *testing.T) {
+	require := require.New(t)
+
+	const WINDOW time.Duration = 5 * time.Minute
+	start := time.Date(2022, time.August, 30, 4, 0, 0, 0, time.UTC)
+	tracker := NewTracker(WINDOW)
+
+	tracker.RecordHeartbeat(HeartbeatNotification{ProducerRef: "ILEVIA", Status: true, ValidUntil: start.Add(2 * time.Minute)}, start)
+	liveness, _ := tracker.Liveness("ILEVIA", start.Add(2*time.Minute))
+	require.False(liveness.Stale)
+	liveness, _ = tracker.Liveness("ILEVIA", start.Add(2*time.Minute+time.Second))
+	require.True(liveness.Stale)
+
+	// A delivery keeps the supplier alive beyond the ValidUntil of the heartbeat
+	tracker.RecordDelivery("ILEVIA", start.Add(time.Minute))
+	liveness, _ = tracker.Liveness("ILEVIA", start.Add(3*time.Minute))
+	require.False(liveness.Stale)
+
+	// The window still applies to a ValidUntil beyond it
+	tracker.RecordHeartbeat(HeartbeatNotification{ProducerRef: "ametis", Status: true, ValidUntil: start.Add(time.Hour)}, start)
+	liveness, _ = tracker.Liveness("ametis", start.Add(WINDOW+time.Second))
+	require.True(liveness.Stale)
+}
@@ -0,0 +1,214 @@
+// Package inspect decodes a saved SIRI message with the types of the
+// project, to check offline how a capture of a supplier is understood
+package inspect
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/julienbt/siri-sm/internal/checkstatus"
+	"github.com/julienbt/siri-sm/internal/getstopmonitoring"
+	"github.com/julienbt/siri-sm/internal/heartbeat"
+	"github.com/julienbt/siri-sm/internal/siri"
+	"github.com/julienbt/siri-sm/internal/subscribe"
+)
+
+// Report is the result of the inspection of a message
+type Report struct {
+	Binding   siri.Binding `json:"binding,omitempty"`
+	Message   string       `json:"message,omitempty"` // e.g. `GetStopMonitoringResponse`
+	Summary   []string     `json:"summary,omitempty"`
+	Answer    interface{}  `json:"answer,omitempty"`
+	Error     string       `json:"error,omitempty"`
+	ErrorPath string       `json:"error_path,omitempty"` // e.g. `Envelope>Body>CheckStatusResponse>Answer>Status`
+}
+
+type messageType struct {
+	Name      string
+	Path      siri.AnswerPath // without `Raw` when the message only exists in SOAP
+	New       func() interface{}
+	Summarize func(v interface{}) []string
+}
+
+var NOTIFY_STOP_MONITORING_PATH = siri.AnswerPath{
+	Soap: []string{"NotifyStopMonitoring", "Notification", "StopMonitoringDelivery"},
+}
+
+var HEARTBEAT_PATH = siri.AnswerPath{
+	Soap: []string{"NotifyHeartbeat"},
+}
+
+var messageTypes = []messageType{
+	{
+		Name:      "CheckStatusResponse",
+		Path:      checkstatus.CHECK_STATUS_ANSWER_PATH,
+		New:       func() interface{} { return &checkstatus.CheckStatusResponseAnswer{} },
+		Summarize: summarizeCheckStatus,
+	},
+	{
+		Name:      "SubscribeResponse",
+		Path:      subscribe.SUBSCRIBE_ANSWER_PATH,
+		New:       func() interface{} { return &subscribe.SubscribeAnswer{} },
+		Summarize: summarizeSubscribe,
+	},
+	{
+		Name:      "GetStopMonitoringResponse",
+		Path:      getstopmonitoring.STOP_MONITORING_DELIVERY_PATH,
+		New:       func() interface{} { return &getstopmonitoring.StopMonitoringDelivery{} },
+		Summarize: summarizeStopMonitoring,
+	},
+	{
+		Name:      "NotifyStopMonitoring",
+		Path:      NOTIFY_STOP_MONITORING_PATH,
+		New:       func() interface{} { return &getstopmonitoring.StopMonitoringDelivery{} },
+		Summarize: summarizeStopMonitoring,
+	},
+	{
+		Name:      "Heartbeat",
+		Path:      HEARTBEAT_PATH,
+		New:       func() interface{} { return &heartbeat.NotifyHeartbeat{} },
+		Summarize: summarizeHeartbeat,
+	},
+}
+
+// MessageNames are the messages which can be inspected
+func MessageNames() []string {
+	names := make([]string, 0, len(messageTypes))
+	for _, t := range messageTypes {
+		names = append(names, t.Name)
+	}
+	return names
+}
+
+func (t *messageType) path(binding siri.Binding) []string {
+	if binding.IsSoap() {
+		return t.Path.Soap
+	}
+	return t.Path.Raw
+}
+
+// detect returns the type of the message whose answer is found, or else the
+// first type of the detected element (the answer is then reported missing)
+func detect(body []byte, binding siri.Binding, name string) (*messageType, bool) {
+	var candidate *messageType
+	for i := range messageTypes {
+		t := &messageTypes[i]
+		path := t.path(binding)
+		if len(path) == 0 || path[0] != name {
+			continue
+		}
+		if candidate == nil {
+			candidate = t
+		}
+		found, _ := siri.DecodeElementAt(body, fullPath(binding, path), &struct{}{})
+		if found {
+			return t, true
+		}
+	}
+	return candidate, candidate != nil
+}
+
+func fullPath(binding siri.Binding, path []string) []string {
+	if binding.IsSoap() {
+		return append([]string{"Envelope", "Body"}, path...)
+	}
+	return append([]string{"Siri"}, path...)
+}
+
+// Inspect detects the message and decodes it, the errors are reported. The
+// times without UTC offset are read in `location`, the timezone of the supplier
+func Inspect(body []byte, location *time.Location) Report {
+	binding, name, err := siri.DetectMessage(body)
+	if err != nil {
+		return reportError(Report{}, err)
+	}
+	report := Report{Binding: binding, Message: name}
+	t, ok := detect(body, binding, name)
+	if !ok {
+		return reportError(report, fmt.Errorf("unsupported message %s, expected one of %s", name, strings.Join(MessageNames(), ", ")))
+	}
+	report.Message = t.Name
+	answer := t.New()
+	err = binding.DecodeAnswerIn(body, t.Path, answer, location)
+	if err != nil {
+		return reportError(report, err)
+	}
+	report.Answer = answer
+	report.Summary = t.Summarize(answer)
+	return report
+}
+
+func reportError(report Report, err error) Report {
+	report.Error = err.Error()
+	var decodeErr *siri.DecodeError
+	if errors.As(err, &decodeErr) {
+		report.Error = decodeErr.Err.Error()
+		report.ErrorPath = decodeErr.Path
+	}
+	return report
+}
+
+const SUMMARY_TIME_LAYOUT string = time.RFC3339Nano
+
+func summarizeCheckStatus(v interface{}) []string {
+	answer := v.(*checkstatus.CheckStatusResponseAnswer)
+	return []string{
+		fmt.Sprintf("status: %t", answer.Status),
+		fmt.Sprintf("response timestamp: %s", answer.ResponseTimestamp.Format(SUMMARY_TIME_LAYOUT)),
+		fmt.Sprintf("service started time: %s", answer.ServiceStartedTime.Format(SUMMARY_TIME_LAYOUT)),
+	}
+}
+
+func summarizeSubscribe(v interface{}) []string {
+	answer := v.(*subscribe.SubscribeAnswer)
+	failed := make([]string, 0)
+	for _, status := range answer.ResponseStatus {
+		if !status.Status {
+			failed = append(failed, status.SubscriptionRef)
+		}
+	}
+	summary := []string{
+		fmt.Sprintf("subscriptions: %d", len(answer.ResponseStatus)),
+		fmt.Sprintf("accepted: %d", len(answer.ResponseStatus)-len(failed)),
+		fmt.Sprintf("rejected: %d", len(failed)),
+	}
+	for _, ref := range failed {
+		summary = append(summary, fmt.Sprintf("rejected subscription: %s", ref))
+	}
+	return summary
+}
+
+func summarizeStopMonitoring(v interface{}) []string {
+	delivery := v.(*getstopmonitoring.StopMonitoringDelivery)
+	visitsByLine := make(map[string]int)
+	for _, visit := range delivery.MonitoredStopVisits {
+		visitsByLine[string(visit.MonitoredVehicleJourney.LineRef)]++
+	}
+	lines := make([]string, 0, len(visitsByLine))
+	for line := range visitsByLine {
+		lines = append(lines, line)
+	}
+	sort.Strings(lines)
+	summary := []string{
+		fmt.Sprintf("monitoring ref: %s", delivery.MonitoringRef),
+		fmt.Sprintf("visits: %d", len(delivery.MonitoredStopVisits)),
+		fmt.Sprintf("cancellations: %d", len(delivery.MonitoredStopVisitCancellations)),
+	}
+	for _, line := range lines {
+		summary = append(summary, fmt.Sprintf("visits of line %s: %d", line, visitsByLine[line]))
+	}
+	return summary
+}
+
+func summarizeHeartbeat(v interface{}) []string {
+	notify := v.(*heartbeat.NotifyHeartbeat)
+	return []string{
+		fmt.Sprintf("producer ref: %s", notify.HeartbeatNotifyInfo.ProducerRef),
+		fmt.Sprintf("status: %t", notify.Notification.Status),
+		fmt.Sprintf("valid until: %s", notify.Notification.ValidUntil.Format(SUMMARY_TIME_LAYOUT)),
+		fmt.Sprintf("service started time: %s", notify.Notification.ServiceStartedTime.Format(SUMMARY_TIME_LAYOUT)),
+	}
+}
@@ -0,0 +1,114 @@
+package inspect
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/julienbt/siri-sm/internal/getstopmonitoring"
+	"github.com/julienbt/siri-sm/internal/siri"
+	"github.com/stretchr/testify/require"
+)
+
+var testDataDir string
+
+func TestMain(m *testing.M) {
+
+	testDataDir = os.Getenv("SIRISM_TEST_DATA_DIR")
+	if testDataDir == "" {
+		panic("$SIRISM_TEST_DATA_DIR isn't set")
+	}
+
+	os.Exit(m.Run())
+}
+
+func readExample(t *testing.T, name string) []byte {
+	body, err := ioutil.ReadFile(fmt.Sprintf("%s/examples/%s", testDataDir, name))
+	require.Nil(t, err)
+	return body
+}
+
+func TestInspectExamples(t *testing.T) {
+	require := require.New(t)
+
+	report := Inspect(readExample(t, "SUB_RESP_000.xml"), time.UTC)
+	require.Empty(report.Error)
+	require.Equal(siri.BINDING_SOAP_11, report.Binding)
+	require.Equal("SubscribeResponse", report.Message)
+	require.Contains(report.Summary, "rejected: 0")
+
+	report = Inspect(readExample(t, "HEARTBEAT_NOTIF_000.xml"), time.UTC)
+	require.Empty(report.Error)
+	require.Equal("Heartbeat", report.Message)
+	require.Contains(report.Summary, "producer ref: ILEVIA")
+}
+
+const RAW_STOP_MONITORING string = `<Siri xmlns="http://www.siri.org.uk/siri" version="2.0">
+	<ServiceDelivery>
+		<StopMonitoringDelivery version="2.0">
+			<MonitoringRef>ILEVIA:StopPoint:BP:CAS001:LOC</MonitoringRef>
+			<MonitoredStopVisit>
+				<MonitoringRef>ILEVIA:StopPoint:BP:CAS001:LOC</MonitoringRef>
+				<MonitoredVehicleJourney>
+					<LineRef>ILEVIA:Line:BP:L1:LOC</LineRef>
+					<DirectionName>ALLER</DirectionName>
+					<MonitoredCall>
+						<StopPointRef>ILEVIA:StopPoint:BP:CAS001:LOC</StopPointRef>
+						<AimedDepartureTime>%s</AimedDepartureTime>
+					</MonitoredCall>
+				</MonitoredVehicleJourney>
+			</MonitoredStopVisit>
+		</StopMonitoringDelivery>
+	</ServiceDelivery>
+</Siri>`
+
+func TestInspectStopMonitoring(t *testing.T) {
+	require := require.New(t)
+
+	report := Inspect([]byte(fmt.Sprintf(RAW_STOP_MONITORING, "2022-08-30T08:00:00.000+02:00")), time.UTC)
+	require.Empty(report.Error)
+	require.Equal(siri.BINDING_RAW, report.Binding)
+	require.Equal("GetStopMonitoringResponse", report.Message)
+	require.Contains(report.Summary, "visits of line L1: 1")
+	delivery, ok := report.Answer.(*getstopmonitoring.StopMonitoringDelivery)
+	require.True(ok)
+	require.Equal(getstopmonitoring.StopPointRef("CAS001"), delivery.MonitoringRef)
+
+	// Seconds without milliseconds
+	report = Inspect([]byte(fmt.Sprintf(RAW_STOP_MONITORING, "2022-08-30T08:00:00+02:00")), time.UTC)
+	require.Empty(report.Error)
+
+	// Without UTC offset, in the timezone of the supplier
+	montreal, err := time.LoadLocation("America/Montreal")
+	require.Nil(err)
+	report = Inspect([]byte(fmt.Sprintf(RAW_STOP_MONITORING, "2022-08-30T08:00:00")), montreal)
+	require.Empty(report.Error)
+	delivery = report.Answer.(*getstopmonitoring.StopMonitoringDelivery)
+	require.True(
+		time.Date(2022, 8, 30, 12, 0, 0, 0, time.UTC).Equal(
+			time.Time(delivery.MonitoredStopVisits[0].MonitoredVehicleJourney.MonitoredCall.AimedDepartureTime),
+		),
+	)
+
+	// Not a SIRI time
+	report = Inspect([]byte(fmt.Sprintf(RAW_STOP_MONITORING, "30/08/2022 08:00")), time.UTC)
+	require.NotEmpty(report.Error)
+	require.Equal(
+		"Siri>ServiceDelivery>StopMonitoringDelivery>MonitoredStopVisit>MonitoredVehicleJourney>MonitoredCall>AimedDepartureTime",
+		report.ErrorPath,
+	)
+}
+
+func TestInspectUnsupported(t *testing.T) {
+	require := require.New(t)
+
+	report := Inspect(readExample(t, "ET_RESP_000.xml"), time.UTC)
+	require.Equal("GetEstimatedTimetableResponse", report.Message)
+	require.NotEmpty(report.Error)
+	require.Empty(report.ErrorPath)
+
+	report = Inspect([]byte("not xml"), time.UTC)
+	require.NotEmpty(report.Error)
+}
@@ -0,0 +1,50 @@
+package linesdiscovery
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Catalog is the set of lines published by a supplier
+type Catalog struct {
+	lines map[string]AnnotatedLineRef
+}
+
+func NewCatalog(lines []AnnotatedLineRef) *Catalog {
+	catalog := &Catalog{
+		lines: make(map[string]AnnotatedLineRef, len(lines)),
+	}
+	for _, line := range lines {
+		catalog.lines[line.LineRef] = line
+	}
+	return catalog
+}
+
+func (c *Catalog) Line(lineRef string) (AnnotatedLineRef, bool) {
+	line, ok := c.lines[lineRef]
+	return line, ok
+}
+
+func (c *Catalog) Lines() []AnnotatedLineRef {
+	lines := make([]AnnotatedLineRef, 0, len(c.lines))
+	for _, line := range c.lines {
+		lines = append(lines, line)
+	}
+	sort.Slice(lines, func(i, j int) bool { return lines[i].LineRef < lines[j].LineRef })
+	return lines
+}
+
+// Validate returns an error listing the line refs unknown to the supplier
+func (c *Catalog) Validate(lineRefs []string) error {
+	unknownLineRefs := make([]string, 0)
+	for _, lineRef := range lineRefs {
+		if _, ok := c.lines[lineRef]; !ok {
+			unknownLineRefs = append(unknownLineRefs, lineRef)
+		}
+	}
+	if len(unknownLineRefs) > 0 {
+		return fmt.Errorf("the `LineRef` are not published by the supplier: %s", strings.Join(unknownLineRefs, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+package linesdiscovery
+
+import (
+	"encoding/xml"
+	"time"
+
+	"github.com/julienbt/siri-sm/internal/siri"
+)
+
+var LINES_DISCOVERY_ANSWER_PATH = siri.AnswerPath{
+	Soap: []string{"LinesDiscoveryResponse", "Answer"},
+	Raw:  []string{"LinesDelivery"},
+}
+
+// LinesDiscoveryAnswer is the `Answer` element in SOAP, and the
+// `LinesDelivery` element in raw SIRI
+type LinesDiscoveryAnswer struct {
+	XMLName           xml.Name
+	ResponseTimestamp time.Time          `xml:"ResponseTimestamp"`
+	Status            bool               `xml:"Status"`
+	AnnotatedLineRefs []AnnotatedLineRef `xml:"AnnotatedLineRef"`
+}
+
+type AnnotatedLineRef struct {
+	XMLName      xml.Name      `xml:"AnnotatedLineRef"`
+	LineRef      string        `xml:"LineRef"`
+	LineName     string        `xml:"LineName"`
+	Monitored    bool          `xml:"Monitored"`
+	Destinations []Destination `xml:"Destinations>Destination"`
+	Directions   []Direction   `xml:"Directions>Direction"`
+}
+
+type Destination struct {
+	DestinationRef string `xml:"DestinationRef"`
+	PlaceName      string `xml:"PlaceName"`
+}
+
+type Direction struct {
+	DirectionRef  string `xml:"DirectionRef"`
+	DirectionName string `xml:"DirectionName"`
+}
@@ -0,0 +1,57 @@
+package linesdiscovery
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/julienbt/siri-sm/internal/siri"
+)
+
+var testDataDir string
+
+func TestMain(m *testing.M) {
+
+	testDataDir = os.Getenv("SIRISM_TEST_DATA_DIR")
+	if testDataDir == "" {
+		panic("$SIRISM_TEST_DATA_DIR isn't set")
+	}
+
+	os.Exit(m.Run())
+}
+
+func TestLinesDiscoveryAnswerDecode(t *testing.T) {
+	require := require.New(t)
+
+	htmlRespBody, err := ioutil.ReadFile(
+		fmt.Sprintf(
+			"%s/examples/LD_RESP_000.xml",
+			testDataDir,
+		),
+	)
+	require.Nil(err)
+
+	answer := LinesDiscoveryAnswer{}
+	err = siri.BINDING_SOAP_11.DecodeAnswer(htmlRespBody, LINES_DISCOVERY_ANSWER_PATH, &answer)
+	require.Nil(err)
+	require.True(answer.Status)
+	require.Len(answer.AnnotatedLineRefs, 2)
+
+	line := answer.AnnotatedLineRefs[0]
+	require.Equal("ILEVIA:Line:BP:L1:LOC", line.LineRef)
+	require.Equal("Liane 1", line.LineName)
+	require.True(line.Monitored)
+	require.Equal([]Destination{{DestinationRef: "ILEVIA:StopPoint:BP:LEZ001:LOC", PlaceName: "Lezennes"}}, line.Destinations)
+	require.Len(line.Directions, 2)
+	require.Equal("Retour", line.Directions[1].DirectionName)
+	require.False(answer.AnnotatedLineRefs[1].Monitored)
+
+	catalog := NewCatalog(answer.AnnotatedLineRefs)
+	require.Nil(catalog.Validate([]string{"ILEVIA:Line:BP:L1:LOC", "ILEVIA:Line:BP:L2:LOC"}))
+	err = catalog.Validate([]string{"ILEVIA:Line:BP:L1:LOC", "ILEVIA:Line:BP:L9:LOC"})
+	require.NotNil(err)
+	require.Contains(err.Error(), "ILEVIA:Line:BP:L9:LOC")
+}
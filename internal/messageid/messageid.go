@@ -0,0 +1,170 @@
+// Package messageid generates the `MessageIdentifier` of the requests, unique
+// even for the requests sent within the same second, and correlates the
+// `RequestMessageRef` of the answers with them
+package messageid
+
+import (
+	"crypto/rand"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// DEFAULT_PATTERN is the pattern of the suppliers without configured one, e.g.
+// `KISIO2:ResponseMessage:20220830_080000_3f9a1c07_42`, the instance keeps
+// apart the identifiers of two processes sending requests at the same time
+const DEFAULT_PATTERN string = "{requestor}:{kind}:{timestamp}_{instance}_{sequence}"
+
+// TIME_LAYOUT is the layout of the `{timestamp}` placeholder
+const TIME_LAYOUT string = "20060102_150405"
+
+// The placeholders of a pattern
+const (
+	PLACEHOLDER_REQUESTOR string = "{requestor}" // requestor or subscriber ref
+	PLACEHOLDER_KIND      string = "{kind}"      // e.g. `ResponseMessage` or `LinesDiscovery`
+	PLACEHOLDER_TIMESTAMP string = "{timestamp}" // request timestamp, see `TIME_LAYOUT`
+	PLACEHOLDER_SEQUENCE  string = "{sequence}"  // counter of the identifiers generated by the process
+	PLACEHOLDER_INSTANCE  string = "{instance}"  // random id of the process
+	PLACEHOLDER_UUID      string = "{uuid}"      // random UUID
+)
+
+var placeholderRegexp = regexp.MustCompile(`\{[^{}]*\}`)
+
+// sequence is shared by all the patterns, the identifiers of two suppliers
+// with the same pattern don't collide either
+var sequence uint64
+
+// instance is drawn once per process, the sequences of two processes start
+// both at 1
+var instance = newInstance()
+
+// Validate checks the placeholders of a pattern, which needs `{sequence}` or
+// `{uuid}` for the identifiers to be unique, an empty pattern is the default
+func Validate(pattern string) error {
+	if pattern == "" {
+		return nil
+	}
+	unique := false
+	for _, placeholder := range placeholderRegexp.FindAllString(pattern, -1) {
+		switch placeholder {
+		case PLACEHOLDER_REQUESTOR, PLACEHOLDER_KIND, PLACEHOLDER_TIMESTAMP, PLACEHOLDER_INSTANCE:
+		case PLACEHOLDER_SEQUENCE, PLACEHOLDER_UUID:
+			unique = true
+		default:
+			return fmt.Errorf("unknown placeholder %s in the message identifier pattern %s", placeholder, pattern)
+		}
+	}
+	if !unique {
+		return fmt.Errorf("the message identifier pattern %s has neither %s nor %s", pattern, PLACEHOLDER_SEQUENCE, PLACEHOLDER_UUID)
+	}
+	return nil
+}
+
+// New generates a message identifier from the pattern, `DEFAULT_PATTERN`
+// when empty
+func New(pattern string, requestor string, kind string, timestamp time.Time) (string, error) {
+	if pattern == "" {
+		pattern = DEFAULT_PATTERN
+	}
+	err := Validate(pattern)
+	if err != nil {
+		return "", err
+	}
+	var uuidErr error
+	identifier := placeholderRegexp.ReplaceAllStringFunc(pattern, func(placeholder string) string {
+		switch placeholder {
+		case PLACEHOLDER_REQUESTOR:
+			return requestor
+		case PLACEHOLDER_KIND:
+			return kind
+		case PLACEHOLDER_TIMESTAMP:
+			return timestamp.Format(TIME_LAYOUT)
+		case PLACEHOLDER_SEQUENCE:
+			return strconv.FormatUint(atomic.AddUint64(&sequence, 1), 10)
+		case PLACEHOLDER_INSTANCE:
+			return instance
+		}
+		var uuid string
+		uuid, uuidErr = newUuid()
+		return uuid
+	})
+	if uuidErr != nil {
+		return "", uuidErr
+	}
+	return identifier, nil
+}
+
+// newUuid returns a random (version 4) UUID
+func newUuid() (string, error) {
+	b := make([]byte, 16)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", fmt.Errorf("error generating an UUID: %s", err)
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), nil
+}
+
+// newInstance returns 8 random hex digits, or the pid and the start time
+// without random source
+func newInstance() string {
+	b := make([]byte, 4)
+	_, err := rand.Read(b)
+	if err != nil {
+		return fmt.Sprintf("%d%x", os.Getpid(), time.Now().UnixNano())
+	}
+	return fmt.Sprintf("%x", b)
+}
+
+// Correlator maps the `MessageIdentifier` of the requests sent to a supplier
+// to the request they identify, e.g. a subscription identifier
+type Correlator struct {
+	mutex    sync.RWMutex
+	requests map[string]string
+}
+
+func NewCorrelator() *Correlator {
+	return &Correlator{
+		requests: make(map[string]string),
+	}
+}
+
+// Add records a request, its own identifier is also accepted as
+// `RequestMessageRef` since some suppliers echo it instead
+func (c *Correlator) Add(messageIdentifier string, request string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.requests[messageIdentifier] = request
+	if request != "" {
+		c.requests[request] = request
+	}
+}
+
+// Match returns the request of a `RequestMessageRef`, ok is false for an
+// unknown ref
+func (c *Correlator) Match(requestMessageRef string) (string, bool) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	request, ok := c.requests[requestMessageRef]
+	return request, ok
+}
+
+// Check logs a warning when the `RequestMessageRef` of an answer is not the
+// `MessageIdentifier` of its request, and returns false
+func Check(logger *logrus.Entry, operation string, messageIdentifier string, requestMessageRef string) bool {
+	if requestMessageRef == "" || requestMessageRef == messageIdentifier {
+		return true
+	}
+	logger.WithFields(logrus.Fields{
+		"messageIdentifier": messageIdentifier,
+		"requestMessageRef": requestMessageRef,
+	}).Warnf("the %s answer refers to another request", operation)
+	return false
+}
@@ -0,0 +1,92 @@
+package messageid
+
+import (
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewIsUniqueWithinASecond(t *testing.T) {
+	require := require.New(t)
+	timestamp := time.Date(2022, 8, 30, 8, 0, 0, 0, time.UTC)
+
+	identifiers := make(map[string]bool)
+	for i := 0; i < 100; i++ {
+		identifier, err := New("", "KISIO2", "ResponseMessage", timestamp)
+		require.Nil(err)
+		require.Regexp(`^KISIO2:ResponseMessage:20220830_080000_[0-9a-f]{8}_\d+$`, identifier)
+		require.False(identifiers[identifier], identifier)
+		identifiers[identifier] = true
+	}
+}
+
+func TestNewInstance(t *testing.T) {
+	require := require.New(t)
+
+	first, err := New("{instance}_{sequence}", "KISIO2", "Message", time.Now())
+	require.Nil(err)
+	second, err := New("{instance}_{sequence}", "KISIO2", "Message", time.Now())
+	require.Nil(err)
+	require.Equal(instance+"_", first[:len(instance)+1])
+	require.Equal(instance+"_", second[:len(instance)+1])
+	require.NotEqual(first, second)
+	// Another process draws another instance
+	require.NotEqual(instance, newInstance())
+}
+
+func TestNewUuid(t *testing.T) {
+	require := require.New(t)
+
+	identifier, err := New("{requestor}-{uuid}", "KISIO2", "Message", time.Now())
+	require.Nil(err)
+	require.Regexp(regexp.MustCompile(`^KISIO2-[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`), identifier)
+}
+
+func TestValidate(t *testing.T) {
+	require := require.New(t)
+
+	require.Nil(Validate(DEFAULT_PATTERN))
+	require.Nil(Validate("SUBREQ_{sequence}"))
+	require.NotNil(Validate("{requestor}:{kind}:{timestamp}"))
+	require.NotNil(Validate("{requestor}:{instance}"))
+	require.NotNil(Validate("{requestor}:{sequense}"))
+
+	_, err := New("{requestor}:{timestamp}", "KISIO2", "Message", time.Now())
+	require.NotNil(err)
+}
+
+func TestCorrelator(t *testing.T) {
+	require := require.New(t)
+	correlator := NewCorrelator()
+	correlator.Add("KISIO2:Message:20220830_080000_1", "KISIO2:Subscription:arret_CAS001:LOC")
+	correlator.Add("KISIO2:Message:20220830_080000_2", "KISIO2:Subscription:arret_CAS002:LOC")
+
+	request, ok := correlator.Match("KISIO2:Message:20220830_080000_2")
+	require.True(ok)
+	require.Equal("KISIO2:Subscription:arret_CAS002:LOC", request)
+
+	request, ok = correlator.Match("KISIO2:Subscription:arret_CAS001:LOC")
+	require.True(ok)
+	require.Equal("KISIO2:Subscription:arret_CAS001:LOC", request)
+
+	_, ok = correlator.Match("SUBREQ")
+	require.False(ok)
+}
+
+func TestCheck(t *testing.T) {
+	require := require.New(t)
+	logger, hook := test.NewNullLogger()
+	entry := logrus.NewEntry(logger)
+
+	require.True(Check(entry, "CheckStatus", "KISIO2:ResponseMessage:1", "KISIO2:ResponseMessage:1"))
+	require.True(Check(entry, "CheckStatus", "KISIO2:ResponseMessage:1", ""))
+	require.Empty(hook.AllEntries())
+
+	require.False(Check(entry, "CheckStatus", "KISIO2:ResponseMessage:1", "KISIO2:ResponseMessage:0"))
+	require.Equal(logrus.WarnLevel, hook.LastEntry().Level)
+	require.Equal("KISIO2:ResponseMessage:0", hook.LastEntry().Data["requestMessageRef"])
+}
@@ -0,0 +1,122 @@
+package metrics
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/julienbt/siri-sm/internal/subscriptionstate"
+	"github.com/julienbt/siri-sm/internal/visitstore"
+)
+
+var stopVisitsDesc = prometheus.NewDesc(
+	prometheus.BuildFQName(NAMESPACE, "", "stop_visits"),
+	"Visits of the stops in the store.",
+	[]string{"supplier", "stop"},
+	nil,
+)
+
+// VisitsCollector reads the number of visits of every stop at each scrape
+type VisitsCollector struct {
+	Store *visitstore.Store
+}
+
+func (c *VisitsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- stopVisitsDesc
+}
+
+func (c *VisitsCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, stop := range c.Store.All() {
+		ch <- prometheus.MustNewConstMetric(
+			stopVisitsDesc,
+			prometheus.GaugeValue,
+			float64(len(stop.Visits)),
+			stop.Supplier,
+			string(stop.StopRef),
+		)
+	}
+}
+
+var (
+	subscriptionValidUntilDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(NAMESPACE, "", "subscription_valid_until_seconds"),
+		"Expiry of the active subscriptions, as a Unix timestamp.",
+		[]string{"supplier", "subscription"},
+		nil,
+	)
+	subscriptionLastNotificationDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(NAMESPACE, "", "subscription_last_notification_seconds"),
+		"Last notification of the active subscriptions, as a Unix timestamp.",
+		[]string{"supplier", "subscription"},
+		nil,
+	)
+	activeSubscriptionsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(NAMESPACE, "", "active_subscriptions"),
+		"Subscriptions accepted and not expired.",
+		[]string{"supplier"},
+		nil,
+	)
+	subscriptionStateErrorDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(NAMESPACE, "", "subscription_state_error"),
+		"The subscription state couldn't be read.",
+		nil,
+		nil,
+	)
+)
+
+// SubscriptionsCollector reads the active subscriptions of the state at each
+// scrape, `Suppliers` names the suppliers by address
+type SubscriptionsCollector struct {
+	State     *subscriptionstate.Store
+	Suppliers map[string]string
+}
+
+func (c *SubscriptionsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- subscriptionValidUntilDesc
+	ch <- subscriptionLastNotificationDesc
+	ch <- activeSubscriptionsDesc
+}
+
+func (c *SubscriptionsCollector) Collect(ch chan<- prometheus.Metric) {
+	records, err := c.State.All()
+	if err != nil {
+		ch <- prometheus.NewInvalidMetric(subscriptionStateErrorDesc, err)
+		return
+	}
+	now := time.Now()
+	active := make(map[string]int)
+	for _, record := range records {
+		supplier := record.Supplier
+		if name, ok := c.Suppliers[supplier]; ok {
+			supplier = name
+		}
+		if _, ok := active[supplier]; !ok {
+			active[supplier] = 0
+		}
+		if !record.Active(now) {
+			continue
+		}
+		active[supplier]++
+		if !record.ValidUntil.IsZero() {
+			ch <- prometheus.MustNewConstMetric(
+				subscriptionValidUntilDesc,
+				prometheus.GaugeValue,
+				float64(record.ValidUntil.Unix()),
+				supplier,
+				record.SubscriptionRef,
+			)
+		}
+		if !record.LastNotification.IsZero() {
+			ch <- prometheus.MustNewConstMetric(
+				subscriptionLastNotificationDesc,
+				prometheus.GaugeValue,
+				float64(record.LastNotification.Unix()),
+				supplier,
+				record.SubscriptionRef,
+			)
+		}
+	}
+	for supplier, count := range active {
+		ch <- prometheus.MustNewConstMetric(activeSubscriptionsDesc, prometheus.GaugeValue, float64(count), supplier)
+	}
+}
@@ -0,0 +1,79 @@
+// Package metrics exposes the behaviour of the suppliers to Prometheus: the
+// SIRI exchanges are measured by a transport of `siri.SoapCall`, the visits
+// and the subscriptions are read from their stores at every scrape
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const NAMESPACE string = "sirism"
+
+var (
+	requestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: NAMESPACE,
+		Name:      "siri_requests_total",
+		Help:      "SIRI requests sent to the suppliers.",
+	}, []string{"supplier", "action"})
+	requestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: NAMESPACE,
+		Name:      "siri_request_duration_seconds",
+		Help:      "Latency of the SIRI requests, until the whole response is read.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"supplier", "action"})
+	responsesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: NAMESPACE,
+		Name:      "siri_responses_total",
+		Help:      "Responses of the suppliers by HTTP status code, \"error\" without response.",
+	}, []string{"supplier", "action", "code"})
+	errorConditionsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: NAMESPACE,
+		Name:      "siri_error_conditions_total",
+		Help:      "SIRI `ErrorCondition` and SOAP faults answered by the suppliers.",
+	}, []string{"supplier", "action", "condition"})
+	notificationsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: NAMESPACE,
+		Name:      "notifications_received_total",
+		Help:      "Notifications received from the suppliers by subscription, the heartbeats without subscription ref.",
+	}, []string{"supplier", "subscription_ref"})
+)
+
+// Registry holds the metrics of the process, the collectors of the stores
+// are registered by the commands which have them
+var Registry = prometheus.NewRegistry()
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		requestsTotal,
+		requestDuration,
+		responsesTotal,
+		errorConditionsTotal,
+		notificationsTotal,
+	)
+}
+
+// Handler serves the metrics of the `Registry` in the Prometheus format
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
+
+// UNKNOWN_SUPPLIER is the supplier of the notifications of an unknown
+// producer, the label values stay bounded by the configured suppliers
+const UNKNOWN_SUPPLIER string = "unknown"
+
+// UNKNOWN_SUBSCRIPTION is the subscription ref of the notifications of the
+// subscriptions missing from the subscription state, the label values stay
+// bounded by the persisted subscriptions
+const UNKNOWN_SUBSCRIPTION string = "unknown"
+
+// RecordNotification counts a notification, `supplier` is the name of the
+// supplier and `subscriptionRef` is empty for a heartbeat
+func RecordNotification(supplier string, subscriptionRef string) {
+	notificationsTotal.WithLabelValues(supplier, subscriptionRef).Inc()
+}
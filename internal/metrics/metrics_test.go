@@ -0,0 +1,132 @@
+package metrics
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+
+	"github.com/julienbt/siri-sm/internal/getstopmonitoring"
+	"github.com/julienbt/siri-sm/internal/siri"
+	"github.com/julienbt/siri-sm/internal/subscriptionstate"
+	"github.com/julienbt/siri-sm/internal/visitstore"
+)
+
+const ERROR_CONDITION_BODY string = `<S:Envelope xmlns:S="http://schemas.xmlsoap.org/soap/envelope/">
+	<S:Body>
+		<sw:GetStopMonitoringResponse xmlns:sw="http://wsdl.siri.org.uk" xmlns:siri="http://www.siri.org.uk/siri">
+			<Answer>
+				<siri:StopMonitoringDelivery>
+					<siri:Status>false</siri:Status>
+					<siri:ErrorCondition>
+						<siri:InvalidDataReferencesError>
+							<siri:ErrorText>Unknown stop</siri:ErrorText>
+						</siri:InvalidDataReferencesError>
+						<siri:Description>CAS999</siri:Description>
+					</siri:ErrorCondition>
+				</siri:StopMonitoringDelivery>
+			</Answer>
+		</sw:GetStopMonitoringResponse>
+	</S:Body>
+</S:Envelope>`
+
+func TestErrorConditions(t *testing.T) {
+	require := require.New(t)
+	require.Equal([]string{"InvalidDataReferencesError"}, ErrorConditions([]byte(ERROR_CONDITION_BODY)))
+	require.Equal([]string{"Fault"}, ErrorConditions([]byte(`<Envelope><Body><Fault><faultstring>down</faultstring></Fault></Body></Envelope>`)))
+	require.Empty(ErrorConditions([]byte(`{"Siri": {}}`)))
+}
+
+func TestRecordNotification(t *testing.T) {
+	require := require.New(t)
+	RecordNotification("notification-test", "KISIO2:Subscription:arret_CAS001:LOC")
+	RecordNotification("notification-test", "KISIO2:Subscription:arret_CAS001:LOC")
+	RecordNotification("notification-test", "")
+	require.Equal(2.0, testutil.ToFloat64(notificationsTotal.WithLabelValues("notification-test", "KISIO2:Subscription:arret_CAS001:LOC")))
+	require.Equal(1.0, testutil.ToFloat64(notificationsTotal.WithLabelValues("notification-test", "")))
+}
+
+func TestTransport(t *testing.T) {
+	require := require.New(t)
+	statusCode := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(statusCode)
+		w.Write([]byte(ERROR_CONDITION_BODY))
+	}))
+	defer server.Close()
+	transport := &Transport{Suppliers: map[string]string{server.URL: "transport-test"}}
+	client := &http.Client{Transport: transport}
+
+	req, _, err := siri.BINDING_SOAP_11.NewHttpRequest(server.URL, "GetStopMonitoring", "<GetStopMonitoring/>")
+	require.Nil(err)
+	resp, err := client.Do(req)
+	require.Nil(err)
+	body, err := ioutil.ReadAll(resp.Body)
+	require.Nil(err)
+	require.Equal(ERROR_CONDITION_BODY, string(body))
+
+	statusCode = http.StatusInternalServerError
+	req, _, err = siri.BINDING_SOAP_12.NewHttpRequest(server.URL, "GetStopMonitoring", "<GetStopMonitoring/>")
+	require.Nil(err)
+	_, err = client.Do(req)
+	require.Nil(err)
+
+	require.Equal(2.0, testutil.ToFloat64(requestsTotal.WithLabelValues("transport-test", "GetStopMonitoring")))
+	require.Equal(1.0, testutil.ToFloat64(responsesTotal.WithLabelValues("transport-test", "GetStopMonitoring", "200")))
+	require.Equal(1.0, testutil.ToFloat64(responsesTotal.WithLabelValues("transport-test", "GetStopMonitoring", "500")))
+	require.Equal(2.0, testutil.ToFloat64(errorConditionsTotal.WithLabelValues("transport-test", "GetStopMonitoring", "InvalidDataReferencesError")))
+
+	// The action is the SIRI message in every binding
+	server.Close()
+	req, _, err = siri.BINDING_RAW.NewHttpRequest(server.URL, "CheckStatus", `<Siri><CheckStatusRequest/></Siri>`)
+	require.Nil(err)
+	_, err = client.Do(req)
+	require.NotNil(err)
+	require.Equal(1.0, testutil.ToFloat64(responsesTotal.WithLabelValues("transport-test", "CheckStatusRequest", "error")))
+}
+
+func TestVisitsCollector(t *testing.T) {
+	require := require.New(t)
+	store := visitstore.NewStore()
+	store.Update("lille", "CAS001", make([]getstopmonitoring.MonitoredStopVisit, 3), time.Now())
+	store.Update("lille", "CAS002", nil, time.Now())
+
+	expected := `
+# HELP sirism_stop_visits Visits of the stops in the store.
+# TYPE sirism_stop_visits gauge
+sirism_stop_visits{stop="CAS001",supplier="lille"} 3
+sirism_stop_visits{stop="CAS002",supplier="lille"} 0
+`
+	require.Nil(testutil.CollectAndCompare(&VisitsCollector{Store: store}, strings.NewReader(expected)))
+}
+
+func TestSubscriptionsCollector(t *testing.T) {
+	require := require.New(t)
+	state, err := subscriptionstate.Open(filepath.Join(t.TempDir(), "subscriptions.db"))
+	require.Nil(err)
+	validUntil := time.Now().Add(time.Hour).Truncate(time.Second)
+	require.Nil(state.Put(
+		subscriptionstate.Record{
+			Supplier:        "http://lille",
+			SubscriptionRef: "KISIO2:Subscription:arret_CAS001:LOC",
+			Status:          subscriptionstate.STATUS_ACTIVE,
+			ValidUntil:      validUntil,
+		},
+		subscriptionstate.Record{
+			Supplier:        "http://lille",
+			SubscriptionRef: "KISIO2:Subscription:arret_CAS002:LOC",
+			Status:          subscriptionstate.STATUS_REJECTED,
+		},
+	))
+	collector := &SubscriptionsCollector{State: state, Suppliers: map[string]string{"http://lille": "lille"}}
+
+	require.Equal(2, testutil.CollectAndCount(collector))
+	require.Equal(1, testutil.CollectAndCount(collector, "sirism_active_subscriptions"))
+	require.Equal(1, testutil.CollectAndCount(collector, "sirism_subscription_valid_until_seconds"))
+}
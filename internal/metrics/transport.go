@@ -0,0 +1,94 @@
+package metrics
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/julienbt/siri-sm/internal/siri"
+)
+
+// Transport measures the requests sent through `Next`, `http.DefaultTransport`
+// when nil
+type Transport struct {
+	Next http.RoundTripper
+	// Suppliers names the suppliers by address, the host of the request is
+	// the supplier label of the other addresses
+	Suppliers map[string]string
+}
+
+// Install measures the requests of `siri.SoapCall`, after the `recording`
+// package installed its own transport
+func Install(suppliers map[string]string) {
+	siri.Transport = &Transport{Next: siri.Transport, Suppliers: suppliers}
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	next := t.Next
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	reqBody, err := siri.ReadRequestBody(req)
+	if err != nil {
+		return nil, err
+	}
+	supplier := siri.SupplierOf(req, t.Suppliers)
+	action := siri.ActionOf(req, reqBody)
+	requestsTotal.WithLabelValues(supplier, action).Inc()
+	startedAt := time.Now()
+
+	resp, err := next.RoundTrip(req)
+	if err != nil {
+		requestDuration.WithLabelValues(supplier, action).Observe(time.Since(startedAt).Seconds())
+		responsesTotal.WithLabelValues(supplier, action, "error").Inc()
+		return nil, err
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	requestDuration.WithLabelValues(supplier, action).Observe(time.Since(startedAt).Seconds())
+	if err != nil {
+		responsesTotal.WithLabelValues(supplier, action, "error").Inc()
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+	responsesTotal.WithLabelValues(supplier, action, strconv.Itoa(resp.StatusCode)).Inc()
+	for _, condition := range ErrorConditions(body) {
+		errorConditionsTotal.WithLabelValues(supplier, action, condition).Inc()
+	}
+	return resp, nil
+}
+
+// ErrorConditions returns the kind of every error of a response: the first
+// element of each `ErrorCondition` (e.g. `CapabilityNotSupportedError`), and
+// `Fault` for a SOAP fault
+func ErrorConditions(body []byte) []string {
+	conditions := make([]string, 0)
+	d := xml.NewDecoder(bytes.NewReader(body))
+	inErrorCondition := false
+	for {
+		token, err := d.Token()
+		if err != nil {
+			// io.EOF, or not XML, e.g. SIRI Lite
+			return conditions
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			switch {
+			case t.Name.Local == "Fault":
+				conditions = append(conditions, "Fault")
+			case t.Name.Local == "ErrorCondition":
+				inErrorCondition = true
+			case inErrorCondition:
+				inErrorCondition = false
+				conditions = append(conditions, t.Name.Local)
+			}
+		case xml.EndElement:
+			if t.Name.Local == "ErrorCondition" {
+				inErrorCondition = false
+			}
+		}
+	}
+}
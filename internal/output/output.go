@@ -0,0 +1,319 @@
+// Package output renders the results of the CLI as JSON, CSV, XML or as a
+// table, to be read or piped into other tools
+package output
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/julienbt/siri-sm/internal/checkstatus"
+	"github.com/julienbt/siri-sm/internal/common/ioutils"
+	"github.com/julienbt/siri-sm/internal/getstopmonitoring"
+	"github.com/julienbt/siri-sm/internal/reconcile"
+	"github.com/julienbt/siri-sm/internal/subscribe"
+	"github.com/julienbt/siri-sm/internal/unsubscribe"
+)
+
+const (
+	FORMAT_JSON  string = "json"
+	FORMAT_CSV   string = "csv"
+	FORMAT_TABLE string = "table"
+	FORMAT_XML   string = "xml"
+)
+
+var Formats = []string{FORMAT_JSON, FORMAT_CSV, FORMAT_TABLE, FORMAT_XML}
+
+func ParseFormat(s string) (string, error) {
+	for _, format := range Formats {
+		if s == format {
+			return s, nil
+		}
+	}
+	return "", fmt.Errorf("unknown output format: %s", s)
+}
+
+// Options are the `--output` and `--dump` flags of the commands
+type Options struct {
+	Format string
+	Dump   bool
+}
+
+func (o *Options) Register(flags *flag.FlagSet) {
+	flags.StringVar(&o.Format, "output", FORMAT_TABLE, `"json", "csv", "table" or "xml"`)
+	flags.BoolVar(&o.Dump, "dump", false, "print the SIRI requests and responses on stderr")
+}
+
+func (o *Options) Validate() error {
+	_, err := ParseFormat(o.Format)
+	return err
+}
+
+// DumpExchange prints the request and the response when `--dump` is set,
+// they are written on stderr to keep stdout for the results
+func (o *Options) DumpExchange(htmlReqBody string, htmlRespBody []byte) {
+	if !o.Dump {
+		return
+	}
+	if len(htmlReqBody) > 0 {
+		fmt.Fprintln(os.Stderr, htmlReqBody)
+	}
+	if htmlRespBody != nil {
+		fmt.Fprintln(os.Stderr, ioutils.GetPrettyPrintOfHtmlBody(htmlRespBody))
+	}
+}
+
+// Write renders the records on stdout in the selected format
+func (o *Options) Write(name string, header []string, records []Record) error {
+	return Write(os.Stdout, o.Format, name, header, records)
+}
+
+// Record is a result rendered as a row of the CSV and table formats, and
+// as an element (its `XMLName`) or an object in the other formats
+type Record interface {
+	Row() []string
+}
+
+// Write renders the records, `name` is the root element in XML and the
+// header is written even without record in CSV and table
+func Write(w io.Writer, format string, name string, header []string, records []Record) error {
+	switch format {
+	case FORMAT_JSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(records)
+	case FORMAT_XML:
+		document := struct {
+			XMLName xml.Name
+			Records []Record
+		}{XMLName: xml.Name{Local: name}, Records: records}
+		encoder := xml.NewEncoder(w)
+		encoder.Indent("", "  ")
+		err := encoder.Encode(document)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(w)
+		return err
+	case FORMAT_CSV:
+		writer := csv.NewWriter(w)
+		err := writer.Write(header)
+		if err != nil {
+			return err
+		}
+		for _, record := range records {
+			err = writer.Write(record.Row())
+			if err != nil {
+				return err
+			}
+		}
+		writer.Flush()
+		return writer.Error()
+	case FORMAT_TABLE:
+		writer := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		writeTableRow(writer, header)
+		for _, record := range records {
+			writeTableRow(writer, record.Row())
+		}
+		return writer.Flush()
+	}
+	return fmt.Errorf("unknown output format: %s", format)
+}
+
+func writeTableRow(w io.Writer, row []string) {
+	for i, cell := range row {
+		if i > 0 {
+			fmt.Fprint(w, "\t")
+		}
+		if cell == "" {
+			cell = "-"
+		}
+		fmt.Fprint(w, cell)
+	}
+	fmt.Fprintln(w)
+}
+
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
+
+func optionalTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+type CheckStatusRecord struct {
+	XMLName                    xml.Name   `xml:"checkStatus" json:"-"`
+	Status                     bool       `xml:"status" json:"status"`
+	SupplierServiceStartedTime *time.Time `xml:"serviceStartedTime,omitempty" json:"service_started_time,omitempty"`
+	LastSupplierCheckStatusOk  *time.Time `xml:"lastCheckStatusOk,omitempty" json:"last_check_status_ok,omitempty"`
+}
+
+var CHECK_STATUS_HEADER = []string{"status", "service_started_time", "last_check_status_ok"}
+
+func NewCheckStatusRecord(result checkstatus.CheckStatusResult) *CheckStatusRecord {
+	return &CheckStatusRecord{
+		Status:                     true,
+		SupplierServiceStartedTime: optionalTime(result.SupplierServiceStartedTime),
+		LastSupplierCheckStatusOk:  optionalTime(result.LastSupplierCheckStatusOk),
+	}
+}
+
+func (r *CheckStatusRecord) Row() []string {
+	return []string{
+		strconv.FormatBool(r.Status),
+		formatTime(r.SupplierServiceStartedTime),
+		formatTime(r.LastSupplierCheckStatusOk),
+	}
+}
+
+// SubscriptionRecord is the outcome of a subscription or of its termination
+type SubscriptionRecord struct {
+	XMLName         xml.Name   `xml:"subscription" json:"-"`
+	SubscriptionRef string     `xml:"subscriptionRef" json:"subscription_ref"`
+	SubscriberRef   string     `xml:"subscriberRef" json:"subscriber_ref"`
+	Status          bool       `xml:"status" json:"status"`
+	ValidUntil      *time.Time `xml:"validUntil,omitempty" json:"valid_until,omitempty"`
+	Error           string     `xml:"error,omitempty" json:"error,omitempty"`
+}
+
+var SUBSCRIPTION_HEADER = []string{"subscription_ref", "subscriber_ref", "status", "valid_until", "error"}
+
+func NewSubscriptionRecord(status subscribe.ResponseStatus) *SubscriptionRecord {
+	return &SubscriptionRecord{
+		SubscriptionRef: status.SubscriptionRef,
+		SubscriberRef:   status.SubscriberRef,
+		Status:          status.Status,
+		ValidUntil:      optionalTime(time.Time(status.ValidUntil)),
+		Error:           status.ErrorText,
+	}
+}
+
+func NewTerminationRecord(status unsubscribe.TerminationResponseStatus) *SubscriptionRecord {
+	return &SubscriptionRecord{
+		SubscriptionRef: status.SubscriptionRef,
+		SubscriberRef:   status.SubscriberRef,
+		Status:          status.Status,
+		Error:           status.Error(),
+	}
+}
+
+func (r *SubscriptionRecord) Row() []string {
+	return []string{
+		r.SubscriptionRef,
+		r.SubscriberRef,
+		strconv.FormatBool(r.Status),
+		formatTime(r.ValidUntil),
+		r.Error,
+	}
+}
+
+// ReconcileRecord is a step of a reconciliation plan, with its outcome once
+// applied
+type ReconcileRecord struct {
+	XMLName         xml.Name   `xml:"step" json:"-"`
+	Action          string     `xml:"action" json:"action"`
+	SubscriptionRef string     `xml:"subscriptionRef" json:"subscription_ref"`
+	StopId          string     `xml:"stopId,omitempty" json:"stop_id,omitempty"`
+	LineRef         string     `xml:"lineRef,omitempty" json:"line_ref,omitempty"`
+	Status          *bool      `xml:"status,omitempty" json:"status,omitempty"`
+	ValidUntil      *time.Time `xml:"validUntil,omitempty" json:"valid_until,omitempty"`
+	Error           string     `xml:"error,omitempty" json:"error,omitempty"`
+}
+
+var RECONCILE_HEADER = []string{"action", "subscription_ref", "stop_id", "line_ref", "status", "valid_until", "error"}
+
+func NewReconcileRecord(step reconcile.Step) *ReconcileRecord {
+	record := &ReconcileRecord{
+		Action:          step.Action,
+		SubscriptionRef: step.SubscriptionRef,
+		StopId:          step.StopPointId,
+		LineRef:         step.LineRef,
+		ValidUntil:      optionalTime(step.ValidUntil),
+		Error:           step.Error,
+	}
+	if step.Applied {
+		status := step.Status
+		record.Status = &status
+	}
+	return record
+}
+
+func (r *ReconcileRecord) Row() []string {
+	status := ""
+	if r.Status != nil {
+		status = strconv.FormatBool(*r.Status)
+	}
+	return []string{
+		r.Action,
+		r.SubscriptionRef,
+		r.StopId,
+		r.LineRef,
+		status,
+		formatTime(r.ValidUntil),
+		r.Error,
+	}
+}
+
+type VisitRecord struct {
+	XMLName               xml.Name   `xml:"visit" json:"-"`
+	StopRef               string     `xml:"stopRef" json:"stop_ref"`
+	LineRef               string     `xml:"lineRef" json:"line_ref"`
+	Direction             string     `xml:"direction" json:"direction"`
+	DestinationName       string     `xml:"destinationName" json:"destination_name"`
+	AimedDepartureTime    *time.Time `xml:"aimedDepartureTime,omitempty" json:"aimed_departure_time,omitempty"`
+	ExpectedDepartureTime *time.Time `xml:"expectedDepartureTime,omitempty" json:"expected_departure_time,omitempty"`
+	DepartureStatus       string     `xml:"departureStatus,omitempty" json:"departure_status,omitempty"`
+	VehicleJourneyRef     string     `xml:"vehicleJourneyRef" json:"vehicle_journey_ref"`
+}
+
+var VISIT_HEADER = []string{
+	"stop_ref",
+	"line_ref",
+	"direction",
+	"destination_name",
+	"aimed_departure_time",
+	"expected_departure_time",
+	"departure_status",
+	"vehicle_journey_ref",
+}
+
+func NewVisitRecord(visit *getstopmonitoring.MonitoredStopVisit) *VisitRecord {
+	journey := &visit.MonitoredVehicleJourney
+	call := &journey.MonitoredCall
+	return &VisitRecord{
+		StopRef:               string(visit.MonitoringRef),
+		LineRef:               string(journey.LineRef),
+		Direction:             fmt.Sprint(journey.DirectionName),
+		DestinationName:       journey.DestinationName,
+		AimedDepartureTime:    optionalTime(time.Time(call.AimedDepartureTime)),
+		ExpectedDepartureTime: optionalTime(time.Time(call.ExpectedDepartureTime)),
+		DepartureStatus:       call.DepartureStatus,
+		VehicleJourneyRef:     journey.FramedVehicleJourneyRef.DatedVehicleJourneyRef,
+	}
+}
+
+func (r *VisitRecord) Row() []string {
+	return []string{
+		r.StopRef,
+		r.LineRef,
+		r.Direction,
+		r.DestinationName,
+		formatTime(r.AimedDepartureTime),
+		formatTime(r.ExpectedDepartureTime),
+		r.DepartureStatus,
+		r.VehicleJourneyRef,
+	}
+}
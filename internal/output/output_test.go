@@ -0,0 +1,104 @@
+package output
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	siri_time "github.com/julienbt/siri-sm/internal/common/time"
+	"github.com/julienbt/siri-sm/internal/getstopmonitoring"
+	"github.com/julienbt/siri-sm/internal/subscribe"
+)
+
+func newRecords() []Record {
+	aimed := time.Date(2022, 8, 30, 8, 5, 0, 0, time.UTC)
+	visit := getstopmonitoring.MonitoredStopVisit{MonitoringRef: "CAS001"}
+	visit.MonitoredVehicleJourney.LineRef = "L1"
+	visit.MonitoredVehicleJourney.DestinationName = "Gare, Lille"
+	visit.MonitoredVehicleJourney.MonitoredCall.AimedDepartureTime = siri_time.Time(aimed)
+	return []Record{
+		NewVisitRecord(&visit),
+		NewSubscriptionRecord(subscribe.ResponseStatus{SubscriptionRef: "SUB1", Status: true}),
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	require := require.New(t)
+	for _, format := range Formats {
+		parsed, err := ParseFormat(format)
+		require.Nil(err)
+		require.Equal(format, parsed)
+	}
+	_, err := ParseFormat("yaml")
+	require.NotNil(err)
+}
+
+func TestWriteJson(t *testing.T) {
+	require := require.New(t)
+	var buf bytes.Buffer
+	err := Write(&buf, FORMAT_JSON, "records", VISIT_HEADER, newRecords())
+	require.Nil(err)
+
+	var decoded []map[string]interface{}
+	require.Nil(json.Unmarshal(buf.Bytes(), &decoded))
+	require.Len(decoded, 2)
+	require.Equal("CAS001", decoded[0]["stop_ref"])
+	require.Equal("2022-08-30T08:05:00Z", decoded[0]["aimed_departure_time"])
+	require.NotContains(decoded[0], "expected_departure_time")
+	require.Equal("SUB1", decoded[1]["subscription_ref"])
+
+	buf.Reset()
+	err = Write(&buf, FORMAT_JSON, "records", VISIT_HEADER, make([]Record, 0))
+	require.Nil(err)
+	require.Equal("[]\n", buf.String())
+}
+
+func TestWriteXml(t *testing.T) {
+	require := require.New(t)
+	var buf bytes.Buffer
+	err := Write(&buf, FORMAT_XML, "records", VISIT_HEADER, newRecords())
+	require.Nil(err)
+
+	var decoded struct {
+		Visits        []VisitRecord        `xml:"visit"`
+		Subscriptions []SubscriptionRecord `xml:"subscription"`
+	}
+	require.Nil(xml.Unmarshal(buf.Bytes(), &decoded))
+	require.True(strings.HasPrefix(buf.String(), "<records>"))
+	require.Len(decoded.Visits, 1)
+	require.Equal("L1", decoded.Visits[0].LineRef)
+	require.Len(decoded.Subscriptions, 1)
+	require.True(decoded.Subscriptions[0].Status)
+}
+
+func TestWriteCsv(t *testing.T) {
+	require := require.New(t)
+	var buf bytes.Buffer
+	err := Write(&buf, FORMAT_CSV, "visits", VISIT_HEADER, newRecords()[:1])
+	require.Nil(err)
+
+	rows, err := csv.NewReader(&buf).ReadAll()
+	require.Nil(err)
+	require.Len(rows, 2)
+	require.Equal(VISIT_HEADER, rows[0])
+	require.Equal("Gare, Lille", rows[1][3])
+	require.Equal("", rows[1][5])
+}
+
+func TestWriteTable(t *testing.T) {
+	require := require.New(t)
+	var buf bytes.Buffer
+	err := Write(&buf, FORMAT_TABLE, "subscriptions", SUBSCRIPTION_HEADER, newRecords()[1:])
+	require.Nil(err)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(lines, 2)
+	require.Equal(SUBSCRIPTION_HEADER, strings.Fields(lines[0]))
+	require.Equal([]string{"SUB1", "-", "true", "-", "-"}, strings.Fields(lines[1]))
+}
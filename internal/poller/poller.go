@@ -0,0 +1,113 @@
+package poller
+
+import (
+	"context"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/julienbt/siri-sm/internal/checkstatus"
+	"github.com/julienbt/siri-sm/internal/config"
+	"github.com/julienbt/siri-sm/internal/getstopmonitoring"
+	"github.com/julienbt/siri-sm/internal/sirilite"
+	"github.com/julienbt/siri-sm/internal/supplierstatus"
+	"github.com/julienbt/siri-sm/internal/visitstore"
+)
+
+// Poller periodically refreshes the store with GetStopMonitoring requests
+// on every monitoring ref of every supplier, and the statuses with a
+// CheckStatus request of every supplier when `Statuses` is set, the
+// requests are sent in the timezone of each supplier
+type Poller struct {
+	Suppliers []config.ConfigSupplier
+	Store     *visitstore.Store
+	Statuses  *supplierstatus.Store
+	Interval  time.Duration
+	Logger    *logrus.Entry
+}
+
+// Run polls until the context is done, `onCycle` is called after each cycle
+func (p *Poller) Run(ctx context.Context, onCycle func()) {
+	ticker := time.NewTicker(p.Interval)
+	defer ticker.Stop()
+	for {
+		p.PollOnce()
+		if onCycle != nil {
+			onCycle()
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (p *Poller) PollOnce() {
+	for _, supplier := range p.Suppliers {
+		// SIRI Lite has no CheckStatus service
+		if p.Statuses != nil && supplier.Protocol != config.PROTOCOL_SIRI_LITE {
+			p.checkStatus(&supplier)
+		}
+		for _, monitoringRef := range supplier.MonitoringRefs {
+			p.pollStop(&supplier, monitoringRef)
+		}
+	}
+}
+
+func (p *Poller) checkStatus(supplier *config.ConfigSupplier) {
+	logger := p.Logger.WithField("supplier", supplier.Name)
+	requestTimestamp := time.Now()
+	checkStatusResult, _, _, err := checkstatus.CheckStatus(
+		supplier.CheckStatusConfig(),
+		logger,
+		&requestTimestamp,
+	)
+	if err != nil {
+		logger.Error(err)
+	}
+	p.Statuses.Update(supplier.Name, checkStatusResult, err, time.Now())
+}
+
+func (p *Poller) pollStop(supplier *config.ConfigSupplier, monitoringRef string) {
+	logger := p.Logger.WithFields(logrus.Fields{
+		"supplier":      supplier.Name,
+		"monitoringRef": monitoringRef,
+	})
+	stopRef, err := getstopmonitoring.ParseStopPointRef(monitoringRef)
+	if err != nil {
+		logger.Error(err)
+		return
+	}
+	monitoredStopVisits, err := p.getStopMonitoring(supplier, logger, monitoringRef)
+	if err != nil {
+		logger.Error(err)
+		return
+	}
+	p.Store.Update(supplier.Name, stopRef, monitoredStopVisits, time.Now())
+}
+
+func (p *Poller) getStopMonitoring(
+	supplier *config.ConfigSupplier,
+	logger *logrus.Entry,
+	monitoringRef string,
+) ([]getstopmonitoring.MonitoredStopVisit, error) {
+	if supplier.Protocol == config.PROTOCOL_SIRI_LITE {
+		monitoredStopVisits, _, _, err := sirilite.GetStopMonitoring(
+			supplier.SiriLiteConfig(),
+			logger,
+			monitoringRef,
+			supplier.LineRefs,
+		)
+		return monitoredStopVisits, err
+	}
+	requestTimestamp := time.Now()
+	monitoredStopVisits, _, _, err := getstopmonitoring.GetStopMonitoring(
+		supplier.CheckStatusConfig(),
+		logger,
+		&requestTimestamp,
+		monitoringRef,
+		supplier.LineRefs,
+	)
+	return monitoredStopVisits, err
+}
@@ -0,0 +1,92 @@
+package reconcile
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// subscriptionRecord is a subscription of the file, the JSON output of
+// `sirism subscribe` is read as well
+type subscriptionRecord struct {
+	SubscriptionRef string     `json:"subscription_ref"`
+	SubscriberRef   string     `json:"subscriber_ref"`
+	Status          bool       `json:"status"`
+	ValidUntil      *time.Time `json:"valid_until,omitempty"`
+}
+
+// ReadFile returns the active subscriptions of the file, none when it
+// doesn't exist yet
+func ReadFile(path string) ([]Subscription, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return []Subscription{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error opening the subscriptions: %s", err)
+	}
+	defer file.Close()
+	return Read(file)
+}
+
+// Read returns the subscriptions of a JSON array, the rejected ones are
+// ignored
+func Read(r io.Reader) ([]Subscription, error) {
+	records := make([]subscriptionRecord, 0)
+	err := json.NewDecoder(r).Decode(&records)
+	if err != nil {
+		return nil, fmt.Errorf("error reading the subscriptions: %s", err)
+	}
+	subscriptions := make([]Subscription, 0, len(records))
+	for _, record := range records {
+		if !record.Status {
+			continue
+		}
+		subscription := Subscription{
+			SubscriptionRef: record.SubscriptionRef,
+			SubscriberRef:   record.SubscriberRef,
+		}
+		if record.ValidUntil != nil {
+			subscription.ValidUntil = *record.ValidUntil
+		}
+		subscriptions = append(subscriptions, subscription)
+	}
+	return subscriptions, nil
+}
+
+func WriteFile(path string, subscriptions []Subscription) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("error creating the subscriptions: %s", err)
+	}
+	err = Write(file, subscriptions)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+func Write(w io.Writer, subscriptions []Subscription) error {
+	records := make([]subscriptionRecord, 0, len(subscriptions))
+	for _, subscription := range subscriptions {
+		record := subscriptionRecord{
+			SubscriptionRef: subscription.SubscriptionRef,
+			SubscriberRef:   subscription.SubscriberRef,
+			Status:          true,
+		}
+		if !subscription.ValidUntil.IsZero() {
+			validUntil := subscription.ValidUntil
+			record.ValidUntil = &validUntil
+		}
+		records = append(records, record)
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	err := encoder.Encode(records)
+	if err != nil {
+		return fmt.Errorf("error writing the subscriptions: %s", err)
+	}
+	return nil
+}
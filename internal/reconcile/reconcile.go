@@ -0,0 +1,272 @@
+// Package reconcile compares the stops to subscribe to with the active
+// subscriptions, and sends only the Subscribe and DeleteSubscription calls
+// needed to go from the ones to the others
+package reconcile
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/julienbt/siri-sm/internal/config"
+	"github.com/julienbt/siri-sm/internal/subscribe"
+	"github.com/julienbt/siri-sm/internal/unsubscribe"
+)
+
+const (
+	ACTION_KEEP      string = "keep"
+	ACTION_SUBSCRIBE string = "subscribe"
+	ACTION_DELETE    string = "delete"
+)
+
+// Subscription is an active subscription, e.g. from the statuses of a
+// Subscribe answer
+type Subscription struct {
+	SubscriptionRef string
+	SubscriberRef   string
+	ValidUntil      time.Time // zero when unknown
+}
+
+// Step is what the plan does with a subscription, the outcome fields are
+// set once applied
+type Step struct {
+	Action          string
+	SubscriptionRef string
+	StopPointId     string // empty for the deleted subscriptions
+	LineRef         string
+	ValidUntil      time.Time
+	Applied         bool // the supplier answered the request of the step
+	Status          bool
+	Error           string
+}
+
+type Plan struct {
+	Steps []Step
+}
+
+// NewPlan compares the subscriptions of the stops, one per stop or one per
+// stop and line with `LineRefs`, with the active subscriptions: the missing
+// ones, or the ones expiring within the `RenewalMargin`, are subscribed, the
+// active ones of another stop deleted and the others kept. The subscriptions
+// of another subscriber are ignored
+func NewPlan(cfg *config.ConfigSubscribe, stopPointIds []string, active []Subscription, now time.Time) (Plan, error) {
+	if cfg.Service != config.SERVICE_STOP_MONITORING {
+		return Plan{}, fmt.Errorf("only the StopMonitoring subscriptions are reconciled, not the %s ones", cfg.Service)
+	}
+	activeByRef := make(map[string]Subscription, len(active))
+	for _, subscription := range active {
+		if subscription.SubscriberRef != "" && subscription.SubscriberRef != cfg.SubscriberRef {
+			continue
+		}
+		if !subscription.ValidUntil.IsZero() && !subscription.ValidUntil.After(now) {
+			continue
+		}
+		activeByRef[subscription.SubscriptionRef] = subscription
+	}
+	lineRefs := []string{""}
+	if len(cfg.LineRefs) > 0 {
+		lineRefs = cfg.LineRefs
+	}
+
+	plan := Plan{Steps: make([]Step, 0, len(stopPointIds)*len(lineRefs)+len(activeByRef))}
+	desired := make(map[string]bool, len(stopPointIds)*len(lineRefs))
+	planned := make(map[string]bool, len(stopPointIds))
+	for _, stopPointId := range stopPointIds {
+		if planned[stopPointId] {
+			continue
+		}
+		planned[stopPointId] = true
+		steps := make([]Step, 0, len(lineRefs))
+		missing := false
+		for _, lineRef := range lineRefs {
+			subscriptionRef, err := subscribe.SubscriptionIdentifier(cfg.SubscriberRef, stopPointId, lineRef)
+			if err != nil {
+				return Plan{}, err
+			}
+			desired[subscriptionRef] = true
+			subscription, ok := activeByRef[subscriptionRef]
+			renewed := !subscription.ValidUntil.IsZero() && !subscription.ValidUntil.After(now.Add(cfg.RenewalMargin))
+			missing = missing || !ok || renewed
+			steps = append(steps, Step{
+				Action:          ACTION_KEEP,
+				SubscriptionRef: subscriptionRef,
+				StopPointId:     stopPointId,
+				LineRef:         lineRef,
+				ValidUntil:      subscription.ValidUntil,
+			})
+		}
+		// The lines of a stop are subscribed, or renewed, together
+		if missing {
+			for i := range steps {
+				steps[i].Action = ACTION_SUBSCRIBE
+				steps[i].ValidUntil = time.Time{}
+			}
+		}
+		plan.Steps = append(plan.Steps, steps...)
+	}
+
+	deleted := make([]Step, 0)
+	for subscriptionRef, subscription := range activeByRef {
+		if desired[subscriptionRef] {
+			continue
+		}
+		deleted = append(deleted, Step{
+			Action:          ACTION_DELETE,
+			SubscriptionRef: subscriptionRef,
+			ValidUntil:      subscription.ValidUntil,
+		})
+	}
+	sort.Slice(deleted, func(i, j int) bool { return deleted[i].SubscriptionRef < deleted[j].SubscriptionRef })
+	plan.Steps = append(plan.Steps, deleted...)
+	return plan, nil
+}
+
+// StopPointIds are the stops to subscribe to
+func (p *Plan) StopPointIds() []string {
+	stopPointIds := make([]string, 0)
+	for i, step := range p.Steps {
+		if step.Action != ACTION_SUBSCRIBE {
+			continue
+		}
+		if i > 0 && p.Steps[i-1].Action == ACTION_SUBSCRIBE && p.Steps[i-1].StopPointId == step.StopPointId {
+			continue
+		}
+		stopPointIds = append(stopPointIds, step.StopPointId)
+	}
+	return stopPointIds
+}
+
+// SubscriptionRefs are the subscriptions to delete
+func (p *Plan) SubscriptionRefs() []string {
+	subscriptionRefs := make([]string, 0)
+	for _, step := range p.Steps {
+		if step.Action == ACTION_DELETE {
+			subscriptionRefs = append(subscriptionRefs, step.SubscriptionRef)
+		}
+	}
+	return subscriptionRefs
+}
+
+// Count is the number of steps of an action
+func (p *Plan) Count(action string) int {
+	count := 0
+	for _, step := range p.Steps {
+		if step.Action == action {
+			count++
+		}
+	}
+	return count
+}
+
+// Failed is the number of steps refused by the supplier
+func (p *Plan) Failed() int {
+	failed := 0
+	for _, step := range p.Steps {
+		if step.Applied && !step.Status {
+			failed++
+		}
+	}
+	return failed
+}
+
+// Active are the subscriptions once the plan is applied: the kept and the
+// subscribed ones, and the ones whose deletion failed
+func (p *Plan) Active(subscriberRef string) []Subscription {
+	active := make([]Subscription, 0, len(p.Steps))
+	for _, step := range p.Steps {
+		switch step.Action {
+		case ACTION_SUBSCRIBE:
+			if !step.Applied || !step.Status {
+				continue
+			}
+		case ACTION_DELETE:
+			if step.Applied && step.Status {
+				continue
+			}
+		}
+		active = append(active, Subscription{
+			SubscriptionRef: step.SubscriptionRef,
+			SubscriberRef:   subscriberRef,
+			ValidUntil:      step.ValidUntil,
+		})
+	}
+	return active
+}
+
+// Apply sends the Subscribe call of the stops to subscribe to, then the
+// DeleteSubscription call of the subscriptions to delete, and returns the
+// plan with the outcome of its steps. The deletions are skipped when the
+// Subscribe call fails, the stops stay subscribed until the next run
+func Apply(
+	cfg config.ConfigSubscribe,
+	logger *logrus.Entry,
+	requestTimestamp *time.Time,
+	plan Plan,
+) (Plan, string, []byte, error) {
+	applied := Plan{Steps: append([]Step(nil), plan.Steps...)}
+	htmlReqBodies := make([]string, 0, 2)
+	htmlRespBodies := make([][]byte, 0, 2)
+	join := func() (string, []byte) {
+		return strings.Join(htmlReqBodies, "\n"), bytes.Join(htmlRespBodies, []byte("\n"))
+	}
+
+	stopPointIds := applied.StopPointIds()
+	if len(stopPointIds) > 0 {
+		logger.Infof("subscribing to %d stops", len(stopPointIds))
+		result, htmlReqBody, htmlRespBody, err := subscribe.SubscribeStops(cfg, logger, requestTimestamp, stopPointIds)
+		htmlReqBodies = append(htmlReqBodies, htmlReqBody)
+		htmlRespBodies = append(htmlRespBodies, htmlRespBody)
+		if err != nil {
+			htmlReqBody, htmlRespBody := join()
+			return applied, htmlReqBody, htmlRespBody, err
+		}
+		statuses := make(map[string]subscribe.ResponseStatus, len(result.ResponseStatus))
+		for _, status := range result.ResponseStatus {
+			statuses[status.SubscriptionRef] = status
+		}
+		for i := range applied.Steps {
+			step := &applied.Steps[i]
+			status, ok := statuses[step.SubscriptionRef]
+			if step.Action != ACTION_SUBSCRIBE || !ok {
+				continue
+			}
+			step.Applied = true
+			step.Status = status.Status
+			step.ValidUntil = time.Time(status.ValidUntil)
+			step.Error = status.ErrorText
+		}
+	}
+
+	subscriptionRefs := applied.SubscriptionRefs()
+	// Without refs, a DeleteSubscription terminates every subscription
+	if len(subscriptionRefs) > 0 {
+		logger.Infof("deleting %d subscriptions", len(subscriptionRefs))
+		statuses, htmlReqBody, htmlRespBody, err := unsubscribe.Unsubscribe(cfg.CheckStatusConfig(), logger, requestTimestamp, subscriptionRefs)
+		htmlReqBodies = append(htmlReqBodies, htmlReqBody)
+		htmlRespBodies = append(htmlRespBodies, htmlRespBody)
+		if err != nil {
+			htmlReqBody, htmlRespBody := join()
+			return applied, htmlReqBody, htmlRespBody, err
+		}
+		terminations := make(map[string]unsubscribe.TerminationResponseStatus, len(statuses))
+		for _, status := range statuses {
+			terminations[status.SubscriptionRef] = status
+		}
+		for i := range applied.Steps {
+			step := &applied.Steps[i]
+			status, ok := terminations[step.SubscriptionRef]
+			if step.Action != ACTION_DELETE || !ok {
+				continue
+			}
+			step.Applied = true
+			step.Status = status.Status
+			step.Error = status.Error()
+		}
+	}
+	htmlReqBody, htmlRespBody := join()
+	return applied, htmlReqBody, htmlRespBody, nil
+}
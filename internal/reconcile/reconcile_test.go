@@ -0,0 +1,140 @@
+package reconcile
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/julienbt/siri-sm/internal/config"
+)
+
+func newConfig() *config.ConfigSubscribe {
+	return &config.ConfigSubscribe{
+		SubscriberRef: "KISIO2",
+		ProducerRef:   "ILEVIA",
+		Service:       config.SERVICE_STOP_MONITORING,
+	}
+}
+
+func TestNewPlan(t *testing.T) {
+	require := require.New(t)
+	now := time.Date(2022, 8, 30, 8, 0, 0, 0, time.UTC)
+	active := []Subscription{
+		{SubscriptionRef: "KISIO2:Subscription:arret_CAS001:LOC", ValidUntil: now.Add(time.Hour)},
+		{SubscriptionRef: "KISIO2:Subscription:arret_CAS002:LOC", ValidUntil: now.Add(-time.Hour)},
+		{SubscriptionRef: "KISIO2:Subscription:arret_CAT001:LOC"},
+		{SubscriptionRef: "OTHER:Subscription:arret_CAU001:LOC", SubscriberRef: "OTHER"},
+	}
+
+	plan, err := NewPlan(newConfig(), []string{"CAS001", "CAS002", "CAS003", "CAS001"}, active, now)
+	require.Nil(err)
+	require.Len(plan.Steps, 4)
+	require.Equal(Step{
+		Action:          ACTION_KEEP,
+		SubscriptionRef: "KISIO2:Subscription:arret_CAS001:LOC",
+		StopPointId:     "CAS001",
+		ValidUntil:      now.Add(time.Hour),
+	}, plan.Steps[0])
+	require.Equal(ACTION_SUBSCRIBE, plan.Steps[1].Action)
+	require.Equal(ACTION_SUBSCRIBE, plan.Steps[2].Action)
+	require.Equal(Step{Action: ACTION_DELETE, SubscriptionRef: "KISIO2:Subscription:arret_CAT001:LOC"}, plan.Steps[3])
+	require.Equal([]string{"CAS002", "CAS003"}, plan.StopPointIds())
+	require.Equal([]string{"KISIO2:Subscription:arret_CAT001:LOC"}, plan.SubscriptionRefs())
+
+	cfg := newConfig()
+	cfg.Service = config.SERVICE_ESTIMATED_TIMETABLE
+	_, err = NewPlan(cfg, []string{"CAS001"}, active, now)
+	require.NotNil(err)
+}
+
+func TestNewPlanSubscribesTheLinesOfAStopTogether(t *testing.T) {
+	require := require.New(t)
+	now := time.Date(2022, 8, 30, 8, 0, 0, 0, time.UTC)
+	cfg := newConfig()
+	cfg.LineRefs = []string{"ILEVIA:Line:BP:L1:LOC", "ILEVIA:Line:BP:L2:LOC"}
+	active := []Subscription{
+		{SubscriptionRef: "KISIO2:Subscription:arret_CAS001_ligne_L1:LOC"},
+		{SubscriptionRef: "KISIO2:Subscription:arret_CAS001_ligne_L2:LOC"},
+		{SubscriptionRef: "KISIO2:Subscription:arret_CAS002_ligne_L1:LOC"},
+	}
+
+	plan, err := NewPlan(cfg, []string{"CAS001", "CAS002"}, active, now)
+	require.Nil(err)
+	require.Equal(2, plan.Count(ACTION_KEEP))
+	require.Equal(2, plan.Count(ACTION_SUBSCRIBE))
+	require.Equal(0, plan.Count(ACTION_DELETE))
+	require.Equal([]string{"CAS002"}, plan.StopPointIds())
+}
+
+func TestNewPlanRenewsTheExpiringSubscriptions(t *testing.T) {
+	require := require.New(t)
+	now := time.Date(2022, 8, 30, 8, 0, 0, 0, time.UTC)
+	cfg := newConfig()
+	cfg.RenewalMargin = time.Hour
+	active := []Subscription{
+		{SubscriptionRef: "KISIO2:Subscription:arret_CAS001:LOC", ValidUntil: now.Add(30 * time.Minute)},
+		{SubscriptionRef: "KISIO2:Subscription:arret_CAS002:LOC", ValidUntil: now.Add(2 * time.Hour)},
+		{SubscriptionRef: "KISIO2:Subscription:arret_CAS003:LOC"},
+		{SubscriptionRef: "KISIO2:Subscription:arret_CAT001:LOC", ValidUntil: now.Add(30 * time.Minute)},
+	}
+
+	plan, err := NewPlan(cfg, []string{"CAS001", "CAS002", "CAS003"}, active, now)
+	require.Nil(err)
+	require.Equal([]string{"CAS001"}, plan.StopPointIds())
+	require.Equal(2, plan.Count(ACTION_KEEP))
+	require.Equal([]string{"KISIO2:Subscription:arret_CAT001:LOC"}, plan.SubscriptionRefs())
+
+	// Without margin, kept until it expires
+	cfg.RenewalMargin = 0
+	plan, err = NewPlan(cfg, []string{"CAS001", "CAS002", "CAS003"}, active, now)
+	require.Nil(err)
+	require.Empty(plan.StopPointIds())
+}
+
+func TestActive(t *testing.T) {
+	require := require.New(t)
+	validUntil := time.Date(2022, 8, 31, 8, 0, 0, 0, time.UTC)
+	plan := Plan{Steps: []Step{
+		{Action: ACTION_KEEP, SubscriptionRef: "kept"},
+		{Action: ACTION_SUBSCRIBE, SubscriptionRef: "subscribed", Applied: true, Status: true, ValidUntil: validUntil},
+		{Action: ACTION_SUBSCRIBE, SubscriptionRef: "rejected", Applied: true},
+		{Action: ACTION_SUBSCRIBE, SubscriptionRef: "unanswered"},
+		{Action: ACTION_DELETE, SubscriptionRef: "deleted", Applied: true, Status: true},
+		{Action: ACTION_DELETE, SubscriptionRef: "not deleted", Applied: true},
+	}}
+
+	active := plan.Active("KISIO2")
+	require.Equal([]Subscription{
+		{SubscriptionRef: "kept", SubscriberRef: "KISIO2"},
+		{SubscriptionRef: "subscribed", SubscriberRef: "KISIO2", ValidUntil: validUntil},
+		{SubscriptionRef: "not deleted", SubscriberRef: "KISIO2"},
+	}, active)
+	require.Equal(2, plan.Failed())
+}
+
+func TestReadAndWrite(t *testing.T) {
+	require := require.New(t)
+	// The JSON output of `sirism subscribe`
+	subscriptions, err := Read(strings.NewReader(`[
+		{"subscription_ref": "KISIO2:Subscription:arret_CAS001:LOC", "subscriber_ref": "KISIO2", "status": true, "valid_until": "2022-08-31T08:00:00Z"},
+		{"subscription_ref": "KISIO2:Subscription:arret_CAS002:LOC", "subscriber_ref": "KISIO2", "status": false, "error": "Unknown stop"}
+	]`))
+	require.Nil(err)
+	require.Equal([]Subscription{{
+		SubscriptionRef: "KISIO2:Subscription:arret_CAS001:LOC",
+		SubscriberRef:   "KISIO2",
+		ValidUntil:      time.Date(2022, 8, 31, 8, 0, 0, 0, time.UTC),
+	}}, subscriptions)
+
+	builder := &strings.Builder{}
+	require.Nil(Write(builder, subscriptions))
+	read, err := Read(strings.NewReader(builder.String()))
+	require.Nil(err)
+	require.Equal(subscriptions, read)
+
+	subscriptions, err = ReadFile(t.TempDir() + "/missing.json")
+	require.Nil(err)
+	require.Empty(subscriptions)
+}
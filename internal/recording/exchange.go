@@ -0,0 +1,157 @@
+// Package recording archives the exchanges with the suppliers and serves
+// them back, to reproduce deterministically what was seen in production
+package recording
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/julienbt/siri-sm/internal/config"
+	"github.com/julienbt/siri-sm/internal/siri"
+)
+
+// Exchange is the metadata of a recorded request/response pair, the bodies
+// are stored next to it in `RequestFile` and `ResponseFile`
+type Exchange struct {
+	Sequence        int           `json:"sequence"`
+	Supplier        string        `json:"supplier"`
+	Operation       string        `json:"operation"` // e.g. `GetStopMonitoring`, or the last segment of the path in SIRI Lite
+	Binding         siri.Binding  `json:"binding,omitempty"`
+	Method          string        `json:"method"`
+	Url             string        `json:"url"`
+	RequestHeaders  http.Header   `json:"request_headers"`
+	RequestFile     string        `json:"request_file"`
+	StartedAt       time.Time     `json:"started_at"`
+	Duration        time.Duration `json:"duration"`
+	StatusCode      int           `json:"status_code,omitempty"`
+	ResponseHeaders http.Header   `json:"response_headers,omitempty"`
+	ResponseFile    string        `json:"response_file,omitempty"`
+	Error           string        `json:"error,omitempty"` // transport error, without response
+}
+
+const METADATA_FILE_SUFFIX string = ".json"
+
+// Headers not archived, they may hold credentials
+var SECRET_HEADERS = []string{"Authorization", "Apikey"}
+
+// Install sets the transport of `siri.SoapCall` according to the config,
+// `suppliers` names the suppliers by address in the recorded exchanges
+func Install(cfg config.ConfigRecording, suppliers map[string]string) error {
+	switch {
+	case cfg.RecordDir != "":
+		recorder, err := NewRecorder(cfg.RecordDir, http.DefaultTransport)
+		if err != nil {
+			return err
+		}
+		recorder.Suppliers = suppliers
+		siri.Transport = recorder
+	case cfg.ReplayDir != "":
+		replayer, err := NewReplayer(cfg.ReplayDir)
+		if err != nil {
+			return err
+		}
+		siri.Transport = replayer
+	}
+	return nil
+}
+
+// SupplierAddresses names the suppliers by address, for `Install`
+func SupplierAddresses(suppliers []config.ConfigSupplier) map[string]string {
+	names := make(map[string]string, len(suppliers))
+	for _, supplier := range suppliers {
+		names[supplier.SupplierAddress] = supplier.Name
+	}
+	return names
+}
+
+// operationOf returns the action of the request, see `siri.ActionOf`, and
+// its binding, empty for the requests without XML body (SIRI Lite)
+func operationOf(req *http.Request, body []byte) (string, siri.Binding) {
+	binding, _, _ := siri.DetectMessage(body)
+	return siri.ActionOf(req, body), binding
+}
+
+// Elements whose text changes at every request, ignored to match a request
+// with a recorded one
+var VOLATILE_ELEMENTS = map[string]bool{
+	"RequestTimestamp":       true,
+	"MessageIdentifier":      true,
+	"InitialTerminationTime": true,
+	"StartTime":              true,
+}
+
+// fingerprint is the request without its volatile parts
+func fingerprint(req *http.Request, body []byte) string {
+	b := &strings.Builder{}
+	b.WriteString(req.URL.RawQuery)
+	d := xml.NewDecoder(bytes.NewReader(body))
+	volatile := 0
+	for {
+		token, err := d.Token()
+		if err == io.EOF {
+			return b.String()
+		}
+		if err != nil {
+			// not XML
+			return req.URL.RawQuery + "|" + string(body)
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			if volatile > 0 || VOLATILE_ELEMENTS[t.Name.Local] {
+				volatile++
+				continue
+			}
+			b.WriteString("<" + t.Name.Local + ">")
+		case xml.EndElement:
+			if volatile > 0 {
+				volatile--
+			}
+		case xml.CharData:
+			if volatile == 0 {
+				b.WriteString(strings.TrimSpace(string(t)))
+			}
+		}
+	}
+}
+
+// LoadExchanges returns the exchanges recorded in the directory, in order
+func LoadExchanges(dir string) ([]Exchange, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*"+METADATA_FILE_SUFFIX))
+	if err != nil {
+		return nil, err
+	}
+	exchanges := make([]Exchange, 0, len(files))
+	for _, file := range files {
+		content, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("error reading the exchange %s: %s", file, err)
+		}
+		exchange := Exchange{}
+		err = json.Unmarshal(content, &exchange)
+		if err != nil {
+			return nil, fmt.Errorf("error decoding the exchange %s: %s", file, err)
+		}
+		exchanges = append(exchanges, exchange)
+	}
+	sort.Slice(exchanges, func(i, j int) bool { return exchanges[i].Sequence < exchanges[j].Sequence })
+	return exchanges, nil
+}
+
+func bodyFileExtension(contentType string) string {
+	switch {
+	case strings.Contains(contentType, "xml"):
+		return ".xml"
+	case strings.Contains(contentType, "json"):
+		return ".json.txt" // not to be taken for a metadata file
+	}
+	return ".txt"
+}
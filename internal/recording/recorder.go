@@ -0,0 +1,131 @@
+package recording
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/julienbt/siri-sm/internal/siri"
+)
+
+// Recorder is a transport archiving every exchange in `Dir`, as a metadata
+// file `<sequence>_<operation>.json` and the files of the bodies
+type Recorder struct {
+	Dir  string
+	Next http.RoundTripper
+	// Suppliers names the suppliers by address, the host of the request is
+	// recorded for the other addresses
+	Suppliers map[string]string
+	// Now is the clock of the recorder, `time.Now` by default
+	Now func() time.Time
+
+	mu       sync.Mutex
+	sequence int
+}
+
+// NewRecorder appends the exchanges to the ones already in the directory
+func NewRecorder(dir string, next http.RoundTripper) (*Recorder, error) {
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return nil, fmt.Errorf("error creating the record directory: %s", err)
+	}
+	exchanges, err := LoadExchanges(dir)
+	if err != nil {
+		return nil, err
+	}
+	sequence := 0
+	if len(exchanges) > 0 {
+		sequence = exchanges[len(exchanges)-1].Sequence
+	}
+	return &Recorder{Dir: dir, Next: next, Now: time.Now, sequence: sequence}, nil
+}
+
+func (r *Recorder) nextSequence() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sequence++
+	return r.sequence
+}
+
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	reqBody, err := siri.ReadRequestBody(req)
+	if err != nil {
+		return nil, err
+	}
+	operation, binding := operationOf(req, reqBody)
+	exchange := Exchange{
+		Sequence:       r.nextSequence(),
+		Supplier:       siri.SupplierOf(req, r.Suppliers),
+		Operation:      operation,
+		Binding:        binding,
+		Method:         req.Method,
+		Url:            req.URL.String(),
+		RequestHeaders: withoutSecrets(req.Header),
+		StartedAt:      r.Now(),
+	}
+	baseName := fmt.Sprintf("%06d_%s", exchange.Sequence, operation)
+	exchange.RequestFile = baseName + ".request" + bodyFileExtension(req.Header.Get("Content-Type"))
+	err = r.writeFile(exchange.RequestFile, reqBody)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, roundTripErr := r.Next.RoundTrip(req)
+	exchange.Duration = r.Now().Sub(exchange.StartedAt)
+	if roundTripErr != nil {
+		exchange.Error = roundTripErr.Error()
+		if err := r.writeExchange(baseName, &exchange); err != nil {
+			return nil, err
+		}
+		return nil, roundTripErr
+	}
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(respBody))
+	exchange.StatusCode = resp.StatusCode
+	exchange.ResponseHeaders = withoutSecrets(resp.Header)
+	exchange.ResponseFile = baseName + ".response" + bodyFileExtension(resp.Header.Get("Content-Type"))
+	err = r.writeFile(exchange.ResponseFile, respBody)
+	if err != nil {
+		return nil, err
+	}
+	err = r.writeExchange(baseName, &exchange)
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (r *Recorder) writeFile(name string, content []byte) error {
+	err := ioutil.WriteFile(filepath.Join(r.Dir, name), content, 0644)
+	if err != nil {
+		return fmt.Errorf("error recording the exchange: %s", err)
+	}
+	return nil
+}
+
+func (r *Recorder) writeExchange(baseName string, exchange *Exchange) error {
+	content, err := json.MarshalIndent(exchange, "", "  ")
+	if err != nil {
+		return err
+	}
+	return r.writeFile(baseName+METADATA_FILE_SUFFIX, content)
+}
+
+func withoutSecrets(header http.Header) http.Header {
+	header = header.Clone()
+	for _, name := range SECRET_HEADERS {
+		header.Del(name)
+	}
+	return header
+}
@@ -0,0 +1,139 @@
+package recording
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/julienbt/siri-sm/internal/checkstatus"
+	"github.com/julienbt/siri-sm/internal/config"
+	"github.com/julienbt/siri-sm/internal/fakeproducer"
+	"github.com/julienbt/siri-sm/internal/getstopmonitoring"
+	"github.com/julienbt/siri-sm/internal/siri"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMain(m *testing.M) {
+
+	testDataDir := os.Getenv("SIRISM_TEST_DATA_DIR")
+	if testDataDir == "" {
+		panic("$SIRISM_TEST_DATA_DIR isn't set")
+	}
+	// The requests are built from the templates of `./template`
+	err := os.Chdir(filepath.Join(testDataDir, ".."))
+	if err != nil {
+		panic(err)
+	}
+
+	os.Exit(m.Run())
+}
+
+func newLogger() *logrus.Entry {
+	logger := logrus.New()
+	logger.SetOutput(ioutil.Discard)
+	return logrus.NewEntry(logger)
+}
+
+func newVisit(monitoringRef string, aimed time.Time) fakeproducer.Visit {
+	return fakeproducer.Visit{
+		ItemIdentifier:         "ILEVIA:Item::" + monitoringRef,
+		MonitoringRef:          monitoringRef,
+		LineRef:                "ILEVIA:Line:BP:L1:LOC",
+		DirectionName:          "ALLER",
+		DataFrameRef:           "2022-08-30",
+		DatedVehicleJourneyRef: "ILEVIA:VehicleJourney::1:LOC",
+		DestinationName:        "Gare",
+		AimedDepartureTime:     aimed,
+	}
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	require := require.New(t)
+	defer func() { siri.Transport = nil }()
+	dir := t.TempDir()
+	now := time.Date(2022, 8, 30, 8, 0, 0, 0, time.UTC)
+
+	producer := fakeproducer.New("ILEVIA")
+	producer.Visits = []fakeproducer.Visit{
+		newVisit("ILEVIA:StopPoint:BP:CAS001:LOC", now.Add(5*time.Minute)),
+		newVisit("ILEVIA:StopPoint:BP:CAS002:LOC", now.Add(10*time.Minute)),
+		newVisit("ILEVIA:StopPoint:BP:CAS002:LOC", now.Add(20*time.Minute)),
+	}
+	producer.Enqueue(fakeproducer.OPERATION_CHECK_STATUS, fakeproducer.Response{StatusCode: http.StatusServiceUnavailable})
+	server := httptest.NewServer(producer)
+	cfg := config.ConfigCheckStatus{
+		SupplierAddress: server.URL,
+		SubscriberRef:   "TEST",
+		Binding:         string(siri.BINDING_SOAP_11),
+	}
+
+	err := Install(config.ConfigRecording{RecordDir: dir}, map[string]string{server.URL: "lille"})
+	require.Nil(err)
+	_, _, _, err = checkstatus.CheckStatus(cfg, newLogger(), &now)
+	require.IsType(&siri.RemoteError{}, err)
+	recordedVisits1, _, _, err := getstopmonitoring.GetStopMonitoring(cfg, newLogger(), &now, "ILEVIA:StopPoint:BP:CAS001:LOC", nil)
+	require.Nil(err)
+	recordedVisits2, _, _, err := getstopmonitoring.GetStopMonitoring(cfg, newLogger(), &now, "ILEVIA:StopPoint:BP:CAS002:LOC", nil)
+	require.Nil(err)
+	server.Close()
+
+	exchanges, err := LoadExchanges(dir)
+	require.Nil(err)
+	require.Len(exchanges, 3)
+	require.Equal("lille", exchanges[0].Supplier)
+	require.Equal("CheckStatus", exchanges[0].Operation)
+	require.Equal(http.StatusServiceUnavailable, exchanges[0].StatusCode)
+	require.Equal("GetStopMonitoring", exchanges[1].Operation)
+	require.Equal(siri.BINDING_SOAP_11, exchanges[1].Binding)
+	require.FileExists(filepath.Join(dir, exchanges[1].ResponseFile))
+
+	err = Install(config.ConfigRecording{ReplayDir: dir}, nil)
+	require.Nil(err)
+	// Requested in another order, and later
+	later := now.Add(time.Hour)
+	visits2, _, _, err := getstopmonitoring.GetStopMonitoring(cfg, newLogger(), &later, "ILEVIA:StopPoint:BP:CAS002:LOC", nil)
+	require.Nil(err)
+	require.Equal(recordedVisits2, visits2)
+	visits1, _, _, err := getstopmonitoring.GetStopMonitoring(cfg, newLogger(), &later, "ILEVIA:StopPoint:BP:CAS001:LOC", nil)
+	require.Nil(err)
+	require.Equal(recordedVisits1, visits1)
+	_, _, _, err = checkstatus.CheckStatus(cfg, newLogger(), &later)
+	require.IsType(&siri.RemoteError{}, err)
+
+	require.Equal(0, siri.Transport.(*Replayer).Remaining())
+	_, _, _, err = checkstatus.CheckStatus(cfg, newLogger(), &later)
+	require.NotNil(err)
+}
+
+func TestRecorderAppends(t *testing.T) {
+	require := require.New(t)
+	dir := t.TempDir()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte("{}"))
+	}))
+	defer server.Close()
+
+	for i := 0; i < 2; i++ {
+		recorder, err := NewRecorder(dir, http.DefaultTransport)
+		require.Nil(err)
+		req, err := http.NewRequest(http.MethodGet, server.URL+"/siri/2.0/stop-monitoring.json?MonitoringRef=CAS001", nil)
+		require.Nil(err)
+		req.Header.Set("apikey", "secret")
+		resp, err := recorder.RoundTrip(req)
+		require.Nil(err)
+		resp.Body.Close()
+	}
+
+	exchanges, err := LoadExchanges(dir)
+	require.Nil(err)
+	require.Len(exchanges, 2)
+	require.Equal(2, exchanges[1].Sequence)
+	require.Equal("stop-monitoring.json", exchanges[1].Operation)
+	require.Empty(exchanges[1].RequestHeaders.Get("apikey"))
+}
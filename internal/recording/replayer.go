@@ -0,0 +1,121 @@
+package recording
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"sync"
+
+	"github.com/julienbt/siri-sm/internal/siri"
+)
+
+// Replayer is a transport serving the recorded responses, a request is
+// answered by the first exchange not yet served of the same operation,
+// preferably with the same request apart from its timestamps and identifiers
+type Replayer struct {
+	mu        sync.Mutex
+	dir       string
+	exchanges []replayedExchange
+}
+
+type replayedExchange struct {
+	Exchange
+	fingerprint string
+	served      bool
+}
+
+func NewReplayer(dir string) (*Replayer, error) {
+	exchanges, err := LoadExchanges(dir)
+	if err != nil {
+		return nil, err
+	}
+	if len(exchanges) == 0 {
+		return nil, fmt.Errorf("no exchange recorded in %s", dir)
+	}
+	replayer := &Replayer{dir: dir}
+	for _, exchange := range exchanges {
+		request, err := http.NewRequest(exchange.Method, exchange.Url, nil)
+		if err != nil {
+			return nil, fmt.Errorf("error in the exchange %d: %s", exchange.Sequence, err)
+		}
+		body, err := ioutil.ReadFile(filepath.Join(dir, exchange.RequestFile))
+		if err != nil {
+			return nil, fmt.Errorf("error in the exchange %d: %s", exchange.Sequence, err)
+		}
+		replayer.exchanges = append(replayer.exchanges, replayedExchange{
+			Exchange:    exchange,
+			fingerprint: fingerprint(request, body),
+		})
+	}
+	return replayer, nil
+}
+
+// Remaining returns the number of exchanges not served yet
+func (r *Replayer) Remaining() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	remaining := 0
+	for i := range r.exchanges {
+		if !r.exchanges[i].served {
+			remaining++
+		}
+	}
+	return remaining
+}
+
+func (r *Replayer) next(operation string, fingerprint string) (Exchange, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var candidate *replayedExchange
+	for i := range r.exchanges {
+		e := &r.exchanges[i]
+		if e.served || e.Operation != operation {
+			continue
+		}
+		if e.fingerprint == fingerprint {
+			candidate = e
+			break
+		}
+		if candidate == nil {
+			candidate = e
+		}
+	}
+	if candidate == nil {
+		return Exchange{}, false
+	}
+	candidate.served = true
+	return candidate.Exchange, true
+}
+
+func (r *Replayer) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := siri.ReadRequestBody(req)
+	if err != nil {
+		return nil, err
+	}
+	operation, _ := operationOf(req, body)
+	exchange, ok := r.next(operation, fingerprint(req, body))
+	if !ok {
+		return nil, fmt.Errorf("no recorded exchange left for %s", operation)
+	}
+	if exchange.Error != "" {
+		return nil, errors.New(exchange.Error)
+	}
+	respBody, err := ioutil.ReadFile(filepath.Join(r.dir, exchange.ResponseFile))
+	if err != nil {
+		return nil, fmt.Errorf("error in the exchange %d: %s", exchange.Sequence, err)
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", exchange.StatusCode, http.StatusText(exchange.StatusCode)),
+		StatusCode:    exchange.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        exchange.ResponseHeaders,
+		Body:          ioutil.NopCloser(bytes.NewReader(respBody)),
+		ContentLength: int64(len(respBody)),
+		Request:       req,
+	}, nil
+}
@@ -0,0 +1,299 @@
+package siri
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	siri_time "github.com/julienbt/siri-sm/internal/common/time"
+)
+
+// Binding is the way SIRI messages are carried over HTTP POST
+type Binding string
+
+const (
+	BINDING_SOAP_11 Binding = "soap11"
+	BINDING_SOAP_12 Binding = "soap12"
+	BINDING_RAW     Binding = "raw" // SIRI 2.0 XML with a `<Siri>` root element
+)
+
+const SOAP_11_ENVELOPE_NAMESPACE string = "http://schemas.xmlsoap.org/soap/envelope/"
+const SOAP_12_ENVELOPE_NAMESPACE string = "http://www.w3.org/2003/05/soap-envelope"
+
+// Names of the templates to define in every request template file
+const (
+	PAYLOAD_TEMPLATE_SOAP string = "soap" // operation element of the SIRI WSDL
+	PAYLOAD_TEMPLATE_RAW  string = "raw"  // `<Siri>` document
+)
+
+func ParseBinding(s string) (Binding, error) {
+	switch Binding(s) {
+	case "":
+		return BINDING_SOAP_11, nil
+	case BINDING_SOAP_11, BINDING_SOAP_12, BINDING_RAW:
+		return Binding(s), nil
+	}
+	return "", fmt.Errorf("unknown binding: %s", s)
+}
+
+func (b Binding) IsSoap() bool {
+	return b == BINDING_SOAP_11 || b == BINDING_SOAP_12
+}
+
+func (b Binding) PayloadTemplateName() string {
+	if b.IsSoap() {
+		return PAYLOAD_TEMPLATE_SOAP
+	}
+	return PAYLOAD_TEMPLATE_RAW
+}
+
+// NewHttpRequest wraps the payload in the envelope of the binding
+func (b Binding) NewHttpRequest(address string, soapAction string, payload string) (*http.Request, string, error) {
+	httpReqBody, err := b.WrapPayload(payload)
+	if err != nil {
+		return nil, "", err
+	}
+	var headers http.Header
+	switch b {
+	case BINDING_SOAP_11:
+		headers = http.Header{
+			"Content-Type": []string{b.ContentType()},
+			"SOAPAction":   []string{soapAction},
+		}
+	case BINDING_SOAP_12:
+		headers = http.Header{
+			"Content-Type": []string{fmt.Sprintf("%s; action=\"%s\"", b.ContentType(), soapAction)},
+		}
+	default:
+		headers = http.Header{
+			"Content-Type": []string{b.ContentType()},
+		}
+	}
+	httpReq, err := http.NewRequest(http.MethodPost, address, strings.NewReader(httpReqBody))
+	if err != nil {
+		return nil, "", fmt.Errorf("error building http-request: %s", err)
+	}
+	httpReq.Header = headers // better than .Header.Set to preserve case (for "SOAPAction")
+	return httpReq, httpReqBody, nil
+}
+
+// WrapPayload returns the payload in the SOAP envelope of the binding, or as
+// an XML document for the raw binding
+func (b Binding) WrapPayload(payload string) (string, error) {
+	switch b {
+	case BINDING_SOAP_11:
+		return wrapInSoapEnvelope(SOAP_11_ENVELOPE_NAMESPACE, payload), nil
+	case BINDING_SOAP_12:
+		return wrapInSoapEnvelope(SOAP_12_ENVELOPE_NAMESPACE, payload), nil
+	case BINDING_RAW:
+		return xml.Header + strings.TrimSpace(payload), nil
+	}
+	return "", fmt.Errorf("unknown binding: %s", b)
+}
+
+func (b Binding) ContentType() string {
+	switch b {
+	case BINDING_SOAP_12:
+		return "application/soap+xml; charset=utf-8"
+	case BINDING_RAW:
+		return "application/xml; charset=utf-8"
+	}
+	return "text/xml; charset=utf-8"
+}
+
+func wrapInSoapEnvelope(namespace string, payload string) string {
+	return fmt.Sprintf(
+		"<soapenv:Envelope xmlns:soapenv=\"%s\">\n<soapenv:Header/>\n<soapenv:Body>\n%s\n</soapenv:Body>\n</soapenv:Envelope>",
+		namespace,
+		strings.TrimSpace(payload),
+	)
+}
+
+// DetectMessage returns the binding of a message and the local name of its
+// first element below the SOAP `Body`, or below the `Siri` root element
+func DetectMessage(body []byte) (Binding, string, error) {
+	d := xml.NewDecoder(bytes.NewReader(body))
+	var binding Binding
+	path := make([]string, 0)
+	for {
+		token, err := d.Token()
+		if err == io.EOF {
+			return "", "", fmt.Errorf("neither SOAP `Body` nor `Siri` root element")
+		}
+		if err != nil {
+			return "", "", err
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			path = append(path, t.Name.Local)
+			switch {
+			case len(path) == 1 && t.Name.Local == "Envelope":
+				switch t.Name.Space {
+				case SOAP_11_ENVELOPE_NAMESPACE:
+					binding = BINDING_SOAP_11
+				case SOAP_12_ENVELOPE_NAMESPACE:
+					binding = BINDING_SOAP_12
+				default:
+					return "", "", fmt.Errorf("unknown SOAP envelope namespace: %s", t.Name.Space)
+				}
+			case len(path) == 1 && t.Name.Local == "Siri":
+				binding = BINDING_RAW
+			case len(path) == 1:
+				return "", "", fmt.Errorf("unknown root element: %s", t.Name.Local)
+			case binding == BINDING_RAW && len(path) == 2:
+				return binding, t.Name.Local, nil
+			case binding.IsSoap() && len(path) == 3 && path[1] == "Body":
+				return binding, t.Name.Local, nil
+			}
+		case xml.EndElement:
+			path = path[:len(path)-1]
+		}
+	}
+}
+
+// AnswerPath locates the answer of a response: below the SOAP `Body` element
+// or below the `Siri` root element
+type AnswerPath struct {
+	Soap []string
+	Raw  []string
+}
+
+type SoapFault struct {
+	FaultCode   string `xml:"faultcode"`
+	FaultString string `xml:"faultstring"`
+	CodeValue   string `xml:"Code>Value"`  // SOAP 1.2
+	ReasonText  string `xml:"Reason>Text"` // SOAP 1.2
+}
+
+func (f *SoapFault) Error() string {
+	if f.FaultString != "" || f.FaultCode != "" {
+		return fmt.Sprintf("soap fault %s: %s", f.FaultCode, f.FaultString)
+	}
+	return fmt.Sprintf("soap fault %s: %s", f.CodeValue, f.ReasonText)
+}
+
+// DecodeAnswer decodes the answer element of the response body into v,
+// a SOAP fault is returned as a `*SoapFault` error
+func (b Binding) DecodeAnswer(body []byte, path AnswerPath, v interface{}) error {
+	if b.IsSoap() {
+		fault := &SoapFault{}
+		found, err := DecodeElementAt(body, []string{"Envelope", "Body", "Fault"}, fault)
+		if err != nil {
+			return err
+		}
+		if found {
+			return fault
+		}
+		return decodeRequiredElementAt(body, append([]string{"Envelope", "Body"}, path.Soap...), v)
+	}
+	return decodeRequiredElementAt(body, append([]string{"Siri"}, path.Raw...), v)
+}
+
+// DecodeAnswerIn is `DecodeAnswer` with the times of the answer set in the
+// timezone of the supplier, see `siri_time.Normalize`
+func (b Binding) DecodeAnswerIn(body []byte, path AnswerPath, v interface{}, location *time.Location) error {
+	err := b.DecodeAnswer(body, path, v)
+	if err != nil {
+		return err
+	}
+	siri_time.Normalize(v, location)
+	return nil
+}
+
+func decodeRequiredElementAt(body []byte, path []string, v interface{}) error {
+	found, err := DecodeElementAt(body, path, v)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return fmt.Errorf("element not found: %s", strings.Join(path, ">"))
+	}
+	return nil
+}
+
+// DecodeError locates a decoding error by the local names of the elements,
+// e.g. `Envelope>Body>CheckStatusResponse>Answer>Status`
+type DecodeError struct {
+	Path string
+	Err  error
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Path, e.Err)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// DecodeElementAt decodes into v the first element whose ancestors match the
+// local names of the path (namespaces are ignored), an error is returned as a
+// `*DecodeError`
+func DecodeElementAt(body []byte, path []string, v interface{}) (bool, error) {
+	d := xml.NewDecoder(bytes.NewReader(body))
+	matchedDepth := 0
+	ancestors := make([]string, 0)
+	for {
+		token, err := d.Token()
+		if err == io.EOF {
+			return false, nil
+		}
+		if err != nil {
+			return false, &DecodeError{Path: strings.Join(ancestors, ">"), Err: err}
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			if len(ancestors) == matchedDepth && t.Name.Local == path[matchedDepth] {
+				matchedDepth++
+				if matchedDepth == len(path) {
+					start := d.InputOffset()
+					err := d.DecodeElement(v, &t)
+					if err != nil {
+						return true, &DecodeError{
+							Path: strings.Join(append(append(ancestors, t.Name.Local), elementPathAt(body, start, d.InputOffset(), err)...), ">"),
+							Err:  err,
+						}
+					}
+					return true, nil
+				}
+			}
+			ancestors = append(ancestors, t.Name.Local)
+		case xml.EndElement:
+			ancestors = ancestors[:len(ancestors)-1]
+			if len(ancestors) < matchedDepth {
+				matchedDepth = len(ancestors)
+			}
+		}
+	}
+}
+
+// elementPathAt returns the path of the element, below the one starting at
+// the offset `start`, which failed when the decoder stopped at `end`: the
+// innermost element still open on a syntax error, else the last one closed
+func elementPathAt(body []byte, start int64, end int64, decodeErr error) []string {
+	d := xml.NewDecoder(bytes.NewReader(body[start:end]))
+	path := make([]string, 0)
+	lastClosed := make([]string, 0)
+	for {
+		token, err := d.Token()
+		if err != nil {
+			break
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			path = append(path, t.Name.Local)
+			lastClosed = lastClosed[:0]
+		case xml.EndElement:
+			lastClosed = append(lastClosed[:0], path...)
+			path = path[:len(path)-1]
+		}
+	}
+	if _, ok := decodeErr.(*xml.SyntaxError); ok || len(lastClosed) == 0 {
+		return path
+	}
+	return lastClosed
+}
@@ -0,0 +1,158 @@
+package siri
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type testAnswer struct {
+	Status bool   `xml:"Status"`
+	Text   string `xml:"Text"`
+}
+
+var TEST_ANSWER_PATH = AnswerPath{
+	Soap: []string{"CheckStatusResponse", "Answer"},
+	Raw:  []string{"CheckStatusResponse"},
+}
+
+func TestNewHttpRequest(t *testing.T) {
+	require := require.New(t)
+
+	httpReq, httpReqBody, err := BINDING_SOAP_11.NewHttpRequest("http://example.com", "CheckStatus", "<CheckStatus/>")
+	require.Nil(err)
+	require.Equal([]string{"CheckStatus"}, httpReq.Header["SOAPAction"])
+	require.Equal("text/xml; charset=utf-8", httpReq.Header.Get("Content-Type"))
+	require.Contains(httpReqBody, SOAP_11_ENVELOPE_NAMESPACE)
+	require.Contains(httpReqBody, "<soapenv:Body>\n<CheckStatus/>\n</soapenv:Body>")
+	body, err := ioutil.ReadAll(httpReq.Body)
+	require.Nil(err)
+	require.Equal(httpReqBody, string(body))
+
+	httpReq, httpReqBody, err = BINDING_SOAP_12.NewHttpRequest("http://example.com", "CheckStatus", "<CheckStatus/>")
+	require.Nil(err)
+	require.Empty(httpReq.Header["SOAPAction"])
+	require.Equal("application/soap+xml; charset=utf-8; action=\"CheckStatus\"", httpReq.Header.Get("Content-Type"))
+	require.Contains(httpReqBody, SOAP_12_ENVELOPE_NAMESPACE)
+
+	httpReq, httpReqBody, err = BINDING_RAW.NewHttpRequest("http://example.com", "CheckStatus", "<Siri/>")
+	require.Nil(err)
+	require.Equal("application/xml; charset=utf-8", httpReq.Header.Get("Content-Type"))
+	require.Contains(httpReqBody, "<Siri/>")
+	require.NotContains(httpReqBody, "Envelope")
+}
+
+func TestDecodeAnswer(t *testing.T) {
+	require := require.New(t)
+
+	answer := testAnswer{}
+	err := BINDING_SOAP_11.DecodeAnswer([]byte(
+		`<soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/"><soap:Body>
+			<ns1:CheckStatusResponse xmlns:ns1="http://wsdl.siri.org.uk">
+				<CheckStatusAnswerInfo><Status>false</Status></CheckStatusAnswerInfo>
+				<Answer><Status>true</Status><Text>soap</Text></Answer>
+			</ns1:CheckStatusResponse>
+		</soap:Body></soap:Envelope>`,
+	), TEST_ANSWER_PATH, &answer)
+	require.Nil(err)
+	require.Equal(testAnswer{Status: true, Text: "soap"}, answer)
+
+	answer = testAnswer{}
+	err = BINDING_SOAP_12.DecodeAnswer([]byte(
+		`<env:Envelope xmlns:env="http://www.w3.org/2003/05/soap-envelope"><env:Body>
+			<CheckStatusResponse><Answer><Status>true</Status><Text>soap12</Text></Answer></CheckStatusResponse>
+		</env:Body></env:Envelope>`,
+	), TEST_ANSWER_PATH, &answer)
+	require.Nil(err)
+	require.Equal(testAnswer{Status: true, Text: "soap12"}, answer)
+
+	answer = testAnswer{}
+	err = BINDING_RAW.DecodeAnswer([]byte(
+		`<?xml version="1.0" encoding="UTF-8"?>
+		<Siri xmlns="http://www.siri.org.uk/siri" version="2.0">
+			<CheckStatusResponse><Status>true</Status><Text>raw</Text></CheckStatusResponse>
+		</Siri>`,
+	), TEST_ANSWER_PATH, &answer)
+	require.Nil(err)
+	require.Equal(testAnswer{Status: true, Text: "raw"}, answer)
+
+	// The answer of another binding is not found
+	err = BINDING_RAW.DecodeAnswer([]byte(
+		`<Envelope><Body><CheckStatusResponse><Answer/></CheckStatusResponse></Body></Envelope>`,
+	), TEST_ANSWER_PATH, &answer)
+	require.NotNil(err)
+}
+
+func TestDecodeAnswerErrorPath(t *testing.T) {
+	require := require.New(t)
+
+	err := BINDING_SOAP_11.DecodeAnswer([]byte(
+		`<soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/"><soap:Body>
+			<CheckStatusResponse><Answer><Text>soap</Text><Status>maybe</Status></Answer></CheckStatusResponse>
+		</soap:Body></soap:Envelope>`,
+	), TEST_ANSWER_PATH, &testAnswer{})
+	require.NotNil(err)
+	decodeErr, ok := err.(*DecodeError)
+	require.True(ok)
+	require.Equal("Envelope>Body>CheckStatusResponse>Answer>Status", decodeErr.Path)
+
+	err = BINDING_RAW.DecodeAnswer([]byte(
+		`<Siri><CheckStatusResponse><Status>true</Status><Text>raw</Text></Siri>`,
+	), TEST_ANSWER_PATH, &testAnswer{})
+	require.NotNil(err)
+	decodeErr, ok = err.(*DecodeError)
+	require.True(ok)
+	require.Equal("Siri>CheckStatusResponse", decodeErr.Path)
+}
+
+func TestDecodeAnswerSoapFault(t *testing.T) {
+	require := require.New(t)
+
+	err := BINDING_SOAP_11.DecodeAnswer([]byte(
+		`<soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/"><soap:Body>
+			<soap:Fault><faultcode>soap:Client</faultcode><faultstring>unknown requestor</faultstring></soap:Fault>
+		</soap:Body></soap:Envelope>`,
+	), TEST_ANSWER_PATH, &testAnswer{})
+	require.NotNil(err)
+	fault, ok := err.(*SoapFault)
+	require.True(ok)
+	require.Equal("unknown requestor", fault.FaultString)
+
+	err = BINDING_SOAP_12.DecodeAnswer([]byte(
+		`<env:Envelope xmlns:env="http://www.w3.org/2003/05/soap-envelope"><env:Body>
+			<env:Fault><env:Code><env:Value>env:Sender</env:Value></env:Code>
+			<env:Reason><env:Text xml:lang="en">unknown requestor</env:Text></env:Reason></env:Fault>
+		</env:Body></env:Envelope>`,
+	), TEST_ANSWER_PATH, &testAnswer{})
+	require.NotNil(err)
+	require.Equal("soap fault env:Sender: unknown requestor", err.Error())
+}
+
+func TestDetectMessage(t *testing.T) {
+	require := require.New(t)
+
+	binding, name, err := DetectMessage([]byte(
+		`<soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/"><soap:Header/><soap:Body>
+			<ns1:GetStopMonitoring xmlns:ns1="http://wsdl.siri.org.uk"/>
+		</soap:Body></soap:Envelope>`,
+	))
+	require.Nil(err)
+	require.Equal(BINDING_SOAP_11, binding)
+	require.Equal("GetStopMonitoring", name)
+
+	binding, name, err = DetectMessage([]byte(
+		`<env:Envelope xmlns:env="http://www.w3.org/2003/05/soap-envelope"><env:Body><CheckStatus/></env:Body></env:Envelope>`,
+	))
+	require.Nil(err)
+	require.Equal(BINDING_SOAP_12, binding)
+	require.Equal("CheckStatus", name)
+
+	binding, name, err = DetectMessage([]byte(`<?xml version="1.0"?><Siri version="2.0"><SubscriptionRequest/></Siri>`))
+	require.Nil(err)
+	require.Equal(BINDING_RAW, binding)
+	require.Equal("SubscriptionRequest", name)
+
+	_, _, err = DetectMessage([]byte(`<html/>`))
+	require.NotNil(err)
+}
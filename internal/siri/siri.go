@@ -14,8 +14,12 @@ func (e *RemoteError) Error() string {
 	return fmt.Sprintf("%s: %s", e.Loc, e.Err)
 }
 
+// Transport of the requests sent by `SoapCall`, `http.DefaultTransport` when
+// nil (see the `recording` package to record or replay the exchanges)
+var Transport http.RoundTripper
+
 func SoapCall(req *http.Request) (*http.Response, error) {
-	client := &http.Client{}
+	client := &http.Client{Transport: Transport}
 	resp, err := client.Do(req)
 	if err != nil {
 		return nil, err
@@ -0,0 +1,50 @@
+package siri
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// The helpers of the transports wrapping `Transport` (recording, audit and
+// metrics), which label the same request the same way
+
+// SupplierOf returns the name of the supplier whose address prefixes the
+// request, else the host of the request
+func SupplierOf(req *http.Request, suppliers map[string]string) string {
+	address := req.URL.String()
+	for supplierAddress, name := range suppliers {
+		if strings.HasPrefix(address, supplierAddress) {
+			return name
+		}
+	}
+	return req.URL.Host
+}
+
+// ActionOf returns the local name of the SIRI message of the request body,
+// e.g. `GetStopMonitoring` in SOAP or `CheckStatusRequest` in raw SIRI XML,
+// else the last segment of the path for the requests without XML body
+// (SIRI Lite)
+func ActionOf(req *http.Request, body []byte) string {
+	if _, name, err := DetectMessage(body); err == nil {
+		return name
+	}
+	return path.Base(req.URL.Path)
+}
+
+// ReadRequestBody reads the body of the request, which is replaced for the
+// next transport
+func ReadRequestBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil {
+		return nil, nil
+	}
+	body, err := ioutil.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	return body, nil
+}
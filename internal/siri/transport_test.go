@@ -0,0 +1,43 @@
+package siri
+
+import (
+	"io/ioutil"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestActionOf(t *testing.T) {
+	require := require.New(t)
+
+	req, _, err := BINDING_SOAP_11.NewHttpRequest("http://lille/siri", "GetStopMonitoring", "<GetStopMonitoring/>")
+	require.Nil(err)
+	body, err := ReadRequestBody(req)
+	require.Nil(err)
+	require.Equal("GetStopMonitoring", ActionOf(req, body))
+	// The body is still readable by the next transport
+	next, err := ioutil.ReadAll(req.Body)
+	require.Nil(err)
+	require.Equal(body, next)
+
+	req, _, err = BINDING_RAW.NewHttpRequest("http://lille/siri", "CheckStatus", "<Siri><CheckStatusRequest/></Siri>")
+	require.Nil(err)
+	body, err = ReadRequestBody(req)
+	require.Nil(err)
+	require.Equal("CheckStatusRequest", ActionOf(req, body))
+
+	req = httptest.NewRequest("GET", "http://lille/siri/2.0/stop-monitoring.json?MonitoringRef=CAS001", nil)
+	body, err = ReadRequestBody(req)
+	require.Nil(err)
+	require.Equal("stop-monitoring.json", ActionOf(req, body))
+}
+
+func TestSupplierOf(t *testing.T) {
+	require := require.New(t)
+	suppliers := map[string]string{"http://lille/siri": "lille"}
+
+	require.Equal("lille", SupplierOf(httptest.NewRequest("POST", "http://lille/siri/sm", strings.NewReader("")), suppliers))
+	require.Equal("amiens:8080", SupplierOf(httptest.NewRequest("POST", "http://amiens:8080/siri", strings.NewReader("")), suppliers))
+}
@@ -0,0 +1,257 @@
+package sirilite
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/julienbt/siri-sm/internal/clockskew"
+	"github.com/julienbt/siri-sm/internal/common/directionname"
+	siri_time "github.com/julienbt/siri-sm/internal/common/time"
+	"github.com/julienbt/siri-sm/internal/config"
+	"github.com/julienbt/siri-sm/internal/getstopmonitoring"
+	"github.com/julienbt/siri-sm/internal/siri"
+)
+
+const STOP_MONITORING_PATH string = "/stop-monitoring.json"
+
+// GetStopMonitoring is the SIRI Lite counterpart of `getstopmonitoring.GetStopMonitoring`,
+// the returned string is the URL of the request
+func GetStopMonitoring(
+	cfg config.ConfigSiriLite,
+	logger *logrus.Entry,
+	monitoringRef string,
+	lineRefs []string,
+) ([]getstopmonitoring.MonitoredStopVisit, string, []byte, error) {
+	var remoteErrorLoc = "SIRI Lite GetStopMonitoring remote error"
+
+	httpReq, reqUrl, err := generateHttpReq(&cfg, monitoringRef, lineRefs)
+	if err != nil {
+		return nil,
+			"",
+			nil,
+			fmt.Errorf("error in building SIRI Lite GetStopMonitoring request: %s", err)
+	}
+	location, err := cfg.Location()
+	if err != nil {
+		return nil,
+			"",
+			nil,
+			err
+	}
+
+	// Send HTTP request and receive the response
+	sentAt := time.Now()
+	resp, err := siri.SoapCall(httpReq)
+	if err != nil {
+		return nil,
+			reqUrl,
+			nil,
+			&siri.RemoteError{Loc: remoteErrorLoc, Err: fmt.Errorf("call error: %s", err)}
+	}
+
+	// Get the HTTP response body
+	defer resp.Body.Close()
+	jsonRespBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil,
+			reqUrl,
+			nil,
+			&siri.RemoteError{Loc: remoteErrorLoc, Err: fmt.Errorf("unreadable response body: %s", err)}
+	}
+	receivedAt := time.Now()
+
+	// Check HTTP status code
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil,
+			reqUrl,
+			jsonRespBody,
+			&siri.RemoteError{Loc: remoteErrorLoc, Err: fmt.Errorf("bad http-response status: %s", resp.Status)}
+	}
+
+	siriEnv := &SiriEnv{}
+	err = json.Unmarshal(jsonRespBody, siriEnv)
+	if err != nil {
+		return nil,
+			reqUrl,
+			jsonRespBody,
+			&siri.RemoteError{Loc: remoteErrorLoc, Err: fmt.Errorf("unmarshallable response body: %s", err)}
+	}
+	stopMonitoringDelivery, err := checkAndConvertDelivery(siriEnv)
+	if err != nil {
+		return nil,
+			reqUrl,
+			jsonRespBody,
+			&siri.RemoteError{Loc: remoteErrorLoc, Err: err}
+	}
+	siri_time.Normalize(stopMonitoringDelivery, location)
+	clockskew.Default.Record(logger, cfg.SupplierAddress, sentAt, receivedAt, time.Time(stopMonitoringDelivery.ResponseTimestamp))
+	monitoredStopVisits, err := getstopmonitoring.ExtractMonitoredStopVisits(stopMonitoringDelivery)
+	if err != nil {
+		return nil,
+			reqUrl,
+			jsonRespBody,
+			&siri.RemoteError{Loc: remoteErrorLoc, Err: err}
+	}
+	monitoredStopVisits, err = getstopmonitoring.FilterByLineRefs(monitoredStopVisits, lineRefs)
+	if err != nil {
+		return nil,
+			reqUrl,
+			jsonRespBody,
+			err
+	}
+	return monitoredStopVisits, reqUrl, jsonRespBody, nil
+}
+
+func generateHttpReq(cfg *config.ConfigSiriLite, monitoringRef string, lineRefs []string) (*http.Request, string, error) {
+	supplierAddressUrl, err := url.Parse(strings.TrimSuffix(cfg.SupplierAddress, "/") + STOP_MONITORING_PATH)
+	if err != nil {
+		return nil, "", fmt.Errorf("error the supplier address is not a valid URL: %s", cfg.SupplierAddress)
+	}
+	query := supplierAddressUrl.Query()
+	query.Set("MonitoringRef", monitoringRef)
+	if len(lineRefs) == 1 {
+		query.Set("LineRef", lineRefs[0])
+	}
+	supplierAddressUrl.RawQuery = query.Encode()
+	reqUrl := supplierAddressUrl.String()
+
+	httpReq, err := http.NewRequest(http.MethodGet, reqUrl, nil)
+	if err != nil {
+		return nil, "", fmt.Errorf("error building http-request: %s", err)
+	}
+	httpReq.Header.Set("Accept", "application/json")
+	if cfg.ApiKey != "" {
+		httpReq.Header.Set("apikey", cfg.ApiKey)
+	}
+	return httpReq, reqUrl, nil
+}
+
+func checkAndConvertDelivery(siriEnv *SiriEnv) (*getstopmonitoring.StopMonitoringDelivery, error) {
+	serviceDelivery := siriEnv.Siri.ServiceDelivery
+	if serviceDelivery == nil {
+		return nil, fmt.Errorf("no `ServiceDelivery` in response body")
+	}
+	if serviceDelivery.ErrorCondition != nil {
+		return nil, fmt.Errorf("error condition in `ServiceDelivery`: %s", serviceDelivery.ErrorCondition.Error())
+	}
+	if serviceDelivery.Status != nil && !*serviceDelivery.Status {
+		return nil, fmt.Errorf("status not true in `ServiceDelivery`")
+	}
+	const EXPECTED_NUMBER_OF_STOP_MONITORING_DELIVERIES int = 1
+	if len(serviceDelivery.StopMonitoringDelivery) != EXPECTED_NUMBER_OF_STOP_MONITORING_DELIVERIES {
+		return nil, fmt.Errorf("invalid number of `StopMonitoringDelivery`: %d", len(serviceDelivery.StopMonitoringDelivery))
+	}
+	delivery := &serviceDelivery.StopMonitoringDelivery[0]
+	if delivery.ErrorCondition != nil {
+		return nil, fmt.Errorf("error condition in `StopMonitoringDelivery`: %s", delivery.ErrorCondition.Error())
+	}
+	if delivery.Status != nil && !*delivery.Status {
+		return nil, fmt.Errorf("status not true in `StopMonitoringDelivery`")
+	}
+	stopMonitoringDelivery, err := convertDelivery(delivery)
+	if err != nil {
+		return nil, err
+	}
+	// The `ResponseTimestamp` of the delivery is optional in SIRI Lite
+	if time.Time(stopMonitoringDelivery.ResponseTimestamp).IsZero() {
+		stopMonitoringDelivery.ResponseTimestamp = siri_time.Time(serviceDelivery.ResponseTimestamp)
+	}
+	return stopMonitoringDelivery, nil
+}
+
+func convertDelivery(delivery *StopMonitoringDelivery) (*getstopmonitoring.StopMonitoringDelivery, error) {
+	stopMonitoringDelivery := &getstopmonitoring.StopMonitoringDelivery{
+		ResponseTimestamp: siri_time.Time(delivery.ResponseTimestamp),
+	}
+	if delivery.MonitoringRef != "" {
+		monitoringRef, err := getstopmonitoring.ParseStopPointRef(string(delivery.MonitoringRef))
+		if err != nil {
+			return nil, err
+		}
+		stopMonitoringDelivery.MonitoringRef = monitoringRef
+	}
+	for i := range delivery.MonitoredStopVisit {
+		monitoredStopVisit, err := convertMonitoredStopVisit(&delivery.MonitoredStopVisit[i])
+		if err != nil {
+			return nil, fmt.Errorf("error in `MonitoredStopVisit` %d: %s", i, err)
+		}
+		stopMonitoringDelivery.MonitoredStopVisits = append(stopMonitoringDelivery.MonitoredStopVisits, monitoredStopVisit)
+	}
+	for _, cancellation := range delivery.MonitoredStopVisitCancellation {
+		monitoringRef, err := getstopmonitoring.ParseStopPointRef(string(cancellation.MonitoringRef))
+		if err != nil {
+			return nil, err
+		}
+		stopMonitoringDelivery.MonitoredStopVisitCancellations = append(
+			stopMonitoringDelivery.MonitoredStopVisitCancellations,
+			getstopmonitoring.MonitoredStopVisitCancellation{
+				ItemRef:       string(cancellation.ItemRef),
+				MonitoringRef: monitoringRef,
+			},
+		)
+	}
+	return stopMonitoringDelivery, nil
+}
+
+func convertMonitoredStopVisit(visit *MonitoredStopVisit) (getstopmonitoring.MonitoredStopVisit, error) {
+	journey := &visit.MonitoredVehicleJourney
+	monitoringRef, err := getstopmonitoring.ParseStopPointRef(string(visit.MonitoringRef))
+	if err != nil {
+		return getstopmonitoring.MonitoredStopVisit{}, err
+	}
+	lineRef, err := getstopmonitoring.ParseLineRef(string(journey.LineRef))
+	if err != nil {
+		return getstopmonitoring.MonitoredStopVisit{}, err
+	}
+	// The optional fields are parsed only when present, like in SIRI XML
+	var directionName directionname.DirectionName
+	if journey.DirectionName != "" {
+		directionName, err = directionname.Parse(string(journey.DirectionName))
+		if err != nil {
+			return getstopmonitoring.MonitoredStopVisit{}, err
+		}
+	}
+	var destinationRef getstopmonitoring.StopPointRef
+	if journey.DestinationRef != "" {
+		destinationRef, err = getstopmonitoring.ParseStopPointRef(string(journey.DestinationRef))
+		if err != nil {
+			return getstopmonitoring.MonitoredStopVisit{}, err
+		}
+	}
+	stopPointRef, err := getstopmonitoring.ParseStopPointRef(string(journey.MonitoredCall.StopPointRef))
+	if err != nil {
+		return getstopmonitoring.MonitoredStopVisit{}, err
+	}
+	call := &journey.MonitoredCall
+	return getstopmonitoring.MonitoredStopVisit{
+		ItemIdentifier: visit.ItemIdentifier,
+		MonitoringRef:  monitoringRef,
+		MonitoredVehicleJourney: getstopmonitoring.MonitoredVehicleJourney{
+			LineRef: lineRef,
+			FramedVehicleJourneyRef: getstopmonitoring.FramedVehicleJourneyRef{
+				DataFrameRef:           string(journey.FramedVehicleJourneyRef.DataFrameRef),
+				DatedVehicleJourneyRef: string(journey.FramedVehicleJourneyRef.DatedVehicleJourneyRef),
+			},
+			DirectionName:   directionName,
+			DestinationRef:  destinationRef,
+			DestinationName: string(journey.DestinationName),
+			Cancellation:    bool(journey.Cancellation),
+			MonitoredCall: getstopmonitoring.MonitoredCall{
+				StopPointRef:          stopPointRef,
+				AimedArrivalTime:      siri_time.Time(call.AimedArrivalTime),
+				ExpectedArrivalTime:   siri_time.Time(call.ExpectedArrivalTime),
+				ArrivalStatus:         call.ArrivalStatus,
+				AimedDepartureTime:    siri_time.Time(call.AimedDepartureTime),
+				ExpectedDepartureTime: siri_time.Time(call.ExpectedDepartureTime),
+				DepartureStatus:       call.DepartureStatus,
+			},
+		},
+	}, nil
+}
@@ -0,0 +1,187 @@
+package sirilite
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+
+	"github.com/julienbt/siri-sm/internal/clockskew"
+	"github.com/julienbt/siri-sm/internal/common/directionname"
+	"github.com/julienbt/siri-sm/internal/config"
+	"github.com/julienbt/siri-sm/internal/siri"
+)
+
+var testDataDir string
+
+func TestMain(m *testing.M) {
+
+	testDataDir = os.Getenv("SIRISM_TEST_DATA_DIR")
+	if testDataDir == "" {
+		panic("$SIRISM_TEST_DATA_DIR isn't set")
+	}
+
+	os.Exit(m.Run())
+}
+
+func newSupplier(t *testing.T, statusCode int, body []byte) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/siri/2.0"+STOP_MONITORING_PATH, r.URL.Path)
+		require.Equal(t, "ILEVIA:StopPoint:BP:CAS001:LOC", r.URL.Query().Get("MonitoringRef"))
+		require.Equal(t, "secret", r.Header.Get("apikey"))
+		w.WriteHeader(statusCode)
+		_, _ = w.Write(body)
+	}))
+}
+
+func getStopMonitoring(t *testing.T, statusCode int, body []byte) ([]byte, error) {
+	supplier := newSupplier(t, statusCode, body)
+	defer supplier.Close()
+	cfg := config.ConfigSiriLite{
+		SupplierAddress: supplier.URL + "/siri/2.0",
+		ApiKey:          "secret",
+	}
+	_, _, jsonRespBody, err := GetStopMonitoring(cfg, logrus.NewEntry(logrus.New()), "ILEVIA:StopPoint:BP:CAS001:LOC", nil)
+	return jsonRespBody, err
+}
+
+func TestGetStopMonitoring(t *testing.T) {
+	require := require.New(t)
+
+	jsonRespBody, err := ioutil.ReadFile(
+		fmt.Sprintf(
+			"%s/examples/SM_LITE_RESP_000.json",
+			testDataDir,
+		),
+	)
+	require.Nil(err)
+	supplier := newSupplier(t, http.StatusOK, jsonRespBody)
+	defer supplier.Close()
+
+	cfg := config.ConfigSiriLite{
+		SupplierAddress: supplier.URL + "/siri/2.0/",
+		ApiKey:          "secret",
+	}
+	monitoredStopVisits, _, _, err := GetStopMonitoring(cfg, logrus.NewEntry(logrus.New()), "ILEVIA:StopPoint:BP:CAS001:LOC", nil)
+	require.Nil(err)
+	require.Len(monitoredStopVisits, 2)
+	skew, ok := clockskew.Default.Get(cfg.SupplierAddress)
+	require.True(ok)
+	require.True(time.Date(2022, 8, 30, 8, 0, 1, 123000000, time.UTC).Equal(skew.ResponseTimestamp))
+
+	journey := monitoredStopVisits[0].MonitoredVehicleJourney
+	require.Equal("CAS001", string(monitoredStopVisits[0].MonitoringRef))
+	require.Equal("L1", string(journey.LineRef))
+	require.Equal("ILEVIA:VehicleJourney::VJ1:LOC", journey.FramedVehicleJourneyRef.DatedVehicleJourneyRef)
+	require.Equal(directionname.DirectionNameAller, journey.DirectionName)
+	require.Equal("CAS002", string(journey.DestinationRef))
+	require.Equal("Lille Flandres", journey.DestinationName)
+	require.Equal(
+		150*time.Second,
+		time.Time(journey.MonitoredCall.ExpectedDepartureTime).Sub(time.Time(journey.MonitoredCall.AimedDepartureTime)),
+	)
+
+	journey = monitoredStopVisits[1].MonitoredVehicleJourney
+	require.Equal("L2", string(journey.LineRef))
+	require.Equal(directionname.DirectionNameRetour, journey.DirectionName)
+	require.True(journey.MonitoredCall.IsCancelled())
+	require.True(time.Time(journey.MonitoredCall.ExpectedDepartureTime).IsZero())
+}
+
+func TestGetStopMonitoringErrorConditions(t *testing.T) {
+	require := require.New(t)
+
+	testCases := []struct {
+		name       string
+		statusCode int
+		body       string
+	}{
+		{"bad http status", http.StatusUnauthorized, `{}`},
+		{"unmarshallable", http.StatusOK, `<Siri/>`},
+		{"no service delivery", http.StatusOK, `{"Siri": {}}`},
+		{"no stop monitoring delivery", http.StatusOK, `{"Siri": {"ServiceDelivery": {}}}`},
+		{
+			"error condition",
+			http.StatusOK,
+			`{"Siri": {"ServiceDelivery": {"StopMonitoringDelivery": [{"Status": false,
+				"ErrorCondition": {"InvalidDataReferencesError": {"ErrorText": "unknown MonitoringRef"}}}]}}}`,
+		},
+		{"status false", http.StatusOK, `{"Siri": {"ServiceDelivery": {"StopMonitoringDelivery": [{"Status": "false"}]}}}`},
+	}
+	for _, testCase := range testCases {
+		_, err := getStopMonitoring(t, testCase.statusCode, []byte(testCase.body))
+		require.NotNil(err, testCase.name)
+		_, isRemoteError := err.(*siri.RemoteError)
+		require.True(isRemoteError, testCase.name)
+	}
+
+	_, err := getStopMonitoring(t, http.StatusOK, []byte(
+		`{"Siri": {"ServiceDelivery": {"StopMonitoringDelivery": [{"Status": false,
+			"ErrorCondition": {"InvalidDataReferencesError": {"ErrorText": "unknown MonitoringRef"}}}]}}}`,
+	))
+	require.Contains(err.Error(), "InvalidDataReferencesError: unknown MonitoringRef")
+}
+
+func TestGetStopMonitoringWithoutOptionalFields(t *testing.T) {
+	require := require.New(t)
+
+	supplier := newSupplier(t, http.StatusOK, []byte(`{"Siri": {"ServiceDelivery": {"StopMonitoringDelivery": [{
+		"Status": "true",
+		"MonitoredStopVisit": [{
+			"MonitoringRef": "ILEVIA:StopPoint:BP:CAS001:LOC",
+			"MonitoredVehicleJourney": {
+				"LineRef": "ILEVIA:Line:BP:L1:LOC",
+				"MonitoredCall": {
+					"StopPointRef": "ILEVIA:StopPoint:BP:CAS001:LOC",
+					"AimedDepartureTime": "2022-08-30T10:05:00+02:00"
+				}
+			}
+		}]
+	}]}}}`))
+	defer supplier.Close()
+	cfg := config.ConfigSiriLite{
+		SupplierAddress: supplier.URL + "/siri/2.0",
+		ApiKey:          "secret",
+	}
+	monitoredStopVisits, _, _, err := GetStopMonitoring(cfg, logrus.NewEntry(logrus.New()), "ILEVIA:StopPoint:BP:CAS001:LOC", nil)
+	require.Nil(err)
+	require.Len(monitoredStopVisits, 1)
+	journey := monitoredStopVisits[0].MonitoredVehicleJourney
+	require.Equal("L1", string(journey.LineRef))
+	require.Empty(journey.DirectionName)
+	require.Empty(journey.DestinationRef)
+}
+
+func TestGetStopMonitoringCancellationAsString(t *testing.T) {
+	require := require.New(t)
+
+	supplier := newSupplier(t, http.StatusOK, []byte(`{"Siri": {"ServiceDelivery": {"StopMonitoringDelivery": [{
+		"Status": true,
+		"MonitoredStopVisit": [{
+			"MonitoringRef": "ILEVIA:StopPoint:BP:CAS001:LOC",
+			"MonitoredVehicleJourney": {
+				"LineRef": "ILEVIA:Line:BP:L1:LOC",
+				"Cancellation": "true",
+				"MonitoredCall": {
+					"StopPointRef": "ILEVIA:StopPoint:BP:CAS001:LOC",
+					"AimedDepartureTime": "2022-08-30T10:05:00+02:00"
+				}
+			}
+		}]
+	}]}}}`))
+	defer supplier.Close()
+	cfg := config.ConfigSiriLite{
+		SupplierAddress: supplier.URL + "/siri/2.0",
+		ApiKey:          "secret",
+	}
+	monitoredStopVisits, _, _, err := GetStopMonitoring(cfg, logrus.NewEntry(logrus.New()), "ILEVIA:StopPoint:BP:CAS001:LOC", nil)
+	require.Nil(err)
+	require.Len(monitoredStopVisits, 1)
+	require.True(monitoredStopVisits[0].MonitoredVehicleJourney.Cancellation)
+}
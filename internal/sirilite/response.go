@@ -0,0 +1,207 @@
+package sirilite
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	siri_time "github.com/julienbt/siri-sm/internal/common/time"
+)
+
+type SiriEnv struct {
+	Siri Siri `json:"Siri"`
+}
+
+type Siri struct {
+	ServiceDelivery *ServiceDelivery `json:"ServiceDelivery"`
+}
+
+type ServiceDelivery struct {
+	ResponseTimestamp      Time                     `json:"ResponseTimestamp"`
+	ProducerRef            Value                    `json:"ProducerRef"`
+	Status                 *Bool                    `json:"Status"`
+	ErrorCondition         *ErrorCondition          `json:"ErrorCondition"`
+	StopMonitoringDelivery []StopMonitoringDelivery `json:"StopMonitoringDelivery"`
+}
+
+type StopMonitoringDelivery struct {
+	ResponseTimestamp              Time                             `json:"ResponseTimestamp"`
+	Version                        string                           `json:"version"`
+	Status                         *Bool                            `json:"Status"`
+	ErrorCondition                 *ErrorCondition                  `json:"ErrorCondition"`
+	MonitoringRef                  Value                            `json:"MonitoringRef"`
+	MonitoredStopVisit             []MonitoredStopVisit             `json:"MonitoredStopVisit"`
+	MonitoredStopVisitCancellation []MonitoredStopVisitCancellation `json:"MonitoredStopVisitCancellation"`
+}
+
+// ErrorCondition keeps the name of the SIRI error (e.g. `AccessNotAllowedError`)
+// and its `ErrorText`
+type ErrorCondition struct {
+	Errors      map[string]ErrorDescription `json:"-"`
+	Description Value                       `json:"Description"`
+}
+
+type ErrorDescription struct {
+	ErrorText string `json:"ErrorText"`
+}
+
+func (ec *ErrorCondition) UnmarshalJSON(data []byte) error {
+	var fields map[string]json.RawMessage
+	err := json.Unmarshal(data, &fields)
+	if err != nil {
+		return err
+	}
+	ec.Errors = make(map[string]ErrorDescription)
+	for name, field := range fields {
+		if name == "Description" {
+			err = json.Unmarshal(field, &ec.Description)
+		} else {
+			var description ErrorDescription
+			err = json.Unmarshal(field, &description)
+			ec.Errors[name] = description
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (ec *ErrorCondition) Error() string {
+	names := make([]string, 0, len(ec.Errors))
+	for name := range ec.Errors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	parts := make([]string, 0, len(ec.Errors)+1)
+	for _, name := range names {
+		description := ec.Errors[name]
+		if description.ErrorText != "" {
+			parts = append(parts, fmt.Sprintf("%s: %s", name, description.ErrorText))
+		} else {
+			parts = append(parts, name)
+		}
+	}
+	if ec.Description != "" {
+		parts = append(parts, string(ec.Description))
+	}
+	if len(parts) == 0 {
+		return "unknown error condition"
+	}
+	return strings.Join(parts, ", ")
+}
+
+type MonitoredStopVisit struct {
+	ItemIdentifier          string                  `json:"ItemIdentifier"`
+	MonitoringRef           Value                   `json:"MonitoringRef"`
+	MonitoredVehicleJourney MonitoredVehicleJourney `json:"MonitoredVehicleJourney"`
+}
+
+type MonitoredVehicleJourney struct {
+	LineRef                 Value                   `json:"LineRef"`
+	FramedVehicleJourneyRef FramedVehicleJourneyRef `json:"FramedVehicleJourneyRef"`
+	DirectionName           Value                   `json:"DirectionName"`
+	DestinationRef          Value                   `json:"DestinationRef"`
+	DestinationName         Value                   `json:"DestinationName"`
+	Cancellation            Bool                    `json:"Cancellation"`
+	MonitoredCall           MonitoredCall           `json:"MonitoredCall"`
+}
+
+type FramedVehicleJourneyRef struct {
+	DataFrameRef           Value `json:"DataFrameRef"`
+	DatedVehicleJourneyRef Value `json:"DatedVehicleJourneyRef"`
+}
+
+type MonitoredCall struct {
+	StopPointRef          Value  `json:"StopPointRef"`
+	AimedArrivalTime      Time   `json:"AimedArrivalTime"`
+	ExpectedArrivalTime   Time   `json:"ExpectedArrivalTime"`
+	ArrivalStatus         string `json:"ArrivalStatus"`
+	AimedDepartureTime    Time   `json:"AimedDepartureTime"`
+	ExpectedDepartureTime Time   `json:"ExpectedDepartureTime"`
+	DepartureStatus       string `json:"DepartureStatus"`
+}
+
+type MonitoredStopVisitCancellation struct {
+	ItemRef       Value `json:"ItemRef"`
+	MonitoringRef Value `json:"MonitoringRef"`
+}
+
+// Value is a SIRI Lite text which is either a plain string, an object
+// `{"value": "..."}` or a list of such objects (only the first one is kept)
+type Value string
+
+func (v *Value) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 || string(data) == "null" {
+		return nil
+	}
+	switch data[0] {
+	case '"':
+		var s string
+		err := json.Unmarshal(data, &s)
+		if err != nil {
+			return err
+		}
+		*v = Value(s)
+		return nil
+	case '{':
+		var object struct {
+			Value string `json:"value"`
+		}
+		err := json.Unmarshal(data, &object)
+		if err != nil {
+			return err
+		}
+		*v = Value(object.Value)
+		return nil
+	case '[':
+		var values []Value
+		err := json.Unmarshal(data, &values)
+		if err != nil {
+			return err
+		}
+		if len(values) > 0 {
+			*v = values[0]
+		}
+		return nil
+	}
+	return fmt.Errorf("the SIRI Lite value is not well formatted: %s", data)
+}
+
+// Bool accepts both JSON booleans and the strings "true" and "false"
+type Bool bool
+
+func (b *Bool) UnmarshalJSON(data []byte) error {
+	switch strings.Trim(string(bytes.TrimSpace(data)), `"`) {
+	case "true":
+		*b = true
+	case "false":
+		*b = false
+	default:
+		return fmt.Errorf("the SIRI Lite boolean is not well formatted: %s", data)
+	}
+	return nil
+}
+
+type Time time.Time
+
+func (t *Time) UnmarshalJSON(data []byte) error {
+	var s string
+	err := json.Unmarshal(data, &s)
+	if err != nil {
+		return err
+	}
+	if s == "" {
+		return nil
+	}
+	parsed, err := siri_time.Parse(s)
+	if err != nil {
+		return err
+	}
+	*t = Time(parsed)
+	return nil
+}
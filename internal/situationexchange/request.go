@@ -0,0 +1,179 @@
+package situationexchange
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"text/template"
+	"time"
+
+	"github.com/julienbt/siri-sm/internal/clockskew"
+	"github.com/julienbt/siri-sm/internal/config"
+	"github.com/julienbt/siri-sm/internal/getstopmonitoring"
+	"github.com/julienbt/siri-sm/internal/messageid"
+	"github.com/julienbt/siri-sm/internal/siri"
+	"github.com/sirupsen/logrus"
+)
+
+type GetSituationExchangeRequest struct {
+	SupplierAddress   url.URL
+	Binding           siri.Binding
+	Location          *time.Location // of the supplier
+	RequestTimestamp  time.Time
+	RequestorRef      string
+	MessageIdentifier string
+	LineRef           string
+}
+
+// GetSituationExchange requests the situations affecting the lines, or all
+// the situations without line
+func GetSituationExchange(
+	cfg config.ConfigCheckStatus,
+	logger *logrus.Entry,
+	requestTimestamp *time.Time,
+	lineRefs []string,
+) ([]PtSituationElement, string, []byte, error) {
+	var remoteErrorLoc = "GetSituationExchange remote error"
+
+	req := GetSituationExchangeRequest{}
+	err := req.populate(&cfg, requestTimestamp, lineRefs)
+	if err != nil {
+		return nil,
+			"",
+			nil,
+			fmt.Errorf("error GetSituationExchange request initialization: %v", err)
+	}
+	httpReq, htmlReqBody, err := req.generateHttpReq()
+	if err != nil {
+		return nil,
+			"",
+			nil,
+			fmt.Errorf("error in building GetSituationExchange request: %s", err)
+	}
+
+	// Send HTTP request and receive the response
+	resp, err := siri.SoapCall(httpReq)
+	if err != nil {
+		return nil,
+			htmlReqBody,
+			nil,
+			&siri.RemoteError{Loc: remoteErrorLoc, Err: fmt.Errorf("call error: %s", err)}
+	}
+
+	// Get the HTTP response body
+	defer resp.Body.Close()
+	htmlRespBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil,
+			htmlReqBody,
+			nil,
+			&siri.RemoteError{Loc: remoteErrorLoc, Err: fmt.Errorf("unreadable response body: %s", err)}
+	}
+
+	// Check HTTP status code
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil,
+			htmlReqBody,
+			htmlRespBody,
+			&siri.RemoteError{Loc: remoteErrorLoc, Err: fmt.Errorf("bad http-response status: %s", resp.Status)}
+	}
+
+	delivery := &SituationExchangeDelivery{}
+	err = req.Binding.DecodeAnswerIn(htmlRespBody, SITUATION_EXCHANGE_DELIVERY_PATH, delivery, req.Location)
+	if err != nil {
+		return nil,
+			htmlReqBody,
+			htmlRespBody,
+			&siri.RemoteError{Loc: remoteErrorLoc, Err: fmt.Errorf("unmarshallable response body: %s", err)}
+	}
+	if delivery.Status != nil && !*delivery.Status {
+		return nil,
+			htmlReqBody,
+			htmlRespBody,
+			&siri.RemoteError{Loc: remoteErrorLoc, Err: fmt.Errorf("status not true in `SituationExchangeDelivery`")}
+	}
+	situations, err := FilterByLineRefs(delivery.Situations, lineRefs)
+	if err != nil {
+		return nil,
+			htmlReqBody,
+			htmlRespBody,
+			err
+	}
+	return situations, htmlReqBody, htmlRespBody, nil
+}
+
+// FilterByLineRefs keeps the situations affecting the lines, or without
+// affected line, all the situations are kept without line
+func FilterByLineRefs(situations []PtSituationElement, lineRefs []string) ([]PtSituationElement, error) {
+	if len(lineRefs) == 0 {
+		return situations, nil
+	}
+	lineIds := make(map[getstopmonitoring.LineRef]bool, len(lineRefs))
+	for _, lineRef := range lineRefs {
+		lineId, err := getstopmonitoring.ParseLineRef(lineRef)
+		if err != nil {
+			return nil, err
+		}
+		lineIds[lineId] = true
+	}
+	filteredSituations := make([]PtSituationElement, 0, len(situations))
+	for _, situation := range situations {
+		affectedLineRefs := situation.Disruption().LineRefs
+		keep := len(affectedLineRefs) == 0
+		for _, affectedLineRef := range affectedLineRefs {
+			keep = keep || lineIds[affectedLineRef]
+		}
+		if keep {
+			filteredSituations = append(filteredSituations, situation)
+		}
+	}
+	return filteredSituations, nil
+}
+
+func (req *GetSituationExchangeRequest) populate(
+	cfg *config.ConfigCheckStatus,
+	requestTimestamp *time.Time,
+	lineRefs []string,
+) error {
+	supplierAddressUrl, err := url.Parse(cfg.SupplierAddress)
+	if err != nil {
+		return fmt.Errorf("error the supplier address is not a valid URL: %s", cfg.SupplierAddress)
+	}
+	binding, err := siri.ParseBinding(cfg.Binding)
+	if err != nil {
+		return err
+	}
+	req.Binding = binding
+	location, err := cfg.Location()
+	if err != nil {
+		return err
+	}
+	req.Location = location
+	req.RequestTimestamp = clockskew.Default.Compensate(cfg.SupplierAddress, requestTimestamp.In(location))
+	req.RequestorRef = cfg.SubscriberRef
+	req.MessageIdentifier, err = messageid.New(cfg.MessageIdPattern, cfg.SubscriberRef, "ResponseMessage", req.RequestTimestamp)
+	if err != nil {
+		return err
+	}
+	// A request has at most one `LineRef`, several lines are filtered on the response
+	if len(lineRefs) == 1 {
+		req.LineRef = lineRefs[0]
+	}
+	req.SupplierAddress = *supplierAddressUrl
+	return nil
+}
+
+func (req *GetSituationExchangeRequest) generateHttpReq() (*http.Request, string, error) {
+	tmpl, err := template.ParseFiles("./template/getsituationexchange-request.tmpl")
+	if err != nil {
+		return nil, "", fmt.Errorf("error parsing template: %s", err)
+	}
+	payloadBuffer := &bytes.Buffer{}
+	err = tmpl.ExecuteTemplate(payloadBuffer, req.Binding.PayloadTemplateName(), req)
+	if err != nil {
+		return nil, "", fmt.Errorf("error building template: %s", err)
+	}
+	return req.Binding.NewHttpRequest(req.SupplierAddress.String(), "GetSituationExchange", payloadBuffer.String())
+}
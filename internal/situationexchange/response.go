@@ -0,0 +1,89 @@
+package situationexchange
+
+import (
+	"encoding/xml"
+	"strings"
+	"time"
+
+	siri_time "github.com/julienbt/siri-sm/internal/common/time"
+	"github.com/julienbt/siri-sm/internal/disruption"
+	"github.com/julienbt/siri-sm/internal/siri"
+)
+
+var SITUATION_EXCHANGE_DELIVERY_PATH = siri.AnswerPath{
+	Soap: []string{"GetSituationExchangeResponse", "Answer", "SituationExchangeDelivery"},
+	Raw:  []string{"ServiceDelivery", "SituationExchangeDelivery"},
+}
+
+type SituationExchangeDelivery struct {
+	XMLName           xml.Name             `xml:"SituationExchangeDelivery"`
+	ResponseTimestamp siri_time.Time       `xml:"ResponseTimestamp"`
+	Status            *bool                `xml:"Status"`
+	Situations        []PtSituationElement `xml:"Situations>PtSituationElement"`
+}
+
+type PtSituationElement struct {
+	XMLName         xml.Name         `xml:"PtSituationElement"`
+	CreationTime    siri_time.Time   `xml:"CreationTime"`
+	ParticipantRef  string           `xml:"ParticipantRef"`
+	SituationNumber string           `xml:"SituationNumber"`
+	Version         int              `xml:"Version"`
+	Progress        string           `xml:"Progress"` // e.g. "open", "closed"
+	ValidityPeriods []ValidityPeriod `xml:"ValidityPeriod"`
+	Severity        string           `xml:"Severity"`
+	ReportType      string           `xml:"ReportType"`
+	Summary         string           `xml:"Summary"`
+	Description     string           `xml:"Description"`
+	Affects         Affects          `xml:"Affects"`
+}
+
+type ValidityPeriod struct {
+	StartTime siri_time.Time `xml:"StartTime"`
+	EndTime   siri_time.Time `xml:"EndTime"`
+}
+
+type Affects struct {
+	AffectedLines      []AffectedLine      `xml:"Networks>AffectedNetwork>AffectedLine"`
+	AffectedStopPoints []AffectedStopPoint `xml:"StopPoints>AffectedStopPoint"`
+}
+
+type AffectedLine struct {
+	LineRef string `xml:"LineRef"`
+}
+
+type AffectedStopPoint struct {
+	StopPointRef  string `xml:"StopPointRef"`
+	StopPointName string `xml:"StopPointName"`
+}
+
+const PROGRESS_CLOSED string = "closed"
+
+func (s *PtSituationElement) IsClosed() bool {
+	return s.Progress == PROGRESS_CLOSED
+}
+
+func (s *PtSituationElement) Disruption() disruption.Disruption {
+	lineRefs := make([]string, 0, len(s.Affects.AffectedLines))
+	for _, affectedLine := range s.Affects.AffectedLines {
+		lineRefs = append(lineRefs, affectedLine.LineRef)
+	}
+	stopPointRefs := make([]string, 0, len(s.Affects.AffectedStopPoints))
+	for _, affectedStopPoint := range s.Affects.AffectedStopPoints {
+		stopPointRefs = append(stopPointRefs, affectedStopPoint.StopPointRef)
+	}
+	d := disruption.Disruption{
+		Id:          s.SituationNumber,
+		Source:      disruption.SOURCE_SITUATION_EXCHANGE,
+		Summary:     strings.TrimSpace(s.Summary),
+		Description: strings.TrimSpace(s.Description),
+		LineRefs:    disruption.ParseLineRefs(lineRefs),
+		StopRefs:    disruption.ParseStopPointRefs(stopPointRefs),
+	}
+	for _, validityPeriod := range s.ValidityPeriods {
+		d.ValidityPeriods = append(d.ValidityPeriods, disruption.Period{
+			Start: time.Time(validityPeriod.StartTime),
+			End:   time.Time(validityPeriod.EndTime),
+		})
+	}
+	return d
+}
@@ -0,0 +1,71 @@
+package situationexchange
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/julienbt/siri-sm/internal/disruption"
+	"github.com/julienbt/siri-sm/internal/getstopmonitoring"
+	"github.com/julienbt/siri-sm/internal/siri"
+)
+
+var testDataDir string
+
+func TestMain(m *testing.M) {
+
+	testDataDir = os.Getenv("SIRISM_TEST_DATA_DIR")
+	if testDataDir == "" {
+		panic("$SIRISM_TEST_DATA_DIR isn't set")
+	}
+
+	os.Exit(m.Run())
+}
+
+func TestSituationExchangeDeliveryDecode(t *testing.T) {
+	require := require.New(t)
+
+	htmlRespBody, err := ioutil.ReadFile(
+		fmt.Sprintf(
+			"%s/examples/SX_RESP_000.xml",
+			testDataDir,
+		),
+	)
+	require.Nil(err)
+
+	delivery := SituationExchangeDelivery{}
+	err = siri.BINDING_RAW.DecodeAnswer(htmlRespBody, SITUATION_EXCHANGE_DELIVERY_PATH, &delivery)
+	require.Nil(err)
+	require.Len(delivery.Situations, 2)
+	require.False(delivery.Situations[0].IsClosed())
+	require.True(delivery.Situations[1].IsClosed())
+
+	disruptions := make([]disruption.Disruption, 0, len(delivery.Situations))
+	for i := range delivery.Situations {
+		disruptions = append(disruptions, delivery.Situations[i].Disruption())
+	}
+	require.Equal("Ligne 2 déviée", disruptions[0].Summary)
+	require.Equal([]getstopmonitoring.LineRef{"L2"}, disruptions[0].LineRefs)
+	require.Equal([]getstopmonitoring.StopPointRef{"CAT001"}, disruptions[1].StopRefs)
+
+	// The line-wide situation affects every stop of the line
+	paris, err := time.LoadLocation("Europe/Paris")
+	require.Nil(err)
+	at := time.Date(2022, 5, 25, 12, 0, 0, 0, paris)
+	casino := disruption.ForStop(disruptions, "CAS001", []getstopmonitoring.LineRef{"L1", "L2"}, at)
+	require.Len(casino, 1)
+	require.Equal("ILEVIA:Situation:SX7:LOC", casino[0].Id)
+	catholique := disruption.ForStop(disruptions, "CAT001", []getstopmonitoring.LineRef{"L1"}, at)
+	require.Len(catholique, 1)
+	require.Equal("ILEVIA:Situation:SX8:LOC", catholique[0].Id)
+	require.Empty(disruption.ForStop(disruptions, "CAS001", []getstopmonitoring.LineRef{"L2"}, at.Add(12*time.Hour)))
+
+	filtered, err := FilterByLineRefs(delivery.Situations, []string{"ILEVIA:Line:BP:L1:LOC"})
+	require.Nil(err)
+	require.Len(filtered, 1)
+	require.Equal("ILEVIA:Situation:SX8:LOC", filtered[0].SituationNumber)
+}
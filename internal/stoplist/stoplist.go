@@ -0,0 +1,86 @@
+package stoplist
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Entry is a line of a stop-list file: a stop id optionally followed by a
+// comment, e.g. `CAS001 # Casino (L1, L2)`
+type Entry struct {
+	StopId  string
+	Comment string
+}
+
+func ReadFile(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening the stop list: %s", err)
+	}
+	defer file.Close()
+	return Read(file)
+}
+
+// Read returns the stop ids, blank lines and comments are ignored
+func Read(r io.Reader) ([]string, error) {
+	stopIds := make([]string, 0)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if strings.ContainsAny(line, " \t") {
+			return nil, fmt.Errorf("the stop list line is not well formatted: %s", line)
+		}
+		stopIds = append(stopIds, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading the stop list: %s", err)
+	}
+	return stopIds, nil
+}
+
+// WriteFile refuses to replace the file by an empty stop list, e.g. after an
+// empty StopPointsDiscovery answer
+func WriteFile(path string, header string, entries []Entry) error {
+	if len(entries) == 0 {
+		return fmt.Errorf("no stop in the stop list, %s left unchanged", path)
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("error creating the stop list: %s", err)
+	}
+	err = Write(file, header, entries)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+func Write(w io.Writer, header string, entries []Entry) error {
+	bufferedWriter := bufio.NewWriter(w)
+	for _, line := range strings.Split(header, "\n") {
+		if line != "" {
+			fmt.Fprintf(bufferedWriter, "# %s\n", line)
+		}
+	}
+	for _, entry := range entries {
+		if entry.Comment != "" {
+			fmt.Fprintf(bufferedWriter, "%s # %s\n", entry.StopId, entry.Comment)
+		} else {
+			fmt.Fprintf(bufferedWriter, "%s\n", entry.StopId)
+		}
+	}
+	if err := bufferedWriter.Flush(); err != nil {
+		return fmt.Errorf("error writing the stop list: %s", err)
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package stoplist
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteAndRead(t *testing.T) {
+	require := require.New(t)
+
+	buffer := &bytes.Buffer{}
+	err := Write(buffer, "Stop points of ILEVIA", []Entry{
+		{StopId: "CAS001", Comment: "Casino (L1, L2)"},
+		{StopId: "CAS002"},
+	})
+	require.Nil(err)
+	require.Equal("# Stop points of ILEVIA\nCAS001 # Casino (L1, L2)\nCAS002\n", buffer.String())
+
+	stopIds, err := Read(buffer)
+	require.Nil(err)
+	require.Equal([]string{"CAS001", "CAS002"}, stopIds)
+}
+
+func TestReadNotWellFormatted(t *testing.T) {
+	_, err := Read(strings.NewReader("CAS001 CAS002\n"))
+	require.NotNil(t, err)
+}
+
+func TestWriteFileEmpty(t *testing.T) {
+	require := require.New(t)
+
+	path := filepath.Join(t.TempDir(), "stop-list.txt")
+	require.Nil(WriteFile(path, "", []Entry{{StopId: "CAS001"}}))
+	require.NotNil(WriteFile(path, "", []Entry{}))
+	content, err := ioutil.ReadFile(path)
+	require.Nil(err)
+	require.Equal("CAS001\n", string(content))
+}
@@ -0,0 +1,180 @@
+package stoppointsdiscovery
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"text/template"
+	"time"
+
+	"github.com/julienbt/siri-sm/internal/clockskew"
+	"github.com/julienbt/siri-sm/internal/config"
+	"github.com/julienbt/siri-sm/internal/getstopmonitoring"
+	"github.com/julienbt/siri-sm/internal/messageid"
+	"github.com/julienbt/siri-sm/internal/siri"
+	"github.com/sirupsen/logrus"
+)
+
+type StopPointsDiscoveryRequest struct {
+	SupplierAddress   url.URL
+	Binding           siri.Binding
+	Location          *time.Location // of the supplier
+	RequestTimestamp  time.Time
+	RequestorRef      string
+	MessageIdentifier string
+}
+
+// StopPoint is a discovered stop point, `StopId` is the id used in the stop lists
+type StopPoint struct {
+	StopPointRef string
+	StopId       getstopmonitoring.StopPointRef
+	StopName     string
+	Monitored    *bool // nil when the supplier didn't flag the stop point
+	LineRefs     []string
+	Longitude    float64
+	Latitude     float64
+}
+
+// IsMonitored is true unless the stop point is flagged as not `Monitored`,
+// SIRI defaults the flag to true
+func (s StopPoint) IsMonitored() bool {
+	return s.Monitored == nil || *s.Monitored
+}
+
+func StopPointsDiscovery(
+	cfg config.ConfigCheckStatus,
+	logger *logrus.Entry,
+	requestTimestamp *time.Time,
+) ([]StopPoint, string, []byte, error) {
+	var remoteErrorLoc = "StopPointsDiscovery remote error"
+	req := StopPointsDiscoveryRequest{}
+	err := req.populate(&cfg, requestTimestamp)
+	if err != nil {
+		return nil,
+			"",
+			nil,
+			fmt.Errorf("error StopPointsDiscovery request initialization: %v", err)
+	}
+	httpReq, htmlReqBody, err := req.generateHttpReq()
+	if err != nil {
+		return nil,
+			"",
+			nil,
+			fmt.Errorf("error in building StopPointsDiscovery request: %s", err)
+	}
+
+	// Send HTTP request and receive the response
+	resp, err := siri.SoapCall(httpReq)
+	if err != nil {
+		return nil,
+			htmlReqBody,
+			nil,
+			&siri.RemoteError{Loc: remoteErrorLoc, Err: fmt.Errorf("call error: %s", err)}
+	}
+
+	// Get the HTTP response body
+	defer resp.Body.Close()
+	htmlRespBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil,
+			htmlReqBody,
+			nil,
+			&siri.RemoteError{Loc: remoteErrorLoc, Err: fmt.Errorf("unreadable response body: %s", err)}
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil,
+			htmlReqBody,
+			htmlRespBody,
+			&siri.RemoteError{Loc: remoteErrorLoc, Err: fmt.Errorf("bad http-response status: %s", resp.Status)}
+	}
+
+	// Parse the succesfull HTTP Response
+	answer := &StopPointsDiscoveryAnswer{}
+	err = req.Binding.DecodeAnswerIn(htmlRespBody, STOP_POINTS_DISCOVERY_ANSWER_PATH, answer, req.Location)
+	if err != nil {
+		return nil,
+			htmlReqBody,
+			htmlRespBody,
+			&siri.RemoteError{Loc: remoteErrorLoc, Err: fmt.Errorf("unmarshallable response body: %s", err)}
+	}
+	if !answer.Status {
+		return nil,
+			htmlReqBody,
+			htmlRespBody,
+			&siri.RemoteError{Loc: remoteErrorLoc, Err: fmt.Errorf("status not true in response body")}
+	}
+	stopPoints, err := extractStopPoints(answer, logger)
+	if err != nil {
+		return nil,
+			htmlReqBody,
+			htmlRespBody,
+			&siri.RemoteError{Loc: remoteErrorLoc, Err: err}
+	}
+	return stopPoints, htmlReqBody, htmlRespBody, nil
+}
+
+// extractStopPoints skips the stop points whose ref is not well formatted
+func extractStopPoints(answer *StopPointsDiscoveryAnswer, logger *logrus.Entry) ([]StopPoint, error) {
+	stopPoints := make([]StopPoint, 0, len(answer.AnnotatedStopPointRefs))
+	for _, annotatedStopPointRef := range answer.AnnotatedStopPointRefs {
+		stopId, err := getstopmonitoring.ParseStopPointRef(annotatedStopPointRef.StopPointRef)
+		if err != nil {
+			logger.Warn(err)
+			continue
+		}
+		stopPoints = append(stopPoints, StopPoint{
+			StopPointRef: annotatedStopPointRef.StopPointRef,
+			StopId:       stopId,
+			StopName:     annotatedStopPointRef.StopName,
+			Monitored:    annotatedStopPointRef.Monitored,
+			LineRefs:     annotatedStopPointRef.LineRefs,
+			Longitude:    annotatedStopPointRef.Location.Longitude,
+			Latitude:     annotatedStopPointRef.Location.Latitude,
+		})
+	}
+	if len(answer.AnnotatedStopPointRefs) > 0 && len(stopPoints) == 0 {
+		return nil, fmt.Errorf("no well formatted `StopPointRef` in response body")
+	}
+	return stopPoints, nil
+}
+
+func (req *StopPointsDiscoveryRequest) populate(cfg *config.ConfigCheckStatus, requestTimestamp *time.Time) error {
+	supplierAddressUrl, err := url.Parse(cfg.SupplierAddress)
+	if err != nil {
+		return fmt.Errorf("error the supplier address is not a valid URL: %s", cfg.SupplierAddress)
+	}
+	binding, err := siri.ParseBinding(cfg.Binding)
+	if err != nil {
+		return err
+	}
+	req.Binding = binding
+	location, err := cfg.Location()
+	if err != nil {
+		return err
+	}
+	req.Location = location
+	req.RequestTimestamp = clockskew.Default.Compensate(cfg.SupplierAddress, requestTimestamp.In(location))
+	req.RequestorRef = cfg.SubscriberRef
+	req.MessageIdentifier, err = messageid.New(cfg.MessageIdPattern, req.RequestorRef, "StopPointsDiscovery", req.RequestTimestamp)
+	if err != nil {
+		return err
+	}
+	req.SupplierAddress = *supplierAddressUrl
+	return nil
+}
+
+func (req *StopPointsDiscoveryRequest) generateHttpReq() (*http.Request, string, error) {
+	tmpl, err := template.ParseFiles("./template/stoppointsdiscovery-request.tmpl")
+	if err != nil {
+		return nil, "", fmt.Errorf("error parsing template: %s", err)
+	}
+	payloadBuffer := &bytes.Buffer{}
+	err = tmpl.ExecuteTemplate(payloadBuffer, req.Binding.PayloadTemplateName(), req)
+	if err != nil {
+		return nil, "", fmt.Errorf("error building template: %s", err)
+	}
+	return req.Binding.NewHttpRequest(req.SupplierAddress.String(), "StopPointsDiscovery", payloadBuffer.String())
+}
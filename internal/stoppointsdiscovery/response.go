@@ -0,0 +1,36 @@
+package stoppointsdiscovery
+
+import (
+	"encoding/xml"
+	"time"
+
+	"github.com/julienbt/siri-sm/internal/siri"
+)
+
+var STOP_POINTS_DISCOVERY_ANSWER_PATH = siri.AnswerPath{
+	Soap: []string{"StopPointsDiscoveryResponse", "Answer"},
+	Raw:  []string{"StopPointsDelivery"},
+}
+
+// StopPointsDiscoveryAnswer is the `Answer` element in SOAP, and the
+// `StopPointsDelivery` element in raw SIRI
+type StopPointsDiscoveryAnswer struct {
+	XMLName                xml.Name
+	ResponseTimestamp      time.Time               `xml:"ResponseTimestamp"`
+	Status                 bool                    `xml:"Status"`
+	AnnotatedStopPointRefs []AnnotatedStopPointRef `xml:"AnnotatedStopPointRef"`
+}
+
+type AnnotatedStopPointRef struct {
+	XMLName      xml.Name `xml:"AnnotatedStopPointRef"`
+	StopPointRef string   `xml:"StopPointRef"`
+	Monitored    *bool    `xml:"Monitored"` // optional, monitored when absent
+	StopName     string   `xml:"StopName"`
+	LineRefs     []string `xml:"Lines>LineRef"`
+	Location     Location `xml:"Location"`
+}
+
+type Location struct {
+	Longitude float64 `xml:"Longitude"`
+	Latitude  float64 `xml:"Latitude"`
+}
@@ -0,0 +1,93 @@
+package stoppointsdiscovery
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+
+	"github.com/julienbt/siri-sm/internal/siri"
+)
+
+func newBool(b bool) *bool {
+	return &b
+}
+
+var testDataDir string
+
+func TestMain(m *testing.M) {
+
+	testDataDir = os.Getenv("SIRISM_TEST_DATA_DIR")
+	if testDataDir == "" {
+		panic("$SIRISM_TEST_DATA_DIR isn't set")
+	}
+
+	os.Exit(m.Run())
+}
+
+func TestStopPointsDiscoveryAnswerDecode(t *testing.T) {
+	require := require.New(t)
+
+	htmlRespBody, err := ioutil.ReadFile(
+		fmt.Sprintf(
+			"%s/examples/SPD_RESP_000.xml",
+			testDataDir,
+		),
+	)
+	require.Nil(err)
+
+	answer := StopPointsDiscoveryAnswer{}
+	err = siri.BINDING_SOAP_11.DecodeAnswer(htmlRespBody, STOP_POINTS_DISCOVERY_ANSWER_PATH, &answer)
+	require.Nil(err)
+	require.True(answer.Status)
+	require.Len(answer.AnnotatedStopPointRefs, 3)
+
+	stopPoints, err := extractStopPoints(&answer, logrus.NewEntry(logrus.New()))
+	require.Nil(err)
+	require.Equal(
+		[]StopPoint{
+			{
+				StopPointRef: "ILEVIA:StopPoint:BP:CAS001:LOC",
+				StopId:       "CAS001",
+				StopName:     "Casino",
+				Monitored:    newBool(true),
+				LineRefs:     []string{"ILEVIA:Line:BP:L1:LOC", "ILEVIA:Line:BP:L2:LOC"},
+				Longitude:    3.0573,
+				Latitude:     50.6365,
+			},
+			{
+				StopPointRef: "ILEVIA:StopPoint:BP:CAS002:LOC",
+				StopId:       "CAS002",
+				StopName:     "Casino",
+				Monitored:    newBool(false),
+				LineRefs:     []string{"ILEVIA:Line:BP:L1:LOC"},
+			},
+		},
+		stopPoints,
+	)
+}
+
+func TestStopPointMonitoredByDefault(t *testing.T) {
+	require := require.New(t)
+
+	answer := StopPointsDiscoveryAnswer{}
+	err := siri.BINDING_RAW.DecodeAnswer([]byte(`<Siri xmlns="http://www.siri.org.uk/siri" version="2.0">
+		<StopPointsDelivery version="2.0">
+			<Status>true</Status>
+			<AnnotatedStopPointRef>
+				<StopPointRef>ILEVIA:StopPoint:BP:CAS001:LOC</StopPointRef>
+				<StopName>Casino</StopName>
+			</AnnotatedStopPointRef>
+		</StopPointsDelivery>
+	</Siri>`), STOP_POINTS_DISCOVERY_ANSWER_PATH, &answer)
+	require.Nil(err)
+	stopPoints, err := extractStopPoints(&answer, logrus.NewEntry(logrus.New()))
+	require.Nil(err)
+	require.Len(stopPoints, 1)
+	require.Nil(stopPoints[0].Monitored)
+	require.True(stopPoints[0].IsMonitored())
+	require.False(StopPoint{Monitored: newBool(false)}.IsMonitored())
+}
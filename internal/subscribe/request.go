@@ -6,21 +6,21 @@ import (
 	"io/ioutil"
 	"net/http"
 	"net/url"
+	"sort"
 	"strings"
+	"sync"
 	"text/template"
 	"time"
 
+	"github.com/julienbt/siri-sm/internal/clockskew"
 	"github.com/julienbt/siri-sm/internal/config"
+	"github.com/julienbt/siri-sm/internal/getstopmonitoring"
+	"github.com/julienbt/siri-sm/internal/messageid"
 	"github.com/julienbt/siri-sm/internal/siri"
+	"github.com/julienbt/siri-sm/internal/stoplist"
 	"github.com/sirupsen/logrus"
 )
 
-var LOCATION_NAME = "Europ/Paris"
-var LOCATION, _ = time.LoadLocation(LOCATION_NAME)
-
-var INITIAL_TERMINATION_TIME time.Time
-var REQUEST_TIMESTAMP time.Time
-
 const SUBSCRIBER_REF string = "KISIO2"
 const STOP_VISIT_TYPES string = "departures"
 const MINIMUM_STOP_VISITS_PER_LINE int = 2
@@ -28,7 +28,9 @@ const PREVIEW_INTERVAL_DURATION time.Duration = 2 * time.Hour
 const INCREMENTAL_UPDATES bool = true
 const CHANGE_BEFORE_UPDATES_DURATION time.Duration = 2 * time.Second
 
-const IDENTIFIER_TIME_LAYOUT string = "20060102_150405"
+// INITIAL_TERMINATION_DAYS are counted in the timezone of the supplier, the
+// subscriptions end at the same wall clock time, even across a DST change
+const INITIAL_TERMINATION_DAYS int = 1
 
 var STOP_POINT_IDS_LILLE_BUS = []string{
 	"CAS001",
@@ -81,28 +83,57 @@ var STOP_POINT_IDS_AMIENS = []string{
 }
 
 func Subscribe(cfg config.ConfigSubscribe, logger *logrus.Entry, requestTimestamp *time.Time) (SubscribeRequestInfoResult, string, []byte, error) {
-	var remoteErrorLoc = "Subscribe remote error"
+	return SubscribeStops(cfg, logger, requestTimestamp, nil)
+}
+
+// SubscribeStops subscribes to the StopMonitoring of the given stops instead
+// of the configured stop list, which is used without stop
+func SubscribeStops(
+	cfg config.ConfigSubscribe,
+	logger *logrus.Entry,
+	requestTimestamp *time.Time,
+	stopPointIds []string,
+) (SubscribeRequestInfoResult, string, []byte, error) {
+	if stopPointIds != nil && cfg.Service != config.SERVICE_STOP_MONITORING {
+		return SubscribeRequestInfoResult{},
+			"",
+			nil,
+			fmt.Errorf("error Subscibe request initialization: the stops are only subscribed with the StopMonitoring service")
+	}
 	req := SubscribeRequestInfo{}
-	err := req.populate(&cfg, requestTimestamp, requestTimestamp)
+	err := req.populate(&cfg, requestTimestamp, stopPointIds)
+	if err != nil {
+		return SubscribeRequestInfoResult{},
+			"",
+			nil,
+			fmt.Errorf("error Subscibe request initialization: %v", err)
+	}
+	chunks, err := req.split(cfg.ChunkSize, cfg.MessageIdPattern)
 	if err != nil {
 		return SubscribeRequestInfoResult{},
 			"",
 			nil,
 			fmt.Errorf("error Subscibe request initialization: %v", err)
 	}
+	if len(chunks) == 1 {
+		return chunks[0].subscribe(&cfg, logger)
+	}
+	return subscribeChunks(&cfg, logger, chunks)
+}
 
-	httpReq, htmlReqBody, err := req.generateHttpSoapReq()
-	// Check/parse the HTTP Response
+// subscribe sends the requests in one Subscribe call
+func (req *SubscribeRequestInfo) subscribe(cfg *config.ConfigSubscribe, logger *logrus.Entry) (SubscribeRequestInfoResult, string, []byte, error) {
+	var remoteErrorLoc = "Subscribe remote error"
+	httpReq, htmlReqBody, err := req.generateHttpReq()
 	if err != nil {
-		if err != nil {
-			return SubscribeRequestInfoResult{},
-				"",
-				nil,
-				fmt.Errorf("error in building SOAP Subscribe request: %s", err)
-		}
+		return SubscribeRequestInfoResult{},
+			"",
+			nil,
+			fmt.Errorf("error in building Subscribe request: %s", err)
 	}
 
 	// Send HTTP request and receive the response
+	sentAt := time.Now()
 	resp, err := siri.SoapCall(httpReq)
 	if err != nil {
 		return SubscribeRequestInfoResult{},
@@ -120,6 +151,7 @@ func Subscribe(cfg config.ConfigSubscribe, logger *logrus.Entry, requestTimestam
 			nil,
 			&siri.RemoteError{Loc: remoteErrorLoc, Err: fmt.Errorf("unreadable response body: %s", err)}
 	}
+	receivedAt := time.Now()
 
 	// Check HTTP status code
 	if resp.StatusCode < 200 || resp.StatusCode > 299 {
@@ -129,57 +161,336 @@ func Subscribe(cfg config.ConfigSubscribe, logger *logrus.Entry, requestTimestam
 			&siri.RemoteError{Loc: remoteErrorLoc, Err: fmt.Errorf("bad http-response status: %s", resp.Status)}
 	}
 
-	return SubscribeRequestInfoResult{}, htmlReqBody, htmlRespBody, nil
+	// Parse the succesfull HTTP Response
+	subscribeAnswer := &SubscribeAnswer{}
+	err = req.Binding.DecodeAnswerIn(htmlRespBody, SUBSCRIBE_ANSWER_PATH, subscribeAnswer, req.Location)
+	if err != nil {
+		return SubscribeRequestInfoResult{},
+			htmlReqBody,
+			htmlRespBody,
+			&siri.RemoteError{Loc: remoteErrorLoc, Err: fmt.Errorf("unmarshallable response body: %s", err)}
+	}
+	clockskew.Default.Record(logger, cfg.SupplierAddress, sentAt, receivedAt, subscribeAnswer.ResponseTimestamp())
+	result := SubscribeRequestInfoResult{
+		ResponseStatus:              subscribeAnswer.ResponseStatus,
+		UnmatchedRequestMessageRefs: req.correlate(subscribeAnswer.ResponseStatus),
+		Targets:                     req.targets(),
+	}
+	if len(result.UnmatchedRequestMessageRefs) > 0 {
+		logger.WithFields(logrus.Fields{
+			"messageIdentifier": req.MessageIdentifier,
+			"requestMessageRef": result.UnmatchedRequestMessageRefs[0],
+		}).Warnf("%d statuses of the Subscribe answer refer to none of the requests", len(result.UnmatchedRequestMessageRefs))
+	}
+	return result, htmlReqBody, htmlRespBody, nil
 }
 
 type SubscribeRequestInfo struct {
 	SupplierAddress   url.URL
+	Binding           siri.Binding
+	Location          *time.Location // of the supplier
 	RequestTimestamp  time.Time
+	MessageIdentifier string
 	SubscriberRef     string
 	ConsumerAddress   string
 	SubscribeRequests []SubscribeRequest
+	// The requests of the other services replace the `SubscribeRequests`
+	EstimatedTimetableSubscribeRequests []LineSubscribeRequest
+	VehicleMonitoringSubscribeRequests  []LineSubscribeRequest
+	GeneralMessageSubscribeRequests     []LineSubscribeRequest
+	SituationExchangeSubscribeRequests  []LineSubscribeRequest
 }
 
-type SubscribeRequestInfoResult struct{}
+type SubscribeRequestInfoResult struct {
+	ResponseStatus []ResponseStatus
+	// UnmatchedRequestMessageRefs are the `RequestMessageRef` of the statuses
+	// referring to none of the requests sent
+	UnmatchedRequestMessageRefs []string
+	// Targets are what the requested subscriptions monitor, by subscription
+	// identifier
+	Targets map[string]Target
+}
 
-func (req *SubscribeRequestInfo) populate(
-	cfg *config.ConfigSubscribe,
-	requestTimestamp *time.Time,
-	initialTerminationTime *time.Time) error {
+// Target is the stop and the line of a subscription, the line only for the
+// services filtered by line
+type Target struct {
+	MonitoringRef string
+	LineRef       string
+}
+
+func (req *SubscribeRequestInfo) populate(cfg *config.ConfigSubscribe, requestTimestamp *time.Time, stopPointIds []string) error {
 	supplierAddressUrl, err := url.Parse(cfg.SupplierAddress)
 	if err != nil {
 		return fmt.Errorf("error the supplier address is not a valid URL: %s", cfg.SupplierAddress)
 	}
+	binding, err := siri.ParseBinding(cfg.Binding)
+	if err != nil {
+		return err
+	}
 	req.SupplierAddress = *supplierAddressUrl
-	req.RequestTimestamp = *requestTimestamp
+	req.Binding = binding
+	location, err := cfg.Location()
+	if err != nil {
+		return err
+	}
+	req.Location = location
+	req.RequestTimestamp = clockskew.Default.Compensate(cfg.SupplierAddress, requestTimestamp.In(location))
+	initialTerminationTime := req.RequestTimestamp.AddDate(0, 0, INITIAL_TERMINATION_DAYS)
+	req.MessageIdentifier, err = messageid.New(cfg.MessageIdPattern, cfg.SubscriberRef, "SubscriptionRequest", req.RequestTimestamp)
+	if err != nil {
+		return err
+	}
 	req.SubscriberRef = cfg.SubscriberRef
 	req.ConsumerAddress = cfg.ConsumerAddress
-	req.SubscribeRequests = initSubscribeRequests(cfg, requestTimestamp, initialTerminationTime)
+	switch cfg.Service {
+	case config.SERVICE_STOP_MONITORING:
+	case config.SERVICE_ESTIMATED_TIMETABLE:
+		req.EstimatedTimetableSubscribeRequests, err = initLineSubscribeRequests(cfg, "ET", &req.RequestTimestamp, &initialTerminationTime)
+		return err
+	case config.SERVICE_VEHICLE_MONITORING:
+		req.VehicleMonitoringSubscribeRequests, err = initLineSubscribeRequests(cfg, "VM", &req.RequestTimestamp, &initialTerminationTime)
+		return err
+	case config.SERVICE_GENERAL_MESSAGE:
+		if len(cfg.LineRefs) > 0 {
+			return fmt.Errorf("the GeneralMessage subscription can't be filtered by `LineRefs`")
+		}
+		req.GeneralMessageSubscribeRequests, err = initLineSubscribeRequests(cfg, "GM", &req.RequestTimestamp, &initialTerminationTime)
+		return err
+	case config.SERVICE_SITUATION_EXCHANGE:
+		req.SituationExchangeSubscribeRequests, err = initLineSubscribeRequests(cfg, "SX", &req.RequestTimestamp, &initialTerminationTime)
+		return err
+	default:
+		return fmt.Errorf("unknown subscription service: %s", cfg.Service)
+	}
+	if stopPointIds == nil {
+		stopPointIds, err = StopPointIds(cfg)
+		if err != nil {
+			return err
+		}
+	}
+	req.SubscribeRequests, err = initSubscribeRequests(cfg, stopPointIds, &req.RequestTimestamp, &initialTerminationTime)
+	if err != nil {
+		return err
+	}
 	return nil
 }
 
-func (req *SubscribeRequestInfo) generateHttpSoapReq() (*http.Request, string, error) {
+// StopPointIds are the stops of the `StopListFile`, the Lille bus stops
+// without file
+func StopPointIds(cfg *config.ConfigSubscribe) ([]string, error) {
+	if cfg.StopListFile == "" {
+		return STOP_POINT_IDS_LILLE_BUS, nil
+	}
+	return stoplist.ReadFile(cfg.StopListFile)
+}
+
+// subscribeChunks sends the chunks in concurrent Subscribe calls, at most
+// `ChunkConcurrency` at once, and merges their statuses: the subscriptions of
+// a failed chunk are rejected with the error, which is only returned when
+// every chunk failed
+func subscribeChunks(
+	cfg *config.ConfigSubscribe,
+	logger *logrus.Entry,
+	chunks []SubscribeRequestInfo,
+) (SubscribeRequestInfoResult, string, []byte, error) {
+	type outcome struct {
+		result       SubscribeRequestInfoResult
+		htmlReqBody  string
+		htmlRespBody []byte
+		err          error
+	}
+	concurrency := cfg.ChunkConcurrency
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	outcomes := make([]outcome, len(chunks))
+	semaphore := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i := range chunks {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+			chunkLogger := logger.WithField("chunk", fmt.Sprintf("%d/%d", i+1, len(chunks)))
+			o := &outcomes[i]
+			o.result, o.htmlReqBody, o.htmlRespBody, o.err = chunks[i].subscribe(cfg, chunkLogger)
+		}(i)
+	}
+	wg.Wait()
+
+	result := SubscribeRequestInfoResult{
+		ResponseStatus:              make([]ResponseStatus, 0, chunks[0].count()*len(chunks)),
+		UnmatchedRequestMessageRefs: make([]string, 0),
+		Targets:                     make(map[string]Target, chunks[0].count()*len(chunks)),
+	}
+	htmlReqBodies := make([]string, 0, len(chunks))
+	htmlRespBodies := make([][]byte, 0, len(chunks))
+	var firstErr error
+	failed := 0
+	for i, o := range outcomes {
+		htmlReqBodies = append(htmlReqBodies, o.htmlReqBody)
+		htmlRespBodies = append(htmlRespBodies, o.htmlRespBody)
+		for subscriptionIdentifier, target := range chunks[i].targets() {
+			result.Targets[subscriptionIdentifier] = target
+		}
+		if o.err != nil {
+			logger.WithField("chunk", fmt.Sprintf("%d/%d", i+1, len(chunks))).Warnf(
+				"the %d subscriptions of the chunk are rejected: %s", chunks[i].count(), o.err)
+			if firstErr == nil {
+				firstErr = o.err
+			}
+			failed++
+			result.ResponseStatus = append(result.ResponseStatus, chunks[i].rejectedStatuses(o.err)...)
+			continue
+		}
+		result.ResponseStatus = append(result.ResponseStatus, o.result.ResponseStatus...)
+		result.UnmatchedRequestMessageRefs = append(result.UnmatchedRequestMessageRefs, o.result.UnmatchedRequestMessageRefs...)
+	}
+	htmlReqBody := strings.Join(htmlReqBodies, "\n")
+	htmlRespBody := bytes.Join(htmlRespBodies, []byte("\n"))
+	if failed == len(chunks) {
+		return SubscribeRequestInfoResult{}, htmlReqBody, htmlRespBody, firstErr
+	}
+	return result, htmlReqBody, htmlRespBody, nil
+}
+
+// count is the number of subscriptions requested
+func (req *SubscribeRequestInfo) count() int {
+	return len(req.SubscribeRequests) +
+		len(req.EstimatedTimetableSubscribeRequests) +
+		len(req.VehicleMonitoringSubscribeRequests) +
+		len(req.GeneralMessageSubscribeRequests) +
+		len(req.SituationExchangeSubscribeRequests)
+}
+
+// split returns chunks of at most `size` subscriptions, each with its own
+// message identifier, the request itself when it isn't larger
+func (req *SubscribeRequestInfo) split(size int, pattern string) ([]SubscribeRequestInfo, error) {
+	count := req.count()
+	if size <= 0 || count <= size {
+		return []SubscribeRequestInfo{*req}, nil
+	}
+	chunks := make([]SubscribeRequestInfo, 0, (count+size-1)/size)
+	for start := 0; start < count; start += size {
+		end := start + size
+		if end > count {
+			end = count
+		}
+		chunk := *req
+		if len(chunks) > 0 {
+			messageIdentifier, err := messageid.New(pattern, req.SubscriberRef, "SubscriptionRequest", req.RequestTimestamp)
+			if err != nil {
+				return nil, err
+			}
+			chunk.MessageIdentifier = messageIdentifier
+		}
+		// Only the requests of the subscribed service are set
+		if len(req.SubscribeRequests) > 0 {
+			chunk.SubscribeRequests = req.SubscribeRequests[start:end]
+		}
+		chunk.EstimatedTimetableSubscribeRequests = lineChunk(req.EstimatedTimetableSubscribeRequests, start, end)
+		chunk.VehicleMonitoringSubscribeRequests = lineChunk(req.VehicleMonitoringSubscribeRequests, start, end)
+		chunk.GeneralMessageSubscribeRequests = lineChunk(req.GeneralMessageSubscribeRequests, start, end)
+		chunk.SituationExchangeSubscribeRequests = lineChunk(req.SituationExchangeSubscribeRequests, start, end)
+		chunks = append(chunks, chunk)
+	}
+	return chunks, nil
+}
+
+func lineChunk(requests []LineSubscribeRequest, start int, end int) []LineSubscribeRequest {
+	if len(requests) == 0 {
+		return requests
+	}
+	return requests[start:end]
+}
+
+// subscriptionIdentifiers are the identifiers of the subscriptions
+// requested, by message identifier of their request
+func (req *SubscribeRequestInfo) subscriptionIdentifiers() map[string]string {
+	identifiers := make(map[string]string, req.count())
+	for _, r := range req.SubscribeRequests {
+		identifiers[r.MessageIdentifier] = r.SubscriptionIdentifier
+	}
+	for _, requests := range [][]LineSubscribeRequest{
+		req.EstimatedTimetableSubscribeRequests,
+		req.VehicleMonitoringSubscribeRequests,
+		req.GeneralMessageSubscribeRequests,
+		req.SituationExchangeSubscribeRequests,
+	} {
+		for _, r := range requests {
+			identifiers[r.MessageIdentifier] = r.SubscriptionIdentifier
+		}
+	}
+	return identifiers
+}
+
+func (req *SubscribeRequestInfo) targets() map[string]Target {
+	targets := make(map[string]Target, req.count())
+	for _, r := range req.SubscribeRequests {
+		targets[r.SubscriptionIdentifier] = Target{MonitoringRef: r.MonitoringRef, LineRef: r.LineRef}
+	}
+	for _, requests := range [][]LineSubscribeRequest{
+		req.EstimatedTimetableSubscribeRequests,
+		req.VehicleMonitoringSubscribeRequests,
+		req.GeneralMessageSubscribeRequests,
+		req.SituationExchangeSubscribeRequests,
+	} {
+		for _, r := range requests {
+			targets[r.SubscriptionIdentifier] = Target{LineRef: r.LineRef}
+		}
+	}
+	return targets
+}
+
+// rejectedStatuses are the statuses of the subscriptions of a failed call
+func (req *SubscribeRequestInfo) rejectedStatuses(err error) []ResponseStatus {
+	statuses := make([]ResponseStatus, 0, req.count())
+	for messageIdentifier, subscriptionIdentifier := range req.subscriptionIdentifiers() {
+		statuses = append(statuses, ResponseStatus{
+			RequestMessageRef: messageIdentifier,
+			SubscriberRef:     req.SubscriberRef,
+			SubscriptionRef:   subscriptionIdentifier,
+			Status:            false,
+			ErrorText:         err.Error(),
+		})
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].SubscriptionRef < statuses[j].SubscriptionRef })
+	return statuses
+}
+
+// correlate returns the `RequestMessageRef` of the statuses which are neither
+// the message identifier nor the subscription identifier of a request
+func (req *SubscribeRequestInfo) correlate(statuses []ResponseStatus) []string {
+	correlator := messageid.NewCorrelator()
+	correlator.Add(req.MessageIdentifier, "")
+	for messageIdentifier, subscriptionIdentifier := range req.subscriptionIdentifiers() {
+		correlator.Add(messageIdentifier, subscriptionIdentifier)
+	}
+	unmatched := make([]string, 0)
+	for _, status := range statuses {
+		if status.RequestMessageRef == "" {
+			continue
+		}
+		if _, ok := correlator.Match(status.RequestMessageRef); !ok {
+			unmatched = append(unmatched, status.RequestMessageRef)
+		}
+	}
+	return unmatched
+}
+
+func (req *SubscribeRequestInfo) generateHttpReq() (*http.Request, string, error) {
 	tmpl, err := template.ParseFiles("./template/subscription-request.tmpl")
 	if err != nil {
 		return nil, "", fmt.Errorf("error parsing template: %s", err)
 	}
 
-	httpReqBodyBuffer := &bytes.Buffer{}
-	err = tmpl.Execute(httpReqBodyBuffer, req)
+	payloadBuffer := &bytes.Buffer{}
+	err = tmpl.ExecuteTemplate(payloadBuffer, req.Binding.PayloadTemplateName(), req)
 	if err != nil {
 		return nil, "", fmt.Errorf("error building template: %s", err)
 	}
-	httpReqBody := httpReqBodyBuffer.String()
-	httpReq, err := http.NewRequest(http.MethodPost, req.SupplierAddress.String(), strings.NewReader(httpReqBody))
-	headers := http.Header{
-		"Content-Type": []string{"text/xml; charset=utf-8"},
-		"SOAPAction":   []string{"Subscribe"},
-	}
-	httpReq.Header = headers // better than .Header.Set to preserve case (for "SOAPAction")
-	if err != nil {
-		return nil, "", fmt.Errorf("error building http-request: %s", err)
-	}
-	return httpReq, httpReqBody, nil
+	return req.Binding.NewHttpRequest(req.SupplierAddress.String(), "Subscribe", payloadBuffer.String())
 }
 
 type SubscribeRequest struct {
@@ -190,35 +501,129 @@ type SubscribeRequest struct {
 	MessageIdentifier        string
 	PreviewInterval          string
 	MonitoringRef            string
+	LineRef                  string
 	StopVisitTypes           string
 	MinimumStopVisitsPerLine int
 	IncrementalUpdates       bool
 	ChangeBeforeUpdates      string
 }
 
+// initSubscribeRequests builds one request per stop, or one request per stop
+// and line when `LineRefs` are configured
 func initSubscribeRequests(
 	cfg *config.ConfigSubscribe,
+	stopPointIds []string,
 	requestTimestamp *time.Time,
 	initialTerminationTime *time.Time,
-) []SubscribeRequest {
-	numberOfSubascibeRequests := len(STOP_POINT_IDS_LILLE_BUS)
+) ([]SubscribeRequest, error) {
+	lineRefs := []string{""}
+	if len(cfg.LineRefs) > 0 {
+		lineRefs = cfg.LineRefs
+	}
+	numberOfSubascibeRequests := len(stopPointIds) * len(lineRefs)
 	requests := make([]SubscribeRequest, 0, numberOfSubascibeRequests)
-	for _, stop_point_id := range STOP_POINT_IDS_LILLE_BUS {
-		req := SubscribeRequest{}
+	for _, stop_point_id := range stopPointIds {
+		for _, lineRef := range lineRefs {
+			subscriptionIdentifier, err := SubscriptionIdentifier(cfg.SubscriberRef, stop_point_id, lineRef)
+			if err != nil {
+				return nil, err
+			}
+			messageIdentifier, err := messageid.New(cfg.MessageIdPattern, cfg.SubscriberRef, "Message", *requestTimestamp)
+			if err != nil {
+				return nil, err
+			}
+			req := SubscribeRequest{}
+			req.SubscriberRef = cfg.SubscriberRef
+			req.SubscriptionIdentifier = subscriptionIdentifier
+			req.InitialTerminationTime = *initialTerminationTime
+			req.PreviewInterval = "PT2H0M0.000S"
+			req.RequestTimestamp = *requestTimestamp
+			req.MessageIdentifier = messageIdentifier
+			req.MonitoringRef = MonitoringRef(cfg.ProducerRef, stop_point_id)
+			req.LineRef = lineRef
+			req.StopVisitTypes = STOP_VISIT_TYPES
+			req.MinimumStopVisitsPerLine = MINIMUM_STOP_VISITS_PER_LINE
+			req.IncrementalUpdates = INCREMENTAL_UPDATES
+			req.ChangeBeforeUpdates = "PT0M30.000S"
+			requests = append(requests, req)
+		}
+	}
+	return requests, nil
+}
+
+// SubscriptionIdentifier is the identifier of the StopMonitoring
+// subscription of a stop, and of a line when set
+func SubscriptionIdentifier(subscriberRef string, stopPointId string, lineRef string) (string, error) {
+	subscriptionName := "arret_" + stopPointId
+	if lineRef != "" {
+		lineId, err := getstopmonitoring.ParseLineRef(lineRef)
+		if err != nil {
+			return "", err
+		}
+		subscriptionName += "_ligne_" + string(lineId)
+	}
+	return subscriberRef + ":Subscription:" + subscriptionName + ":LOC", nil
+}
+
+// MonitoringRef is the `MonitoringRef` of a stop of the producer
+func MonitoringRef(producerRef string, stopPointId string) string {
+	return producerRef + ":StopPoint:BP:" + stopPointId + ":LOC"
+}
+
+// LineSubscribeRequest is the subscription request of the services
+// filtered by line (EstimatedTimetable, VehicleMonitoring, GeneralMessage
+// and SituationExchange)
+type LineSubscribeRequest struct {
+	SubscriberRef          string
+	SubscriptionIdentifier string
+	InitialTerminationTime time.Time
+	RequestTimestamp       time.Time
+	MessageIdentifier      string
+	PreviewInterval        string
+	LineRef                string
+	IncrementalUpdates     bool
+	ChangeBeforeUpdates    string
+}
+
+// initLineSubscribeRequests builds one request per line, or a single
+// request for the whole network without `LineRefs`
+func initLineSubscribeRequests(
+	cfg *config.ConfigSubscribe,
+	serviceName string,
+	requestTimestamp *time.Time,
+	initialTerminationTime *time.Time,
+) ([]LineSubscribeRequest, error) {
+	lineRefs := []string{""}
+	if len(cfg.LineRefs) > 0 {
+		lineRefs = cfg.LineRefs
+	}
+	requests := make([]LineSubscribeRequest, 0, len(lineRefs))
+	for _, lineRef := range lineRefs {
+		subscriptionName := "reseau"
+		if lineRef != "" {
+			lineId, err := getstopmonitoring.ParseLineRef(lineRef)
+			if err != nil {
+				return nil, err
+			}
+			subscriptionName = "ligne_" + string(lineId)
+		}
+		messageIdentifier, err := messageid.New(cfg.MessageIdPattern, cfg.SubscriberRef, "Message", *requestTimestamp)
+		if err != nil {
+			return nil, err
+		}
+		req := LineSubscribeRequest{}
 		req.SubscriberRef = cfg.SubscriberRef
-		req.SubscriptionIdentifier = cfg.SubscriberRef + ":Subscription:" + "arret_" + stop_point_id + ":LOC"
-		req.InitialTerminationTime = requestTimestamp.AddDate(0, 0, 1)
-		req.PreviewInterval = "PT2H0M0.000S"
+		req.SubscriptionIdentifier = cfg.SubscriberRef + ":Subscription:" + serviceName + ":" + subscriptionName + ":LOC"
+		req.InitialTerminationTime = *initialTerminationTime
 		req.RequestTimestamp = *requestTimestamp
-		req.MessageIdentifier = cfg.SubscriberRef + ":Message:" + requestTimestamp.Format(IDENTIFIER_TIME_LAYOUT)
-		req.MonitoringRef = cfg.ProducerRef + ":StopPoint:BP:" + stop_point_id + ":LOC"
-		req.StopVisitTypes = STOP_VISIT_TYPES
-		req.MinimumStopVisitsPerLine = MINIMUM_STOP_VISITS_PER_LINE
+		req.MessageIdentifier = messageIdentifier
+		req.PreviewInterval = "PT2H0M0.000S"
+		req.LineRef = lineRef
 		req.IncrementalUpdates = INCREMENTAL_UPDATES
 		req.ChangeBeforeUpdates = "PT0M30.000S"
 		requests = append(requests, req)
 	}
-	return requests
+	return requests, nil
 }
 
 type Duration time.Duration
@@ -0,0 +1,195 @@
+package subscribe
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	siri_time "github.com/julienbt/siri-sm/internal/common/time"
+	"github.com/julienbt/siri-sm/internal/config"
+)
+
+func TestInitLineSubscribeRequests(t *testing.T) {
+	require := require.New(t)
+
+	requestTimestamp := time.Date(2022, 5, 25, 10, 0, 0, 0, time.UTC)
+	cfg := config.ConfigSubscribe{
+		SubscriberRef: "KISIO2",
+		ProducerRef:   "ILEVIA",
+		Service:       config.SERVICE_ESTIMATED_TIMETABLE,
+	}
+
+	requests, err := initLineSubscribeRequests(&cfg, "ET", &requestTimestamp, &requestTimestamp)
+	require.Nil(err)
+	require.Len(requests, 1)
+	require.Equal("KISIO2:Subscription:ET:reseau:LOC", requests[0].SubscriptionIdentifier)
+	require.Equal("", requests[0].LineRef)
+
+	cfg.LineRefs = []string{"ILEVIA:Line:BP:L1:LOC", "ILEVIA:Line:BP:L2:LOC"}
+	requests, err = initLineSubscribeRequests(&cfg, "ET", &requestTimestamp, &requestTimestamp)
+	require.Nil(err)
+	require.Len(requests, 2)
+	require.Equal("KISIO2:Subscription:ET:ligne_L2:LOC", requests[1].SubscriptionIdentifier)
+	require.Equal("ILEVIA:Line:BP:L2:LOC", requests[1].LineRef)
+
+	requests, err = initLineSubscribeRequests(&cfg, "VM", &requestTimestamp, &requestTimestamp)
+	require.Nil(err)
+	require.Equal("KISIO2:Subscription:VM:ligne_L1:LOC", requests[0].SubscriptionIdentifier)
+
+	cfg.LineRefs = []string{"L1"}
+	_, err = initLineSubscribeRequests(&cfg, "ET", &requestTimestamp, &requestTimestamp)
+	require.NotNil(err)
+}
+
+func TestPopulateAcrossDst(t *testing.T) {
+	for _, tc := range []struct {
+		name                    string
+		requestTimestamp        time.Time
+		timezone                string
+		expectedTimestamp       string
+		expectedTerminationTime string
+		expectedDuration        time.Duration
+	}{
+		{
+			name:                    "spring forward",
+			requestTimestamp:        time.Date(2022, 3, 26, 9, 30, 0, 125_000_000, time.UTC),
+			timezone:                "Europe/Paris",
+			expectedTimestamp:       "2022-03-26T10:30:00.125+01:00",
+			expectedTerminationTime: "2022-03-27T10:30:00.125+02:00",
+			expectedDuration:        23 * time.Hour,
+		},
+		{
+			name:                    "fall back",
+			requestTimestamp:        time.Date(2022, 10, 29, 8, 30, 0, 0, time.UTC),
+			timezone:                "Europe/Paris",
+			expectedTimestamp:       "2022-10-29T10:30:00.000+02:00",
+			expectedTerminationTime: "2022-10-30T10:30:00.000+01:00",
+			expectedDuration:        25 * time.Hour,
+		},
+		{
+			name:                    "other timezone",
+			requestTimestamp:        time.Date(2022, 3, 12, 20, 0, 0, 0, time.UTC),
+			timezone:                "America/Montreal",
+			expectedTimestamp:       "2022-03-12T15:00:00.000-05:00",
+			expectedTerminationTime: "2022-03-13T15:00:00.000-04:00",
+			expectedDuration:        23 * time.Hour,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			require := require.New(t)
+			cfg := config.ConfigSubscribe{
+				SupplierAddress: "http://localhost:8090",
+				SubscriberRef:   "KISIO2",
+				ProducerRef:     "ILEVIA",
+				ConsumerAddress: "http://localhost:8080",
+				Binding:         "soap11",
+				Service:         config.SERVICE_STOP_MONITORING,
+				Timezone:        tc.timezone,
+			}
+
+			req := SubscribeRequestInfo{}
+			err := req.populate(&cfg, &tc.requestTimestamp, nil)
+			require.Nil(err)
+			require.Equal(tc.expectedTimestamp, req.RequestTimestamp.Format(siri_time.LAYOUT))
+			subscribeRequest := req.SubscribeRequests[0]
+			require.Equal(tc.expectedTerminationTime, subscribeRequest.InitialTerminationTime.Format(siri_time.LAYOUT))
+			require.Equal(tc.expectedDuration, subscribeRequest.InitialTerminationTime.Sub(subscribeRequest.RequestTimestamp))
+
+			_, htmlReqBody, err := req.generateHttpReq()
+			require.Nil(err)
+			require.Contains(htmlReqBody, "<RequestTimestamp>"+tc.expectedTimestamp+"</RequestTimestamp>")
+			require.Contains(htmlReqBody, "<InitialTerminationTime>"+tc.expectedTerminationTime+"</InitialTerminationTime>")
+		})
+	}
+}
+
+func TestPopulateUnknownTimezone(t *testing.T) {
+	requestTimestamp := time.Now()
+	cfg := config.ConfigSubscribe{
+		SupplierAddress: "http://localhost:8090",
+		Binding:         "soap11",
+		Service:         config.SERVICE_STOP_MONITORING,
+		Timezone:        "Europ/Paris",
+	}
+	req := SubscribeRequestInfo{}
+	require.NotNil(t, req.populate(&cfg, &requestTimestamp, nil))
+}
+
+func TestMessageIdentifiersAreUnique(t *testing.T) {
+	require := require.New(t)
+	requestTimestamp := time.Date(2022, 8, 30, 8, 0, 0, 0, time.UTC)
+	cfg := config.ConfigSubscribe{
+		SupplierAddress: "http://localhost:8090",
+		SubscriberRef:   "KISIO2",
+		ProducerRef:     "ILEVIA",
+		ConsumerAddress: "http://localhost:8080",
+		Binding:         "soap11",
+		Service:         config.SERVICE_STOP_MONITORING,
+		LineRefs:        []string{"ILEVIA:Line:BP:L1:LOC", "ILEVIA:Line:BP:L2:LOC"},
+	}
+
+	req := SubscribeRequestInfo{}
+	require.Nil(req.populate(&cfg, &requestTimestamp, nil))
+	require.Len(req.SubscribeRequests, 2)
+	identifiers := map[string]bool{req.MessageIdentifier: true}
+	for _, subscribeRequest := range req.SubscribeRequests {
+		require.False(identifiers[subscribeRequest.MessageIdentifier], subscribeRequest.MessageIdentifier)
+		identifiers[subscribeRequest.MessageIdentifier] = true
+	}
+	_, htmlReqBody, err := req.generateHttpReq()
+	require.Nil(err)
+	require.Contains(htmlReqBody, "<MessageIdentifier>"+req.MessageIdentifier+"</MessageIdentifier>")
+
+	unmatched := req.correlate([]ResponseStatus{
+		{RequestMessageRef: req.SubscribeRequests[0].MessageIdentifier},
+		{RequestMessageRef: req.SubscribeRequests[1].SubscriptionIdentifier},
+		{RequestMessageRef: req.MessageIdentifier},
+		{RequestMessageRef: ""},
+		{RequestMessageRef: "SUBREQ"},
+	})
+	require.Equal([]string{"SUBREQ"}, unmatched)
+
+	cfg.MessageIdPattern = "{requestor}:{timestamp}"
+	require.NotNil(req.populate(&cfg, &requestTimestamp, nil))
+}
+
+func TestSplit(t *testing.T) {
+	require := require.New(t)
+	requestTimestamp := time.Date(2022, 8, 30, 8, 0, 0, 0, time.UTC)
+	cfg := config.ConfigSubscribe{
+		SupplierAddress: "http://localhost:8090",
+		SubscriberRef:   "KISIO2",
+		ProducerRef:     "ILEVIA",
+		ConsumerAddress: "http://localhost:8080",
+		Binding:         "soap11",
+		Service:         config.SERVICE_STOP_MONITORING,
+		LineRefs:        []string{"ILEVIA:Line:BP:L1:LOC", "ILEVIA:Line:BP:L2:LOC", "ILEVIA:Line:BP:L3:LOC"},
+	}
+	req := SubscribeRequestInfo{}
+	require.Nil(req.populate(&cfg, &requestTimestamp, nil))
+
+	chunks, err := req.split(0, "")
+	require.Nil(err)
+	require.Len(chunks, 1)
+	chunks, err = req.split(3, "")
+	require.Nil(err)
+	require.Len(chunks, 1)
+
+	chunks, err = req.split(2, "")
+	require.Nil(err)
+	require.Len(chunks, 2)
+	require.Len(chunks[0].SubscribeRequests, 2)
+	require.Len(chunks[1].SubscribeRequests, 1)
+	require.Nil(chunks[1].EstimatedTimetableSubscribeRequests)
+	require.Equal(req.MessageIdentifier, chunks[0].MessageIdentifier)
+	require.NotEqual(chunks[0].MessageIdentifier, chunks[1].MessageIdentifier)
+	require.Equal(req.SubscribeRequests[2], chunks[1].SubscribeRequests[0])
+
+	statuses := chunks[1].rejectedStatuses(fmt.Errorf("bad http-response status: 500"))
+	require.Len(statuses, 1)
+	require.False(statuses[0].Status)
+	require.Equal(req.SubscribeRequests[2].SubscriptionIdentifier, statuses[0].SubscriptionRef)
+	require.Equal("bad http-response status: 500", statuses[0].ErrorText)
+}
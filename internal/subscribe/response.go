@@ -2,10 +2,17 @@ package subscribe
 
 import (
 	"encoding/xml"
+	"time"
 
 	siri_time "github.com/julienbt/siri-sm/internal/common/time"
+	"github.com/julienbt/siri-sm/internal/siri"
 )
 
+var SUBSCRIBE_ANSWER_PATH = siri.AnswerPath{
+	Soap: []string{"SubscribeResponse", "Answer"},
+	Raw:  []string{"SubscriptionResponse"},
+}
+
 type SubscribeEnv struct {
 	XMLName           xml.Name          `xml:"Envelope"`
 	SubscribeResponse SubscribeResponse `xml:"Body>SubscribeResponse"`
@@ -16,6 +23,24 @@ type SubscribeResponse struct {
 	ResponseStatus []ResponseStatus `xml:"Answer>ResponseStatus"`
 }
 
+// SubscribeAnswer is the `Answer` element in SOAP, and the
+// `SubscriptionResponse` element in raw SIRI
+type SubscribeAnswer struct {
+	XMLName        xml.Name
+	ResponseStatus []ResponseStatus `xml:"ResponseStatus"`
+}
+
+// ResponseTimestamp is the first `ResponseTimestamp` of the statuses, the
+// zero time without status
+func (a *SubscribeAnswer) ResponseTimestamp() time.Time {
+	for _, status := range a.ResponseStatus {
+		if !time.Time(status.ResponseTimestamp).IsZero() {
+			return time.Time(status.ResponseTimestamp)
+		}
+	}
+	return time.Time{}
+}
+
 type ResponseStatus struct {
 	XMLName           xml.Name       `xml:"ResponseStatus"`
 	ResponseTimestamp siri_time.Time `xml:"ResponseTimestamp"`
@@ -24,4 +49,5 @@ type ResponseStatus struct {
 	SubscriptionRef   string         `xml:"SubscriptionRef"`
 	Status            bool           `xml:"Status"`
 	ValidUntil        siri_time.Time `xml:"ValidUntil"`
+	ErrorText         string         `xml:"ErrorCondition>ErrorText"`
 }
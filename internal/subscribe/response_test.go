@@ -5,10 +5,12 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
 	siri_time "github.com/julienbt/siri-sm/internal/common/time"
+	"github.com/julienbt/siri-sm/internal/siri"
 	"github.com/stretchr/testify/require"
 )
 
@@ -24,6 +26,11 @@ func TestMain(m *testing.M) {
 	if testDataDir == "" {
 		panic("$SIRISM_TEST_DATA_DIR isn't set")
 	}
+	// The requests are built from the templates of `./template`
+	err := os.Chdir(filepath.Join(testDataDir, ".."))
+	if err != nil {
+		panic(err)
+	}
 
 	os.Exit(m.Run())
 }
@@ -114,3 +121,21 @@ func TestSubscribeResponseXmlUnmarshal(t *testing.T) {
 		)
 	}
 }
+
+func TestSubscribeAnswerDecode(t *testing.T) {
+	require := require.New(t)
+
+	htmlRespBody, err := ioutil.ReadFile(
+		fmt.Sprintf(
+			"%s/examples/SUB_RESP_000.xml",
+			testDataDir,
+		),
+	)
+	require.Nil(err)
+
+	subscribeAnswer := SubscribeAnswer{}
+	err = siri.BINDING_SOAP_11.DecodeAnswer(htmlRespBody, SUBSCRIBE_ANSWER_PATH, &subscribeAnswer)
+	require.Nil(err)
+	require.Len(subscribeAnswer.ResponseStatus, 50)
+	require.Equal("SUBHOR_ILEVIA:StopPoint:BP:11N001:LOC", subscribeAnswer.ResponseStatus[0].SubscriptionRef)
+}
@@ -0,0 +1,377 @@
+// Package subscriptionstate persists the subscriptions sent to the suppliers
+// in a bbolt file, the active ones are found back after a restart to renew
+// and reconcile them
+package subscriptionstate
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/julienbt/siri-sm/internal/reconcile"
+	"github.com/julienbt/siri-sm/internal/subscribe"
+	"github.com/julienbt/siri-sm/internal/unsubscribe"
+)
+
+const (
+	STATUS_ACTIVE     string = "active"
+	STATUS_REJECTED   string = "rejected"
+	STATUS_TERMINATED string = "terminated"
+)
+
+// OPEN_TIMEOUT is the wait for the lock of a file opened by another process
+const OPEN_TIMEOUT time.Duration = time.Second
+
+// subscriptionsBucket holds a bucket per supplier, keyed by subscription ref
+var subscriptionsBucket = []byte("subscriptions")
+
+type Record struct {
+	Supplier         string    `json:"supplier"` // address of the supplier
+	SubscriptionRef  string    `json:"subscription_ref"`
+	SubscriberRef    string    `json:"subscriber_ref"`
+	MonitoringRef    string    `json:"monitoring_ref,omitempty"` // empty for the services filtered by line
+	LineRef          string    `json:"line_ref,omitempty"`
+	Status           string    `json:"status"`
+	Error            string    `json:"error,omitempty"`
+	ValidUntil       time.Time `json:"valid_until"`       // zero when unknown
+	LastNotification time.Time `json:"last_notification"` // zero before the first one
+	UpdatedAt        time.Time `json:"updated_at"`
+}
+
+// Active is true for an accepted subscription not expired yet
+func (r Record) Active(now time.Time) bool {
+	return r.Status == STATUS_ACTIVE && (r.ValidUntil.IsZero() || r.ValidUntil.After(now))
+}
+
+// Store is the bbolt file of the subscriptions, opened for each operation
+// only: the daemons reading it and the commands writing it run side by side,
+// a process waits up to `OPEN_TIMEOUT` for the operation of another one
+type Store struct {
+	path string
+	// mutex serializes the operations of the process, bbolt locks the file
+	// for each opening even in the same process
+	mutex sync.Mutex
+}
+
+// Open checks the file, created when missing, with the records of the
+// previous runs
+func Open(path string) (*Store, error) {
+	s := &Store{path: path}
+	err := s.update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(subscriptionsBucket)
+		if err != nil {
+			return fmt.Errorf("error initializing the subscription state %s: %s", path, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *Store) open(readOnly bool) (*bolt.DB, error) {
+	db, err := bolt.Open(s.path, 0600, &bolt.Options{Timeout: OPEN_TIMEOUT, ReadOnly: readOnly})
+	if err == bolt.ErrTimeout {
+		return nil, fmt.Errorf("error opening the subscription state %s: locked by another process", s.path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error opening the subscription state %s: %s", s.path, err)
+	}
+	return db, nil
+}
+
+// view runs a read-only transaction, other processes may read the file at
+// the same time
+func (s *Store) view(fn func(tx *bolt.Tx) error) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	db, err := s.open(true)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	return db.View(fn)
+}
+
+// update runs a read-write transaction, the file is locked meanwhile
+func (s *Store) update(fn func(tx *bolt.Tx) error) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	db, err := s.open(false)
+	if err != nil {
+		return err
+	}
+	err = db.Update(fn)
+	if closeErr := db.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// Put writes the records in a single transaction
+func (s *Store) Put(records ...Record) error {
+	return s.update(func(tx *bolt.Tx) error {
+		for _, record := range records {
+			err := put(tx, record)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *Store) Get(supplier string, subscriptionRef string) (Record, bool, error) {
+	var record Record
+	var ok bool
+	err := s.view(func(tx *bolt.Tx) error {
+		var err error
+		record, ok, err = get(tx, supplier, subscriptionRef)
+		return err
+	})
+	return record, ok, err
+}
+
+// All returns the records sorted by supplier and subscription ref
+func (s *Store) All() ([]Record, error) {
+	records := make([]Record, 0)
+	err := s.view(func(tx *bolt.Tx) error {
+		return tx.Bucket(subscriptionsBucket).ForEach(func(supplier []byte, _ []byte) error {
+			supplierRecords, err := list(tx, string(supplier))
+			records = append(records, supplierRecords...)
+			return err
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+// Supplier returns the records of a supplier sorted by subscription ref
+func (s *Store) Supplier(supplier string) ([]Record, error) {
+	var records []Record
+	err := s.view(func(tx *bolt.Tx) error {
+		var err error
+		records, err = list(tx, supplier)
+		return err
+	})
+	return records, err
+}
+
+// Active returns the active subscriptions of a supplier
+func (s *Store) Active(supplier string, now time.Time) ([]Record, error) {
+	records, err := s.Supplier(supplier)
+	if err != nil {
+		return nil, err
+	}
+	active := make([]Record, 0, len(records))
+	for _, record := range records {
+		if record.Active(now) {
+			active = append(active, record)
+		}
+	}
+	return active, nil
+}
+
+// RecordSubscribe records the statuses of a Subscribe answer, the last
+// notification of a renewed subscription is kept
+func (s *Store) RecordSubscribe(supplier string, result subscribe.SubscribeRequestInfoResult, at time.Time) error {
+	return s.update(func(tx *bolt.Tx) error {
+		for _, status := range result.ResponseStatus {
+			record, _, err := get(tx, supplier, status.SubscriptionRef)
+			if err != nil {
+				return err
+			}
+			target := result.Targets[status.SubscriptionRef]
+			record.Supplier = supplier
+			record.SubscriptionRef = status.SubscriptionRef
+			record.SubscriberRef = status.SubscriberRef
+			record.MonitoringRef = target.MonitoringRef
+			record.LineRef = target.LineRef
+			record.Status = STATUS_ACTIVE
+			record.Error = ""
+			if !status.Status {
+				record.Status = STATUS_REJECTED
+				record.Error = status.ErrorText
+			}
+			record.ValidUntil = time.Time(status.ValidUntil)
+			record.UpdatedAt = at
+			err = put(tx, record)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// RecordTermination records the subscriptions terminated by a
+// DeleteSubscription answer, the others are left as they are
+func (s *Store) RecordTermination(supplier string, statuses []unsubscribe.TerminationResponseStatus, at time.Time) error {
+	return s.update(func(tx *bolt.Tx) error {
+		for _, status := range statuses {
+			if !status.Status {
+				continue
+			}
+			record, _, err := get(tx, supplier, status.SubscriptionRef)
+			if err != nil {
+				return err
+			}
+			record.Supplier = supplier
+			record.SubscriptionRef = status.SubscriptionRef
+			record.SubscriberRef = status.SubscriberRef
+			record.Status = STATUS_TERMINATED
+			record.Error = ""
+			record.UpdatedAt = at
+			err = put(tx, record)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// RecordNotifications records the reception of a notification of the
+// subscriptions in a single transaction, and returns the unknown ones
+func (s *Store) RecordNotifications(supplier string, subscriptionRefs []string, at time.Time) ([]string, error) {
+	unknown := make([]string, 0)
+	err := s.update(func(tx *bolt.Tx) error {
+		unknown = unknown[:0]
+		for _, subscriptionRef := range subscriptionRefs {
+			record, ok, err := get(tx, supplier, subscriptionRef)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				unknown = append(unknown, subscriptionRef)
+				continue
+			}
+			record.LastNotification = at
+			err = put(tx, record)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return unknown, nil
+}
+
+func put(tx *bolt.Tx, record Record) error {
+	bucket, err := tx.Bucket(subscriptionsBucket).CreateBucketIfNotExists([]byte(record.Supplier))
+	if err != nil {
+		return fmt.Errorf("error writing the subscription %s: %s", record.SubscriptionRef, err)
+	}
+	value, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("error writing the subscription %s: %s", record.SubscriptionRef, err)
+	}
+	return bucket.Put([]byte(record.SubscriptionRef), value)
+}
+
+func get(tx *bolt.Tx, supplier string, subscriptionRef string) (Record, bool, error) {
+	bucket := tx.Bucket(subscriptionsBucket).Bucket([]byte(supplier))
+	if bucket == nil {
+		return Record{}, false, nil
+	}
+	value := bucket.Get([]byte(subscriptionRef))
+	if value == nil {
+		return Record{}, false, nil
+	}
+	var record Record
+	err := json.Unmarshal(value, &record)
+	if err != nil {
+		return Record{}, false, fmt.Errorf("error reading the subscription %s: %s", subscriptionRef, err)
+	}
+	return record, true, nil
+}
+
+// list returns the records of a supplier, bbolt iterates over the keys in
+// byte order
+func list(tx *bolt.Tx, supplier string) ([]Record, error) {
+	records := make([]Record, 0)
+	bucket := tx.Bucket(subscriptionsBucket).Bucket([]byte(supplier))
+	if bucket == nil {
+		return records, nil
+	}
+	err := bucket.ForEach(func(key []byte, value []byte) error {
+		var record Record
+		err := json.Unmarshal(value, &record)
+		if err != nil {
+			return fmt.Errorf("error reading the subscription %s: %s", key, err)
+		}
+		records = append(records, record)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+// RecordPlan records the outcome of the applied steps of a reconciliation
+// plan, the subscribed stops are monitoring refs of the producer
+func (s *Store) RecordPlan(supplier string, subscriberRef string, producerRef string, plan reconcile.Plan, at time.Time) error {
+	return s.update(func(tx *bolt.Tx) error {
+		for _, step := range plan.Steps {
+			if !step.Applied || step.Action == reconcile.ACTION_KEEP {
+				continue
+			}
+			if step.Action == reconcile.ACTION_DELETE && !step.Status {
+				continue
+			}
+			record, _, err := get(tx, supplier, step.SubscriptionRef)
+			if err != nil {
+				return err
+			}
+			record.Supplier = supplier
+			record.SubscriptionRef = step.SubscriptionRef
+			record.SubscriberRef = subscriberRef
+			record.UpdatedAt = at
+			switch {
+			case step.Action == reconcile.ACTION_DELETE:
+				record.Status = STATUS_TERMINATED
+				record.Error = ""
+			case step.Status:
+				record.Status = STATUS_ACTIVE
+				record.Error = ""
+			default:
+				record.Status = STATUS_REJECTED
+				record.Error = step.Error
+			}
+			if step.Action == reconcile.ACTION_SUBSCRIBE {
+				record.MonitoringRef = subscribe.MonitoringRef(producerRef, step.StopPointId)
+				record.LineRef = step.LineRef
+				record.ValidUntil = step.ValidUntil
+			}
+			err = put(tx, record)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Subscriptions are the records as the active subscriptions of a
+// reconciliation
+func Subscriptions(records []Record) []reconcile.Subscription {
+	subscriptions := make([]reconcile.Subscription, 0, len(records))
+	for _, record := range records {
+		subscriptions = append(subscriptions, reconcile.Subscription{
+			SubscriptionRef: record.SubscriptionRef,
+			SubscriberRef:   record.SubscriberRef,
+			ValidUntil:      record.ValidUntil,
+		})
+	}
+	return subscriptions
+}
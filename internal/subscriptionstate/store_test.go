@@ -0,0 +1,175 @@
+package subscriptionstate
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	bolt "go.etcd.io/bbolt"
+
+	siri_time "github.com/julienbt/siri-sm/internal/common/time"
+	"github.com/julienbt/siri-sm/internal/reconcile"
+	"github.com/julienbt/siri-sm/internal/subscribe"
+	"github.com/julienbt/siri-sm/internal/unsubscribe"
+)
+
+const SUPPLIER string = "http://lille"
+
+func newSubscribeResult(validUntil time.Time) subscribe.SubscribeRequestInfoResult {
+	return subscribe.SubscribeRequestInfoResult{
+		ResponseStatus: []subscribe.ResponseStatus{
+			{
+				SubscriberRef:   "KISIO2",
+				SubscriptionRef: "KISIO2:Subscription:arret_CAS001:LOC",
+				Status:          true,
+				ValidUntil:      siri_time.Time(validUntil),
+			},
+			{
+				SubscriberRef:   "KISIO2",
+				SubscriptionRef: "KISIO2:Subscription:arret_CAS002:LOC",
+				Status:          false,
+				ErrorText:       "Unknown stop",
+			},
+		},
+		Targets: map[string]subscribe.Target{
+			"KISIO2:Subscription:arret_CAS001:LOC": {MonitoringRef: "ILEVIA:StopPoint:BP:CAS001:LOC"},
+			"KISIO2:Subscription:arret_CAS002:LOC": {MonitoringRef: "ILEVIA:StopPoint:BP:CAS002:LOC"},
+		},
+	}
+}
+
+func TestReloadedOnOpen(t *testing.T) {
+	require := require.New(t)
+	path := filepath.Join(t.TempDir(), "subscriptions.db")
+	now := time.Date(2022, 8, 30, 8, 0, 0, 0, time.UTC)
+
+	store, err := Open(path)
+	require.Nil(err)
+	require.Nil(store.RecordSubscribe(SUPPLIER, newSubscribeResult(now.Add(24*time.Hour)), now))
+	unknown, err := store.RecordNotifications(
+		SUPPLIER,
+		[]string{"KISIO2:Subscription:arret_CAS001:LOC", "KISIO2:Subscription:unknown:LOC"},
+		now.Add(time.Minute),
+	)
+	require.Nil(err)
+	require.Equal([]string{"KISIO2:Subscription:unknown:LOC"}, unknown)
+	// Reopened by another process
+	store, err = Open(path)
+	require.Nil(err)
+	records, err := store.All()
+	require.Nil(err)
+	require.Len(records, 2)
+	require.Equal(Record{
+		Supplier:         SUPPLIER,
+		SubscriptionRef:  "KISIO2:Subscription:arret_CAS001:LOC",
+		SubscriberRef:    "KISIO2",
+		MonitoringRef:    "ILEVIA:StopPoint:BP:CAS001:LOC",
+		Status:           STATUS_ACTIVE,
+		ValidUntil:       now.Add(24 * time.Hour),
+		LastNotification: now.Add(time.Minute),
+		UpdatedAt:        now,
+	}, records[0])
+	require.Equal(STATUS_REJECTED, records[1].Status)
+	require.Equal("Unknown stop", records[1].Error)
+
+	active, err := store.Active(SUPPLIER, now)
+	require.Nil(err)
+	require.Len(active, 1)
+	active, err = store.Active(SUPPLIER, now.Add(25*time.Hour))
+	require.Nil(err)
+	require.Empty(active)
+	active, err = store.Active("http://amiens", now)
+	require.Nil(err)
+	require.Empty(active)
+}
+
+func TestSharedBetweenProcesses(t *testing.T) {
+	require := require.New(t)
+	path := filepath.Join(t.TempDir(), "subscriptions.db")
+	now := time.Date(2022, 8, 30, 8, 0, 0, 0, time.UTC)
+
+	daemon, err := Open(path)
+	require.Nil(err)
+	command, err := Open(path)
+	require.Nil(err)
+	require.Nil(command.RecordSubscribe(SUPPLIER, newSubscribeResult(now.Add(time.Hour)), now))
+	active, err := daemon.Active(SUPPLIER, now)
+	require.Nil(err)
+	require.Len(active, 1)
+
+	// An operation waits for the one of another process, and gives up
+	db, err := bolt.Open(path, 0600, nil)
+	require.Nil(err)
+	defer db.Close()
+	_, err = daemon.Active(SUPPLIER, now)
+	require.NotNil(err)
+	require.Contains(err.Error(), "locked by another process")
+}
+
+func TestRenewalKeepsTheLastNotification(t *testing.T) {
+	require := require.New(t)
+	store, err := Open(filepath.Join(t.TempDir(), "subscriptions.db"))
+	require.Nil(err)
+	now := time.Date(2022, 8, 30, 8, 0, 0, 0, time.UTC)
+
+	require.Nil(store.RecordSubscribe(SUPPLIER, newSubscribeResult(now.Add(time.Hour)), now))
+	_, err = store.RecordNotifications(SUPPLIER, []string{"KISIO2:Subscription:arret_CAS001:LOC"}, now.Add(time.Minute))
+	require.Nil(err)
+	require.Nil(store.RecordSubscribe(SUPPLIER, newSubscribeResult(now.Add(25*time.Hour)), now.Add(time.Hour)))
+
+	record, ok, err := store.Get(SUPPLIER, "KISIO2:Subscription:arret_CAS001:LOC")
+	require.Nil(err)
+	require.True(ok)
+	require.Equal(now.Add(25*time.Hour), record.ValidUntil)
+	require.Equal(now.Add(time.Minute), record.LastNotification)
+}
+
+func TestRecordTermination(t *testing.T) {
+	require := require.New(t)
+	store, err := Open(filepath.Join(t.TempDir(), "subscriptions.db"))
+	require.Nil(err)
+	now := time.Date(2022, 8, 30, 8, 0, 0, 0, time.UTC)
+	require.Nil(store.RecordSubscribe(SUPPLIER, newSubscribeResult(now.Add(time.Hour)), now))
+
+	require.Nil(store.RecordTermination(SUPPLIER, []unsubscribe.TerminationResponseStatus{
+		{SubscriberRef: "KISIO2", SubscriptionRef: "KISIO2:Subscription:arret_CAS001:LOC", Status: true},
+		{SubscriberRef: "KISIO2", SubscriptionRef: "KISIO2:Subscription:arret_CAS002:LOC", Status: false},
+	}, now))
+	records, err := store.Supplier(SUPPLIER)
+	require.Nil(err)
+	require.Equal(STATUS_TERMINATED, records[0].Status)
+	require.Equal(STATUS_REJECTED, records[1].Status)
+}
+
+func TestRecordPlan(t *testing.T) {
+	require := require.New(t)
+	store, err := Open(filepath.Join(t.TempDir(), "subscriptions.db"))
+	require.Nil(err)
+	now := time.Date(2022, 8, 30, 8, 0, 0, 0, time.UTC)
+	require.Nil(store.RecordSubscribe(SUPPLIER, newSubscribeResult(now.Add(time.Hour)), now))
+
+	require.Nil(store.RecordPlan(SUPPLIER, "KISIO2", "ILEVIA", reconcile.Plan{Steps: []reconcile.Step{
+		{Action: reconcile.ACTION_DELETE, SubscriptionRef: "KISIO2:Subscription:arret_CAS001:LOC", Applied: true, Status: true},
+		{
+			Action:          reconcile.ACTION_SUBSCRIBE,
+			SubscriptionRef: "KISIO2:Subscription:arret_CAT001:LOC",
+			StopPointId:     "CAT001",
+			ValidUntil:      now.Add(time.Hour),
+			Applied:         true,
+			Status:          true,
+		},
+		{Action: reconcile.ACTION_SUBSCRIBE, SubscriptionRef: "KISIO2:Subscription:arret_CAT002:LOC", StopPointId: "CAT002"},
+	}}, now))
+
+	active, err := store.Active(SUPPLIER, now)
+	require.Nil(err)
+	require.Len(active, 1)
+	require.Equal("KISIO2:Subscription:arret_CAT001:LOC", active[0].SubscriptionRef)
+	require.Equal("ILEVIA:StopPoint:BP:CAT001:LOC", active[0].MonitoringRef)
+	require.Equal([]reconcile.Subscription{{
+		SubscriptionRef: "KISIO2:Subscription:arret_CAT001:LOC",
+		SubscriberRef:   "KISIO2",
+		ValidUntil:      now.Add(time.Hour),
+	}}, Subscriptions(active))
+}
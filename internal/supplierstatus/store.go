@@ -0,0 +1,69 @@
+package supplierstatus
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/julienbt/siri-sm/internal/checkstatus"
+)
+
+type Status struct {
+	Supplier          string
+	CheckStatusResult checkstatus.CheckStatusResult
+	LastCheckStatusAt time.Time
+	LastSuccessAt     time.Time // of the last CheckStatus without error
+	LastError         string
+}
+
+// Available is true when the last CheckStatus of the supplier succeeded
+func (s Status) Available() bool {
+	return !s.LastCheckStatusAt.IsZero() && s.LastError == ""
+}
+
+// Store keeps the outcome of the last CheckStatus of every supplier
+type Store struct {
+	mutex     sync.RWMutex
+	suppliers map[string]Status
+}
+
+func NewStore() *Store {
+	return &Store{
+		suppliers: make(map[string]Status),
+	}
+}
+
+// Update records a CheckStatus outcome, on error the last successful result is kept
+func (s *Store) Update(supplier string, result checkstatus.CheckStatusResult, err error, at time.Time) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	status := s.suppliers[supplier]
+	status.Supplier = supplier
+	status.LastCheckStatusAt = at
+	if err != nil {
+		status.LastError = err.Error()
+	} else {
+		status.LastError = ""
+		status.LastSuccessAt = at
+		status.CheckStatusResult = result
+	}
+	s.suppliers[supplier] = status
+}
+
+func (s *Store) Get(supplier string) (Status, bool) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	status, ok := s.suppliers[supplier]
+	return status, ok
+}
+
+func (s *Store) All() []Status {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	all := make([]Status, 0, len(s.suppliers))
+	for _, status := range s.suppliers {
+		all = append(all, status)
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].Supplier < all[j].Supplier })
+	return all
+}
@@ -0,0 +1,133 @@
+package unsubscribe
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"text/template"
+	"time"
+
+	"github.com/julienbt/siri-sm/internal/clockskew"
+	"github.com/julienbt/siri-sm/internal/config"
+	"github.com/julienbt/siri-sm/internal/messageid"
+	"github.com/julienbt/siri-sm/internal/siri"
+	"github.com/sirupsen/logrus"
+)
+
+// DeleteSubscriptionRequest terminates the subscriptions of the requestor,
+// all of them without `SubscriptionRefs`
+type DeleteSubscriptionRequest struct {
+	SupplierAddress   url.URL
+	Binding           siri.Binding
+	Location          *time.Location // of the supplier
+	RequestTimestamp  time.Time
+	RequestorRef      string
+	MessageIdentifier string
+	SubscriptionRefs  []string
+}
+
+func Unsubscribe(
+	cfg config.ConfigCheckStatus,
+	logger *logrus.Entry,
+	requestTimestamp *time.Time,
+	subscriptionRefs []string,
+) ([]TerminationResponseStatus, string, []byte, error) {
+	var remoteErrorLoc = "DeleteSubscription remote error"
+	req := DeleteSubscriptionRequest{}
+	err := req.populate(&cfg, requestTimestamp, subscriptionRefs)
+	if err != nil {
+		return nil,
+			"",
+			nil,
+			fmt.Errorf("error DeleteSubscription request initialization: %v", err)
+	}
+	httpReq, htmlReqBody, err := req.generateHttpReq()
+	if err != nil {
+		return nil,
+			"",
+			nil,
+			fmt.Errorf("error in building DeleteSubscription request: %s", err)
+	}
+
+	// Send HTTP request and receive the response
+	resp, err := siri.SoapCall(httpReq)
+	if err != nil {
+		return nil,
+			htmlReqBody,
+			nil,
+			&siri.RemoteError{Loc: remoteErrorLoc, Err: fmt.Errorf("call error: %s", err)}
+	}
+
+	// Get the HTTP response body
+	defer resp.Body.Close()
+	htmlRespBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil,
+			htmlReqBody,
+			nil,
+			&siri.RemoteError{Loc: remoteErrorLoc, Err: fmt.Errorf("unreadable response body: %s", err)}
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil,
+			htmlReqBody,
+			htmlRespBody,
+			&siri.RemoteError{Loc: remoteErrorLoc, Err: fmt.Errorf("bad http-response status: %s", resp.Status)}
+	}
+
+	// Parse the succesfull HTTP Response
+	answer := &DeleteSubscriptionAnswer{}
+	err = req.Binding.DecodeAnswerIn(htmlRespBody, DELETE_SUBSCRIPTION_ANSWER_PATH, answer, req.Location)
+	if err != nil {
+		return nil,
+			htmlReqBody,
+			htmlRespBody,
+			&siri.RemoteError{Loc: remoteErrorLoc, Err: fmt.Errorf("unmarshallable response body: %s", err)}
+	}
+	return answer.TerminationResponseStatus, htmlReqBody, htmlRespBody, nil
+}
+
+func (req *DeleteSubscriptionRequest) populate(
+	cfg *config.ConfigCheckStatus,
+	requestTimestamp *time.Time,
+	subscriptionRefs []string,
+) error {
+	supplierAddressUrl, err := url.Parse(cfg.SupplierAddress)
+	if err != nil {
+		return fmt.Errorf("error the supplier address is not a valid URL: %s", cfg.SupplierAddress)
+	}
+	binding, err := siri.ParseBinding(cfg.Binding)
+	if err != nil {
+		return err
+	}
+	req.Binding = binding
+	location, err := cfg.Location()
+	if err != nil {
+		return err
+	}
+	req.Location = location
+	req.RequestTimestamp = clockskew.Default.Compensate(cfg.SupplierAddress, requestTimestamp.In(location))
+	req.RequestorRef = cfg.SubscriberRef
+	req.MessageIdentifier, err = messageid.New(cfg.MessageIdPattern, req.RequestorRef, "DeleteSubscription", req.RequestTimestamp)
+	if err != nil {
+		return err
+	}
+	req.SupplierAddress = *supplierAddressUrl
+	req.SubscriptionRefs = subscriptionRefs
+	return nil
+}
+
+func (req *DeleteSubscriptionRequest) generateHttpReq() (*http.Request, string, error) {
+	tmpl, err := template.ParseFiles("./template/deletesubscription-request.tmpl")
+	if err != nil {
+		return nil, "", fmt.Errorf("error parsing template: %s", err)
+	}
+	payloadBuffer := &bytes.Buffer{}
+	err = tmpl.ExecuteTemplate(payloadBuffer, req.Binding.PayloadTemplateName(), req)
+	if err != nil {
+		return nil, "", fmt.Errorf("error building template: %s", err)
+	}
+	return req.Binding.NewHttpRequest(req.SupplierAddress.String(), "DeleteSubscription", payloadBuffer.String())
+}
@@ -0,0 +1,27 @@
+package unsubscribe
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+
+	"github.com/julienbt/siri-sm/internal/clockskew"
+	"github.com/julienbt/siri-sm/internal/config"
+)
+
+func TestPopulateCompensatesClockSkew(t *testing.T) {
+	require := require.New(t)
+	defaultTracker := clockskew.Default
+	defer func() { clockskew.Default = defaultTracker }()
+
+	sentAt := time.Date(2022, 8, 30, 8, 0, 0, 0, time.UTC)
+	clockskew.Default = clockskew.NewTracker(0, true)
+	clockskew.Default.Record(logrus.NewEntry(logrus.New()), "http://lille", sentAt, sentAt, sentAt.Add(-3*time.Minute))
+
+	cfg := config.ConfigCheckStatus{SupplierAddress: "http://lille", SubscriberRef: "KISIO2", Timezone: "UTC"}
+	req := DeleteSubscriptionRequest{}
+	require.Nil(req.populate(&cfg, &sentAt, nil))
+	require.True(sentAt.Add(-3 * time.Minute).Equal(req.RequestTimestamp))
+}
@@ -0,0 +1,39 @@
+package unsubscribe
+
+import (
+	"encoding/xml"
+
+	siri_time "github.com/julienbt/siri-sm/internal/common/time"
+	"github.com/julienbt/siri-sm/internal/siri"
+)
+
+var DELETE_SUBSCRIPTION_ANSWER_PATH = siri.AnswerPath{
+	Soap: []string{"DeleteSubscriptionResponse", "Answer"},
+	Raw:  []string{"TerminateSubscriptionResponse"},
+}
+
+// DeleteSubscriptionAnswer is the `Answer` element in SOAP, and the
+// `TerminateSubscriptionResponse` element in raw SIRI
+type DeleteSubscriptionAnswer struct {
+	XMLName                   xml.Name
+	TerminationResponseStatus []TerminationResponseStatus `xml:"TerminationResponseStatus"`
+}
+
+type TerminationResponseStatus struct {
+	XMLName           xml.Name       `xml:"TerminationResponseStatus"`
+	ResponseTimestamp siri_time.Time `xml:"ResponseTimestamp"`
+	SubscriberRef     string         `xml:"SubscriberRef"`
+	SubscriptionRef   string         `xml:"SubscriptionRef"`
+	Status            bool           `xml:"Status"`
+	ErrorText         string         `xml:"ErrorCondition>ErrorText"`
+	// Most suppliers nest the text in the error, e.g. `UnknownSubscriptionError`
+	NestedErrorText string `xml:"ErrorCondition>UnknownSubscriptionError>ErrorText"`
+}
+
+// Error returns the text of the error condition, if any
+func (s *TerminationResponseStatus) Error() string {
+	if s.ErrorText != "" {
+		return s.ErrorText
+	}
+	return s.NestedErrorText
+}
@@ -0,0 +1,50 @@
+package unsubscribe
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/julienbt/siri-sm/internal/siri"
+)
+
+var testDataDir string
+
+func TestMain(m *testing.M) {
+
+	testDataDir = os.Getenv("SIRISM_TEST_DATA_DIR")
+	if testDataDir == "" {
+		panic("$SIRISM_TEST_DATA_DIR isn't set")
+	}
+
+	os.Exit(m.Run())
+}
+
+func TestDeleteSubscriptionAnswerDecode(t *testing.T) {
+	require := require.New(t)
+
+	htmlRespBody, err := ioutil.ReadFile(
+		fmt.Sprintf(
+			"%s/examples/DELSUB_RESP_000.xml",
+			testDataDir,
+		),
+	)
+	require.Nil(err)
+
+	answer := DeleteSubscriptionAnswer{}
+	err = siri.BINDING_SOAP_11.DecodeAnswer(htmlRespBody, DELETE_SUBSCRIPTION_ANSWER_PATH, &answer)
+	require.Nil(err)
+	require.Len(answer.TerminationResponseStatus, 2)
+
+	terminated := answer.TerminationResponseStatus[0]
+	require.Equal("KISIO2:Subscription:arret_11N001:LOC", terminated.SubscriptionRef)
+	require.True(terminated.Status)
+	require.Empty(terminated.Error())
+
+	unknown := answer.TerminationResponseStatus[1]
+	require.False(unknown.Status)
+	require.Equal("Unknown subscription", unknown.Error())
+}
@@ -0,0 +1,73 @@
+package vehiclemonitoring
+
+import (
+	"encoding/xml"
+
+	siri_time "github.com/julienbt/siri-sm/internal/common/time"
+	"github.com/julienbt/siri-sm/internal/getstopmonitoring"
+	"github.com/julienbt/siri-sm/internal/siri"
+)
+
+var VEHICLE_MONITORING_DELIVERY_PATH = siri.AnswerPath{
+	Soap: []string{"GetVehicleMonitoringResponse", "Answer", "VehicleMonitoringDelivery"},
+	Raw:  []string{"ServiceDelivery", "VehicleMonitoringDelivery"},
+}
+
+type VehicleMonitoringDelivery struct {
+	XMLName           xml.Name          `xml:"VehicleMonitoringDelivery"`
+	ResponseTimestamp siri_time.Time    `xml:"ResponseTimestamp"`
+	Status            *bool             `xml:"Status"`
+	VehicleActivities []VehicleActivity `xml:"VehicleActivity"`
+}
+
+type VehicleActivity struct {
+	XMLName                 xml.Name                `xml:"VehicleActivity"`
+	RecordedAtTime          siri_time.Time          `xml:"RecordedAtTime"`
+	ValidUntilTime          siri_time.Time          `xml:"ValidUntilTime"`
+	ItemIdentifier          string                  `xml:"ItemIdentifier"`
+	VehicleMonitoringRef    string                  `xml:"VehicleMonitoringRef"`
+	ProgressBetweenStops    *ProgressBetweenStops   `xml:"ProgressBetweenStops"`
+	MonitoredVehicleJourney MonitoredVehicleJourney `xml:"MonitoredVehicleJourney"`
+}
+
+// ProgressBetweenStops is the progress of the vehicle along the link to the next stop
+type ProgressBetweenStops struct {
+	LinkDistance float64 `xml:"LinkDistance"` // in meters
+	Percentage   float64 `xml:"Percentage"`
+}
+
+type MonitoredVehicleJourney struct {
+	XMLName                 xml.Name                                  `xml:"MonitoredVehicleJourney"`
+	LineRef                 getstopmonitoring.LineRef                 `xml:"LineRef"`
+	DirectionRef            string                                    `xml:"DirectionRef"`
+	FramedVehicleJourneyRef getstopmonitoring.FramedVehicleJourneyRef `xml:"FramedVehicleJourneyRef"`
+	PublishedLineName       string                                    `xml:"PublishedLineName"`
+	OperatorRef             string                                    `xml:"OperatorRef"`
+	OriginRef               string                                    `xml:"OriginRef"`
+	OriginName              string                                    `xml:"OriginName"`
+	DestinationRef          string                                    `xml:"DestinationRef"`
+	DestinationName         string                                    `xml:"DestinationName"`
+	Monitored               bool                                      `xml:"Monitored"`
+	VehicleLocation         *VehicleLocation                          `xml:"VehicleLocation"`
+	Bearing                 *float64                                  `xml:"Bearing"` // in degrees clockwise from the north
+	ProgressRate            string                                    `xml:"ProgressRate"`
+	ProgressStatus          string                                    `xml:"ProgressStatus"`
+	Delay                   string                                    `xml:"Delay"` // XML duration, e.g. `PT2M`
+	VehicleRef              string                                    `xml:"VehicleRef"`
+	MonitoredCall           *MonitoredCall                            `xml:"MonitoredCall"`
+}
+
+type VehicleLocation struct {
+	Longitude float64 `xml:"Longitude"`
+	Latitude  float64 `xml:"Latitude"`
+}
+
+// MonitoredCall is the current or next call of the vehicle
+type MonitoredCall struct {
+	StopPointRef          getstopmonitoring.StopPointRef `xml:"StopPointRef"`
+	Order                 int                            `xml:"Order"`
+	StopPointName         string                         `xml:"StopPointName"`
+	VehicleAtStop         bool                           `xml:"VehicleAtStop"`
+	AimedDepartureTime    siri_time.Time                 `xml:"AimedDepartureTime"`
+	ExpectedDepartureTime siri_time.Time                 `xml:"ExpectedDepartureTime"`
+}
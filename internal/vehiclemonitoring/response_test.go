@@ -0,0 +1,66 @@
+package vehiclemonitoring
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/julienbt/siri-sm/internal/getstopmonitoring"
+	"github.com/julienbt/siri-sm/internal/siri"
+)
+
+var testDataDir string
+
+func TestMain(m *testing.M) {
+
+	testDataDir = os.Getenv("SIRISM_TEST_DATA_DIR")
+	if testDataDir == "" {
+		panic("$SIRISM_TEST_DATA_DIR isn't set")
+	}
+
+	os.Exit(m.Run())
+}
+
+func TestVehicleMonitoringDeliveryDecode(t *testing.T) {
+	require := require.New(t)
+
+	htmlRespBody, err := ioutil.ReadFile(
+		fmt.Sprintf(
+			"%s/examples/VM_RESP_000.xml",
+			testDataDir,
+		),
+	)
+	require.Nil(err)
+
+	delivery := VehicleMonitoringDelivery{}
+	err = siri.BINDING_RAW.DecodeAnswer(htmlRespBody, VEHICLE_MONITORING_DELIVERY_PATH, &delivery)
+	require.Nil(err)
+	require.Len(delivery.VehicleActivities, 2)
+
+	activity := delivery.VehicleActivities[0]
+	require.Equal("ILEVIA:Vehicle:BP:812:LOC", activity.VehicleMonitoringRef)
+	require.NotNil(activity.ProgressBetweenStops)
+	require.Equal(37.2, activity.ProgressBetweenStops.Percentage)
+
+	journey := activity.MonitoredVehicleJourney
+	require.Equal(getstopmonitoring.LineRef("L1"), journey.LineRef)
+	require.Equal("ILEVIA:VehicleJourney:BP:4242:LOC", journey.FramedVehicleJourneyRef.DatedVehicleJourneyRef)
+	require.Equal(&VehicleLocation{Longitude: 3.0573, Latitude: 50.6365}, journey.VehicleLocation)
+	require.NotNil(journey.Bearing)
+	require.Equal(92.5, *journey.Bearing)
+	require.Equal("PT1M30S", journey.Delay)
+	require.NotNil(journey.MonitoredCall)
+	require.Equal(getstopmonitoring.StopPointRef("CAS001"), journey.MonitoredCall.StopPointRef)
+
+	// Neither location nor bearing for the second vehicle
+	require.Nil(delivery.VehicleActivities[1].MonitoredVehicleJourney.VehicleLocation)
+	require.Nil(delivery.VehicleActivities[1].MonitoredVehicleJourney.Bearing)
+
+	filtered, err := FilterByLineRefs(delivery.VehicleActivities, []string{"ILEVIA:Line:BP:L2:LOC"})
+	require.Nil(err)
+	require.Len(filtered, 1)
+	require.Equal(getstopmonitoring.LineRef("L2"), filtered[0].MonitoredVehicleJourney.LineRef)
+}
@@ -0,0 +1,86 @@
+package visitstore
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/julienbt/siri-sm/internal/getstopmonitoring"
+)
+
+// StopVisits are the last known visits of a stop for a supplier
+type StopVisits struct {
+	Supplier  string
+	StopRef   getstopmonitoring.StopPointRef
+	Visits    []getstopmonitoring.MonitoredStopVisit
+	UpdatedAt time.Time
+}
+
+type stopKey struct {
+	supplier string
+	stopRef  getstopmonitoring.StopPointRef
+}
+
+// Store keeps the current `MonitoredStopVisit` state of every monitored stop
+type Store struct {
+	mutex sync.RWMutex
+	stops map[stopKey]StopVisits
+}
+
+func NewStore() *Store {
+	return &Store{
+		stops: make(map[stopKey]StopVisits),
+	}
+}
+
+// Update replaces all the visits of a stop
+func (s *Store) Update(
+	supplier string,
+	stopRef getstopmonitoring.StopPointRef,
+	visits []getstopmonitoring.MonitoredStopVisit,
+	updatedAt time.Time,
+) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.stops[stopKey{supplier: supplier, stopRef: stopRef}] = StopVisits{
+		Supplier:  supplier,
+		StopRef:   stopRef,
+		Visits:    visits,
+		UpdatedAt: updatedAt,
+	}
+}
+
+// Stop returns the visits of a stop for all the suppliers monitoring it
+func (s *Store) Stop(stopRef getstopmonitoring.StopPointRef) []StopVisits {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	stops := make([]StopVisits, 0)
+	for key, stopVisits := range s.stops {
+		if key.stopRef == stopRef {
+			stops = append(stops, stopVisits)
+		}
+	}
+	sortStopVisits(stops)
+	return stops
+}
+
+// All returns a snapshot of the visits of every stop
+func (s *Store) All() []StopVisits {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	stops := make([]StopVisits, 0, len(s.stops))
+	for _, stopVisits := range s.stops {
+		stops = append(stops, stopVisits)
+	}
+	sortStopVisits(stops)
+	return stops
+}
+
+func sortStopVisits(stops []StopVisits) {
+	sort.Slice(stops, func(i, j int) bool {
+		if stops[i].Supplier != stops[j].Supplier {
+			return stops[i].Supplier < stops[j].Supplier
+		}
+		return stops[i].StopRef < stops[j].StopRef
+	})
+}